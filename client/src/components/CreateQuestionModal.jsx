@@ -119,6 +119,7 @@ const CreateQuestionModal = ({ visible, onClose, mode, question, defaultTab = 'm
       setAiGeneratedQuestions(response.data.data)
       setSelectedAiQuestions([])
       message.success('题目生成成功')
+      ;(response.data.warnings || []).forEach((w) => message.warning(w))
     } catch (error) {
       message.error('题目生成失败')
       console.error('Error generating questions:', error)
@@ -128,6 +129,11 @@ const CreateQuestionModal = ({ visible, onClose, mode, question, defaultTab = 'm
   }
 
   const handleAiQuestionSelect = (index, checked) => {
+    // 占位题目需要人工填写，不能直接加入题库
+    if (checked && aiGeneratedQuestions[index]?.provenance === 'placeholder') {
+      message.warning('占位题目不能直接添加，请先手工编辑')
+      return
+    }
     const next = checked
       ? [...selectedAiQuestions, index]
       : selectedAiQuestions.filter((idx) => idx !== index)
@@ -461,6 +467,22 @@ const CreateQuestionModal = ({ visible, onClose, mode, question, defaultTab = 'm
                           <div style={{ flex: 1 }}>
                             <div style={{ marginBottom: 12, fontSize: '15px', lineHeight: '1.6' }}>
                               <strong style={{ color: '#1890ff' }}>题目 {index + 1}:</strong> {question.content}
+                              {question.provenance === 'repaired' && (
+                                <span style={{ marginLeft: 8, color: '#faad14', fontSize: '12px' }}>[已修复，请核对]</span>
+                              )}
+                              {question.provenance === 'placeholder' && (
+                                <span style={{ marginLeft: 8, color: '#ff4d4f', fontSize: '12px' }}>[占位题目]</span>
+                              )}
+                              {question.duplicates?.length > 0 && (
+                                <span style={{ marginLeft: 8, color: '#ff4d4f', fontSize: '12px' }}>
+                                  [与题库题目 #{question.duplicates[0].id} 重复]
+                                </span>
+                              )}
+                              {question.verification?.status === 'disagreed' && (
+                                <span style={{ marginLeft: 8, color: '#faad14', fontSize: '12px' }}>
+                                  [答案待核对：校验结果为 {question.verification.verifier_answer}]
+                                </span>
+                              )}
                             </div>
                             {question.options && (
                               <div style={{ marginLeft: 0, marginBottom: 10, fontSize: '14px', color: '#666' }}>
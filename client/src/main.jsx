@@ -1,8 +1,14 @@
 import React from 'react'
 import ReactDOM from 'react-dom/client'
+import axios from 'axios'
 import App from './App'
 import './App.css'
 
+// 当前用户通过请求头 X-User 发给服务端，用于记录题目的创建者和审核人；
+// 请求头只能是 ASCII，中文用户名按 URL 编码
+const currentUser = localStorage.getItem('user') || '张三'
+axios.defaults.headers.common['X-User'] = encodeURIComponent(currentUser)
+
 ReactDOM.createRoot(document.getElementById('root')).render(
   <React.StrictMode>
     <App />
@@ -107,6 +107,9 @@ const QuestionManagement = () => {
       const params = {
         page: pagination.current,
         page_size: pagination.pageSize,
+        // 管理页面显示所有审核状态的题目，以及编程题的参考答案和隐藏用例
+        status: 'all',
+        view: 'author',
         ...filters
       }
       const response = await axios.get('/api/questions', { params })
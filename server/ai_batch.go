@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// batchCellSize 每个子请求最多生成的题目数，与 AIGenerateRequest 的上限一致
+	batchCellSize = 20
+	// maxBatchQuestions 一次批量生成的题目总数上限
+	maxBatchQuestions = 200
+	// defaultBatchConcurrency 同时执行的子请求数量
+	defaultBatchConcurrency = 3
+	maxBatchConcurrency     = 5
+)
+
+// batchDifficulties 难度分配和单元格展开时的固定顺序
+var batchDifficulties = []models.Difficulty{models.Easy, models.Medium, models.Hard}
+
+// BatchGenerateRequest 按 主题 × 题型 × 难度 的矩阵批量生成题目
+type BatchGenerateRequest struct {
+	Language string `json:"language" binding:"required"`
+	// Topics 主题列表，为空时不限定主题
+	Topics []string `json:"topics"`
+	// Types 每个主题下各题型的题目数量，如 {"single_choice": 10, "programming": 2}
+	Types map[models.QuestionType]int `json:"types" binding:"required"`
+	// Difficulty 难度分布权重，如 {"easy": 0.3, "medium": 0.5, "hard": 0.2}，不传时全部为 medium
+	Difficulty map[models.Difficulty]float64 `json:"difficulty"`
+	// Concurrency 同时调用模型的数量，默认 3，最大 5
+	Concurrency int `json:"concurrency"`
+	// Save 是否直接保存去重后的题目
+	Save bool `json:"save"`
+	// BankID 保存到指定题库，设置后视为 save=true
+	BankID *uint `json:"bank_id"`
+	// Verify 是否校验选择题答案，不传时由 AI_VERIFY 决定
+	Verify *bool `json:"verify"`
+}
+
+// BatchCellReport 单个子请求的执行情况
+type BatchCellReport struct {
+	Topic      string              `json:"topic"`
+	Type       models.QuestionType `json:"type"`
+	Difficulty models.Difficulty   `json:"difficulty"`
+	Requested  int                 `json:"requested"`
+	Generated  int                 `json:"generated"`  // 通过校验的题目数
+	Duplicates int                 `json:"duplicates"` // 与批次内或题库中题目重复而丢弃的数量
+	Kept       int                 `json:"kept"`
+	Saved      int                 `json:"saved"`
+	Template   *PromptRef          `json:"template,omitempty"`
+	Warnings   []string            `json:"warnings"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// BatchSummary 整个批次的汇总
+type BatchSummary struct {
+	Requested   int `json:"requested"`
+	Kept        int `json:"kept"`
+	Duplicates  int `json:"duplicates"`
+	FailedCells int `json:"failed_cells"`
+	Saved       int `json:"saved"`
+}
+
+// batchCell 展开后的子请求及其结果
+type batchCell struct {
+	spec      AIGenerateRequest
+	report    BatchCellReport
+	questions []GeneratedQuestion
+}
+
+// splitByWeights 按权重把 total 分配给各难度，使用最大余数法保证总数不变
+func splitByWeights(total int, weights map[models.Difficulty]float64) map[models.Difficulty]int {
+	sum := 0.0
+	for _, d := range batchDifficulties {
+		sum += weights[d]
+	}
+
+	counts := make(map[models.Difficulty]int, len(batchDifficulties))
+	remainders := make([]models.Difficulty, 0, len(batchDifficulties))
+	assigned := 0
+	for _, d := range batchDifficulties {
+		if weights[d] <= 0 {
+			continue
+		}
+		exact := float64(total) * weights[d] / sum
+		counts[d] = int(math.Floor(exact))
+		assigned += counts[d]
+		remainders = append(remainders, d)
+	}
+	sort.SliceStable(remainders, func(i, j int) bool {
+		ei := float64(total) * weights[remainders[i]] / sum
+		ej := float64(total) * weights[remainders[j]] / sum
+		return ei-math.Floor(ei) > ej-math.Floor(ej)
+	})
+	for i := 0; assigned < total; i++ {
+		counts[remainders[i%len(remainders)]]++
+		assigned++
+	}
+	return counts
+}
+
+// expandBatchRequest 校验批量请求并展开为子请求，单元格超过 batchCellSize 时拆成多个
+func expandBatchRequest(req BatchGenerateRequest) ([]*batchCell, error) {
+	if len(req.Types) == 0 {
+		return nil, fmt.Errorf("types 不能为空")
+	}
+	types := make([]models.QuestionType, 0, len(req.Types))
+	total := 0
+	for t, n := range req.Types {
+		if converted, ok := validateAndConvertType(string(t)); !ok || converted != t {
+			return nil, fmt.Errorf("不支持的题型: %s", t)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("题型 %s 的数量不能为负数", t)
+		}
+		types = append(types, t)
+		total += n
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	weights := req.Difficulty
+	if len(weights) == 0 {
+		weights = map[models.Difficulty]float64{models.Medium: 1}
+	}
+	positive := false
+	for d, w := range weights {
+		if d != models.Easy && d != models.Medium && d != models.Hard {
+			return nil, fmt.Errorf("不支持的难度: %s", d)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("难度 %s 的权重不能为负数", d)
+		}
+		positive = positive || w > 0
+	}
+	if !positive {
+		return nil, fmt.Errorf("难度分布的权重之和必须大于0")
+	}
+
+	topics := req.Topics
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+	if total*len(topics) > maxBatchQuestions {
+		return nil, fmt.Errorf("一次最多生成%d道题目，当前请求%d道", maxBatchQuestions, total*len(topics))
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("题目总数不能为0")
+	}
+
+	var cells []*batchCell
+	for _, topic := range topics {
+		for _, t := range types {
+			counts := splitByWeights(req.Types[t], weights)
+			for _, d := range batchDifficulties {
+				for remaining := counts[d]; remaining > 0; remaining -= batchCellSize {
+					n := remaining
+					if n > batchCellSize {
+						n = batchCellSize
+					}
+					cells = append(cells, &batchCell{
+						spec: AIGenerateRequest{
+							Type:       t,
+							Count:      n,
+							Difficulty: d,
+							Language:   req.Language,
+							Topic:      topic,
+							Verify:     req.Verify,
+						},
+						report: BatchCellReport{Topic: topic, Type: t, Difficulty: d, Requested: n, Warnings: []string{}},
+					})
+				}
+			}
+		}
+	}
+	return cells, nil
+}
+
+// dedupeBatchQuestions 按单元格顺序去重：与批次内已保留的题目或题库中的题目相似的都丢弃
+func dedupeBatchQuestions(cells []*batchCell) {
+	var kept []questionFingerprint
+	for _, cell := range cells {
+		flagDuplicateQuestions(cell.questions)
+
+		unique := make([]GeneratedQuestion, 0, len(cell.questions))
+		for i, q := range cell.questions {
+			if len(q.Duplicates) > 0 {
+				cell.report.Duplicates++
+				cell.report.Warnings = append(cell.report.Warnings, fmt.Sprintf("第%d题与题库中的题目 #%d 重复（相似度 %.2f），已丢弃", i+1, q.Duplicates[0].ID, q.Duplicates[0].Score))
+				continue
+			}
+			fp := requestFingerprint(q.QuestionRequest)
+			duplicated := false
+			for _, other := range kept {
+				if other.Hash == fp.Hash || estimateSimilarity(fp.Signature, other.Signature) >= similarityThreshold {
+					duplicated = true
+					break
+				}
+			}
+			if duplicated {
+				cell.report.Duplicates++
+				cell.report.Warnings = append(cell.report.Warnings, fmt.Sprintf("第%d题与本批次中的其他题目重复，已丢弃", i+1))
+				continue
+			}
+			kept = append(kept, fp)
+			unique = append(unique, q)
+		}
+		cell.questions = unique
+		cell.report.Kept = len(unique)
+	}
+}
+
+// 5.7 矩阵批量生成：展开为子请求并发执行，去重后返回或保存，附带每个单元格的报告
+func batchGenerateQuestions(c *gin.Context) {
+	var req BatchGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	cells, err := expandBatchRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.BankID != nil {
+		if _, status, err := lookupBank(*req.BankID, requestUser(c), true); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		req.Save = true
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	} else if concurrency > maxBatchConcurrency {
+		concurrency = maxBatchConcurrency
+	}
+
+	ctx := c.Request.Context()
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, cell := range cells {
+		wg.Add(1)
+		go func(cell *batchCell) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// 一个批次最多有 200 道题，中间件只在开始时检查一次预算，每个子请求调用模型前都要重新检查
+			if reason, _, ok := checkAIBudget(aiCallerFromContext(ctx)); !ok {
+				cell.report.Error = reason
+				return
+			}
+
+			prompt, template, err := resolvePrompt(cell.spec)
+			if err != nil {
+				cell.report.Error = err.Error()
+				return
+			}
+			cell.report.Template = template
+
+			generation, err := generateWithRepair(ctx, cell.spec, prompt)
+			if err != nil {
+				log.Printf("Batch cell %s/%s/%s error: %v", cell.spec.Topic, cell.spec.Type, cell.spec.Difficulty, err)
+				cell.report.Error = "AI服务调用失败: " + err.Error()
+				return
+			}
+			questions, warnings := finalizeGeneratedQuestions(generation.Questions, generation.Invalid, cell.spec, generation.ParseErr, false)
+			setPromptRef(questions, template)
+			setGenerationSource(questions, generation.Result.Model, cell.spec.Topic, nil)
+			cell.questions = questions
+			cell.report.Generated = len(questions)
+			cell.report.Warnings = append(cell.report.Warnings, generation.Notes...)
+			cell.report.Warnings = append(cell.report.Warnings, warnings...)
+		}(cell)
+	}
+	wg.Wait()
+
+	// 去重依赖单元格顺序，必须在全部子请求完成后进行
+	dedupeBatchQuestions(cells)
+	for _, cell := range cells {
+		if cell.spec.verify() && len(cell.questions) > 0 {
+			cell.report.Warnings = append(cell.report.Warnings, verifyGeneratedQuestions(ctx, cell.questions)...)
+		}
+	}
+
+	generated := []GeneratedQuestion{}
+	reports := make([]BatchCellReport, 0, len(cells))
+	var summary BatchSummary
+	for _, cell := range cells {
+		generated = append(generated, cell.questions...)
+		summary.Requested += cell.report.Requested
+		summary.Kept += cell.report.Kept
+		summary.Duplicates += cell.report.Duplicates
+		if cell.report.Error != "" {
+			summary.FailedCells++
+		}
+	}
+
+	if summary.FailedCells == len(cells) {
+		for _, cell := range cells {
+			reports = append(reports, cell.report)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "所有子请求都失败了", "cells": reports, "summary": summary})
+		return
+	}
+
+	if !req.Save {
+		for _, cell := range cells {
+			reports = append(reports, cell.report)
+		}
+		c.JSON(http.StatusOK, gin.H{"data": generated, "cells": reports, "summary": summary})
+		return
+	}
+
+	// 重复题目已在去重时丢弃，这里保存的就是全部保留的题目
+	saved, _, err := saveGeneratedQuestions(generated, req.BankID, requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	for _, cell := range cells {
+		cell.report.Saved = cell.report.Kept
+		reports = append(reports, cell.report)
+	}
+	summary.Saved = len(saved)
+	log.Printf("Batch generation saved %d questions from %d cells", len(saved), len(cells))
+	c.JSON(http.StatusCreated, gin.H{"data": saved, "cells": reports, "summary": summary})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSplitByWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		weights map[models.Difficulty]float64
+		want    [3]int // easy、medium、hard
+	}{
+		{"exact", 10, map[models.Difficulty]float64{models.Easy: 0.3, models.Medium: 0.5, models.Hard: 0.2}, [3]int{3, 5, 2}},
+		// 余数最大的难度先分到剩下的题目
+		{"largest remainder", 5, map[models.Difficulty]float64{models.Easy: 2, models.Medium: 1}, [3]int{3, 2, 0}},
+		{"largest remainder wins over order", 10, map[models.Difficulty]float64{models.Easy: 0.42, models.Medium: 0.31, models.Hard: 0.27}, [3]int{4, 3, 3}},
+		{"equal remainders keep order", 10, map[models.Difficulty]float64{models.Easy: 1, models.Medium: 1, models.Hard: 1}, [3]int{4, 3, 3}},
+		{"equal remainders two", 7, map[models.Difficulty]float64{models.Medium: 1, models.Hard: 1}, [3]int{0, 4, 3}},
+		{"zero weight", 3, map[models.Difficulty]float64{models.Easy: 0, models.Hard: 1}, [3]int{0, 0, 3}},
+		{"fewer than difficulties", 1, map[models.Difficulty]float64{models.Easy: 1, models.Medium: 1, models.Hard: 1}, [3]int{1, 0, 0}},
+	}
+	for _, tt := range tests {
+		counts := splitByWeights(tt.total, tt.weights)
+		got := [3]int{counts[models.Easy], counts[models.Medium], counts[models.Hard]}
+		if got != tt.want {
+			t.Errorf("%s: counts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBatchRequest(t *testing.T) {
+	cells, err := expandBatchRequest(BatchGenerateRequest{
+		Language:   "Go",
+		Topics:     []string{"切片", "并发"},
+		Types:      map[models.QuestionType]int{models.SingleChoice: 45, models.TrueFalse: 2},
+		Difficulty: map[models.Difficulty]float64{models.Easy: 0.1, models.Hard: 0.9},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个主题下：单选题 easy 5、hard 40 拆成 20 + 20，判断题 hard 2（easy 分到 0.2 道，不生成）
+	var got []string
+	total := 0
+	for _, cell := range cells {
+		got = append(got, cell.spec.Topic+"/"+string(cell.spec.Type)+"/"+string(cell.spec.Difficulty)+"/"+fmt.Sprint(cell.spec.Count))
+		total += cell.spec.Count
+		if cell.report.Requested != cell.spec.Count || cell.spec.Language != "Go" {
+			t.Errorf("cell %+v: report = %+v", cell.spec, cell.report)
+		}
+	}
+	want := []string{
+		"切片/single_choice/easy/5", "切片/single_choice/hard/20", "切片/single_choice/hard/20", "切片/true_false/hard/2",
+		"并发/single_choice/easy/5", "并发/single_choice/hard/20", "并发/single_choice/hard/20", "并发/true_false/hard/2",
+	}
+	if !equalStrings(got, want) || total != 94 {
+		t.Errorf("cells = %v, want %v", got, want)
+	}
+
+	invalid := []struct {
+		name string
+		req  BatchGenerateRequest
+		want string
+	}{
+		{"too many", BatchGenerateRequest{Topics: []string{"a", "b", "c"}, Types: map[models.QuestionType]int{models.SingleChoice: 67}}, "一次最多生成200道题目"},
+		{"unknown type", BatchGenerateRequest{Types: map[models.QuestionType]int{"essay": 1}}, "不支持的题型"},
+		{"negative count", BatchGenerateRequest{Types: map[models.QuestionType]int{models.SingleChoice: -1}}, "不能为负数"},
+		{"zero weights", BatchGenerateRequest{Types: map[models.QuestionType]int{models.SingleChoice: 1}, Difficulty: map[models.Difficulty]float64{models.Easy: 0}}, "权重之和必须大于0"},
+		{"zero total", BatchGenerateRequest{Types: map[models.QuestionType]int{models.SingleChoice: 0}}, "题目总数不能为0"},
+	}
+	for _, tt := range invalid {
+		if _, err := expandBatchRequest(tt.req); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDedupeBatchQuestions(t *testing.T) {
+	resetData(t)
+	existing := createQuestion(t, "", choiceQuestion("map 的零值是什么"))
+
+	question := func(content string) GeneratedQuestion {
+		return GeneratedQuestion{QuestionRequest: QuestionRequest{
+			Type: models.SingleChoice, Content: content, Difficulty: models.Easy, Language: "Go", Answer: "A",
+			Options: models.JSON{"A": content + " 选项一", "B": content + " 选项二", "C": content + " 选项三", "D": content + " 选项四"},
+		}}
+	}
+	cells := []*batchCell{
+		{questions: []GeneratedQuestion{question("slice 的零值是什么"), question("channel 的零值是什么")}},
+		// 与前一个单元格的题目重复、与题库中的题目重复
+		{questions: []GeneratedQuestion{question("slice 的零值是什么"), question("map 的零值是什么"), question("interface 的零值是什么")}},
+	}
+	dedupeBatchQuestions(cells)
+
+	if r := cells[0].report; r.Kept != 2 || r.Duplicates != 0 {
+		t.Errorf("first cell: report = %+v", r)
+	}
+	r := cells[1].report
+	if r.Kept != 1 || r.Duplicates != 2 || len(cells[1].questions) != 1 || cells[1].questions[0].Content != "interface 的零值是什么" {
+		t.Fatalf("second cell: report = %+v, questions = %+v", r, cells[1].questions)
+	}
+	want := []string{"第1题与本批次中的其他题目重复，已丢弃", "第2题与题库中的题目 #" + fmt.Sprint(existing.ID) + " 重复"}
+	for i, w := range want {
+		if !strings.HasPrefix(r.Warnings[i], w) {
+			t.Errorf("warning %d = %q, want %q", i, r.Warnings[i], w)
+		}
+	}
+}
+
+func TestBatchChecksBudgetPerCell(t *testing.T) {
+	resetData(t)
+	useFixture(t, "valid_choice")
+	useBudget(t, AIUsageConfig{DailyTokenBudget: 1})
+
+	var resp struct {
+		Cells   []BatchCellReport `json:"cells"`
+		Summary BatchSummary      `json:"summary"`
+	}
+	body := gin.H{"language": "Go", "types": gin.H{"single_choice": 3}, "difficulty": gin.H{"easy": 1, "medium": 1, "hard": 1},
+		"concurrency": 1, "verify": false}
+	if code := apiRequest(t, http.MethodPost, "/api/ai/batch", "", body, &resp); code != http.StatusOK {
+		t.Fatalf("batch: status = %d", code)
+	}
+
+	// 第一个子请求用完预算后，其余子请求不再调用模型
+	if resp.Summary.FailedCells != 2 || resp.Summary.Kept != 1 || len(testStub.Requests()) != 1 {
+		t.Errorf("summary = %+v, model called %d times", resp.Summary, len(testStub.Requests()))
+	}
+	for _, cell := range resp.Cells {
+		if cell.Error != "" && !strings.Contains(cell.Error, "超出预算 1") {
+			t.Errorf("cell error = %q", cell.Error)
+		}
+	}
+
+	addUsage(t, "", 1, 0, time.Now())
+	if code := apiRequest(t, http.MethodPost, "/api/ai/batch", "", body, nil); code != http.StatusTooManyRequests {
+		t.Errorf("batch over budget: status = %d, want 429", code)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-server/aistub"
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+)
+
+// 端到端测试：通过 httptest 调用 /api/ai/generate，模型调用由 aistub 回放 testdata/ai 中录制的响应
+
+var (
+	testStub    *aistub.Server
+	testStubURL string
+	testRouter  *gin.Engine
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+
+	testStub = aistub.New("testdata/ai")
+	stubServer := httptest.NewServer(testStub)
+	testStubURL = stubServer.URL
+
+	if err := initDatabase("file::memory:?cache=shared"); err != nil {
+		panic(err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	testRouter = setupRouter()
+
+	code := m.Run()
+	stubServer.Close()
+	os.Exit(code)
+}
+
+// useFixture 让模型调用回放指定的 fixture，并清空题库和请求记录
+func useFixture(t *testing.T, name string) {
+	t.Helper()
+	testStub.Reset()
+	for _, table := range []string{"questions", "question_fingerprints", "question_similarity_bands"} {
+		if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aiConfig = AIConfig{
+		Provider:         ProviderOpenAI,
+		BaseURL:          testStubURL + "/v1/",
+		APIKey:           "test",
+		Model:            name,
+		MaxTokens:        2000,
+		Timeout:          5 * time.Second,
+		StructuredOutput: StructuredOutputNone,
+		RepairAttempts:   1,
+	}
+	var err error
+	if aiGenerator, err = newQuestionGenerator(aiConfig); err != nil {
+		t.Fatal(err)
+	}
+	aiVerifier = aiGenerator
+}
+
+type generateResponse struct {
+	Data     []GeneratedQuestion `json:"data"`
+	Warnings []string            `json:"warnings"`
+	Invalid  []InvalidQuestion   `json:"invalid"`
+	Error    string              `json:"error"`
+}
+
+func postJSON(t *testing.T, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+// apiRequest 以 user 的身份调用接口，user 为空时不带 X-User 请求头，body 为 nil 时不发送请求体
+func apiRequest(t *testing.T, method, path, user string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// resetData 清空题目、标签、题库等所有业务数据
+func resetData(t *testing.T) {
+	t.Helper()
+	for _, table := range []string{
+		"questions", "question_fingerprints", "question_similarity_bands", "question_explanations",
+		"question_verifications", "classification_suggestions", "question_translations", "question_tags",
+		"tags", "question_banks", "question_bank_items", "question_revisions", "question_reviews",
+	} {
+		if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// createQuestion 以 user 的身份新建题目，失败时终止测试
+func createQuestion(t *testing.T, user string, body gin.H) models.Question {
+	t.Helper()
+	var resp struct {
+		Data  models.Question `json:"data"`
+		Error string          `json:"error"`
+	}
+	if code := apiRequest(t, http.MethodPost, "/api/questions", user, body, &resp); code != http.StatusCreated {
+		t.Fatalf("create question: status = %d, error = %s", code, resp.Error)
+	}
+	return resp.Data
+}
+
+// choiceQuestion 内容和选项互不相同的单选题，避免被当作重复题目
+func choiceQuestion(content string) gin.H {
+	return gin.H{
+		"type":       "single_choice",
+		"content":    content,
+		"options":    gin.H{"A": content + " 选项一", "B": content + " 选项二", "C": content + " 选项三", "D": content + " 选项四"},
+		"answer":     "A",
+		"difficulty": "easy",
+		"language":   "Go",
+	}
+}
+
+func generate(t *testing.T, body gin.H) (int, generateResponse) {
+	t.Helper()
+	var resp generateResponse
+	code := postJSON(t, "/api/ai/generate", body, &resp)
+	return code, resp
+}
+
+func choiceRequest(count int) gin.H {
+	return gin.H{"type": "single_choice", "count": count, "difficulty": "easy", "language": "Go"}
+}
+
+func containsWarning(warnings []string, substr string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGenerateValidResponse(t *testing.T) {
+	useFixture(t, "valid_choice")
+	code, resp := generate(t, choiceRequest(2))
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", code, resp.Error)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("got %d questions, want 2", len(resp.Data))
+	}
+	q := resp.Data[0]
+	if q.Provenance != ProvenanceAI || q.Source != models.SourceAI || q.Model != "valid_choice" {
+		t.Errorf("provenance = %q, source = %q, model = %q", q.Provenance, q.Source, q.Model)
+	}
+	if q.Answer != "A" || q.Options["A"] != "go" || q.Difficulty != models.Easy {
+		t.Errorf("unexpected question: %+v", q.QuestionRequest)
+	}
+	if len(resp.Invalid) != 0 || len(resp.Warnings) != 0 {
+		t.Errorf("invalid = %v, warnings = %v", resp.Invalid, resp.Warnings)
+	}
+
+	requests := testStub.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("stub got %d requests, want 1", len(requests))
+	}
+	if prompt := requests[0].Messages[0].Content; !strings.Contains(prompt, "single_choice") || !strings.Contains(prompt, "Go") {
+		t.Errorf("prompt does not describe the request: %s", prompt)
+	}
+}
+
+func TestGenerateRepairsLenientJSON(t *testing.T) {
+	useFixture(t, "fenced_json5")
+	code, resp := generate(t, choiceRequest(1))
+	if code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("status = %d, data = %v, error = %s", code, resp.Data, resp.Error)
+	}
+	q := resp.Data[0]
+	if q.Provenance != ProvenanceRepaired {
+		t.Errorf("provenance = %q, want %q", q.Provenance, ProvenanceRepaired)
+	}
+	if q.Content != "Go 中哪个关键字用于启动协程？" || q.Options["D"] != "select" {
+		t.Errorf("unexpected question: %+v", q.QuestionRequest)
+	}
+	if !containsWarning(resp.Warnings, "格式修复") {
+		t.Errorf("missing repaired warning: %v", resp.Warnings)
+	}
+}
+
+func TestGenerateAsksModelToRepair(t *testing.T) {
+	useFixture(t, "repair_then_valid")
+	code, resp := generate(t, choiceRequest(1))
+	if code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("status = %d, data = %v, error = %s", code, resp.Data, resp.Error)
+	}
+	if resp.Data[0].Provenance != ProvenanceRepaired {
+		t.Errorf("provenance = %q, want %q", resp.Data[0].Provenance, ProvenanceRepaired)
+	}
+	if !containsWarning(resp.Warnings, "第1次请求模型修正输出") {
+		t.Errorf("missing repair note: %v", resp.Warnings)
+	}
+
+	requests := testStub.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("stub got %d requests, want 2", len(requests))
+	}
+	// 修正请求带上了模型上一次的输出和错误原因
+	repair := requests[1].Messages
+	if len(repair) != 3 || repair[1].Role != "assistant" || !strings.Contains(repair[1].Content, "抱歉") {
+		t.Fatalf("unexpected repair conversation: %+v", repair)
+	}
+	if !strings.Contains(repair[2].Content, "不是合法的JSON") {
+		t.Errorf("repair prompt does not explain the problem: %s", repair[2].Content)
+	}
+}
+
+func TestRepairReplacesPreviousAttempt(t *testing.T) {
+	useFixture(t, "garbage")
+	aiConfig.RepairAttempts = 3
+	body := choiceRequest(1)
+	body["fill_placeholders"] = false
+	generate(t, body)
+
+	requests := testStub.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("stub got %d requests, want 4", len(requests))
+	}
+	// 每次修正请求都是原始提示词加上一次的输出和错误原因，对话不会越来越长
+	for i, r := range requests[1:] {
+		if len(r.Messages) != 3 || r.Messages[1].Role != "assistant" || r.Messages[2].Role != "user" {
+			t.Errorf("repair %d: %d messages, want 3", i+1, len(r.Messages))
+		}
+	}
+}
+
+func TestGenerateReportsInvalidQuestions(t *testing.T) {
+	useFixture(t, "invalid_fields")
+	body := choiceRequest(2)
+	body["fill_placeholders"] = false
+	code, resp := generate(t, body)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", code, resp.Error)
+	}
+	if len(resp.Data) != 1 {
+		t.Errorf("got %d valid questions, want 1", len(resp.Data))
+	}
+	if len(resp.Invalid) != 1 || resp.Invalid[0].Index != 1 || len(resp.Invalid[0].Errors) == 0 {
+		t.Fatalf("unexpected invalid list: %+v", resp.Invalid)
+	}
+	if !containsWarning(resp.Warnings, "第2题未通过校验") {
+		t.Errorf("missing validation warning: %v", resp.Warnings)
+	}
+}
+
+func TestGenerateFillsPlaceholders(t *testing.T) {
+	useFixture(t, "short_count")
+	code, resp := generate(t, choiceRequest(3))
+	if code != http.StatusOK || len(resp.Data) != 3 {
+		t.Fatalf("status = %d, got %d questions, error = %s", code, len(resp.Data), resp.Error)
+	}
+	if resp.Data[0].Provenance == ProvenancePlaceholder {
+		t.Errorf("first question should come from the model")
+	}
+	for _, q := range resp.Data[1:] {
+		if q.Provenance != ProvenancePlaceholder || !isPlaceholderContent(q.Content) {
+			t.Errorf("expected placeholder, got %+v", q)
+		}
+	}
+}
+
+func TestGenerateWithoutPlaceholders(t *testing.T) {
+	useFixture(t, "short_count")
+	body := choiceRequest(3)
+	body["fill_placeholders"] = false
+	code, resp := generate(t, body)
+	if code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("status = %d, got %d questions, error = %s", code, len(resp.Data), resp.Error)
+	}
+	if !containsWarning(resp.Warnings, "AI只生成了1道题目") {
+		t.Errorf("missing shortage warning: %v", resp.Warnings)
+	}
+}
+
+func TestGenerateUnparsableOutput(t *testing.T) {
+	useFixture(t, "garbage")
+	body := choiceRequest(1)
+	body["fill_placeholders"] = false
+	code, resp := generate(t, body)
+	if code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", code, http.StatusBadGateway)
+	}
+	if !containsWarning(resp.Warnings, "AI响应解析失败") || !containsWarning(resp.Warnings, "修正后的输出仍然无法使用") {
+		t.Errorf("unexpected warnings: %v", resp.Warnings)
+	}
+}
+
+func TestGenerateTruncatedOutput(t *testing.T) {
+	useFixture(t, "truncated")
+	body := choiceRequest(2)
+	body["fill_placeholders"] = false
+	code, resp := generate(t, body)
+	if code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", code, http.StatusBadGateway)
+	}
+	if !containsWarning(resp.Warnings, "字符串未闭合") {
+		t.Errorf("warnings should explain the truncation: %v", resp.Warnings)
+	}
+}
+
+func TestGenerateWrappedObject(t *testing.T) {
+	useFixture(t, "wrapped_object")
+	code, resp := generate(t, gin.H{"type": "multiple_choice", "count": 1, "difficulty": "medium", "language": "Go"})
+	if code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("status = %d, data = %v, error = %s", code, resp.Data, resp.Error)
+	}
+	if resp.Data[0].Answer != "A,B" {
+		t.Errorf("answer = %q, want %q", resp.Data[0].Answer, "A,B")
+	}
+}
+
+func TestGenerateUpstreamError(t *testing.T) {
+	useFixture(t, "upstream_error")
+	code, resp := generate(t, choiceRequest(1))
+	if code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(resp.Error, "500") {
+		t.Errorf("error should mention upstream status: %s", resp.Error)
+	}
+	if n := len(testStub.Requests()); n != 1 {
+		t.Errorf("stub got %d requests, want 1 (retries disabled)", n)
+	}
+}
+
+func TestGenerateNoChoices(t *testing.T) {
+	useFixture(t, "no_choices")
+	code, resp := generate(t, choiceRequest(1))
+	if code != http.StatusInternalServerError || !strings.Contains(resp.Error, "AI未返回任何内容") {
+		t.Fatalf("status = %d, error = %s", code, resp.Error)
+	}
+}
+
+func TestGenerateSave(t *testing.T) {
+	useFixture(t, "valid_choice")
+	body := choiceRequest(2)
+	body["save"] = true
+	body["topic"] = "并发"
+
+	var saved struct {
+		Data     []models.Question `json:"data"`
+		Warnings []string          `json:"warnings"`
+	}
+	if code := postJSON(t, "/api/ai/generate", body, &saved); code != http.StatusCreated {
+		t.Fatalf("status = %d", code)
+	}
+	if len(saved.Data) != 2 {
+		t.Fatalf("saved %d questions, want 2", len(saved.Data))
+	}
+	var stored models.Question
+	if err := db.First(&stored, saved.Data[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Source != models.SourceAI || stored.Model != "valid_choice" || stored.Topic != "并发" {
+		t.Errorf("provenance not stored: source = %q, model = %q, topic = %q", stored.Source, stored.Model, stored.Topic)
+	}
+
+	// 再次生成相同的题目时，完全重复的题目不会保存
+	testStub.Reset()
+	if code := postJSON(t, "/api/ai/generate", body, &saved); code != http.StatusCreated {
+		t.Fatalf("status = %d", code)
+	}
+	if len(saved.Data) != 0 || !containsWarning(saved.Warnings, "完全相同，未保存") {
+		t.Errorf("duplicates should be skipped: data = %v, warnings = %v", saved.Data, saved.Warnings)
+	}
+}
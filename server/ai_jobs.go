@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	aiJobQueue = make(chan uint, 100)
+
+	// 正在执行的任务的取消函数
+	aiJobCancels   = make(map[uint]context.CancelFunc)
+	aiJobCancelsMu sync.Mutex
+)
+
+// startAIJobWorkers 启动任务执行协程，数量由 AI_JOB_WORKERS 配置，默认 2
+func startAIJobWorkers() {
+	workers := 2
+	if v := os.Getenv("AI_JOB_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			workers = n
+		} else {
+			log.Printf("Warning: invalid AI_JOB_WORKERS %q, using %d", v, workers)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range aiJobQueue {
+				runAIJob(id)
+			}
+		}()
+	}
+
+	recoverAIJobs()
+}
+
+// recoverAIJobs 服务重启后，把上次未完成的任务重新放回队列
+func recoverAIJobs() {
+	db.Model(&models.AIJob{}).
+		Where("status = ?", models.JobRunning).
+		Updates(map[string]interface{}{"status": models.JobPending, "progress": 0})
+
+	var ids []uint
+	db.Model(&models.AIJob{}).Where("status = ?", models.JobPending).Order("id").Pluck("id", &ids)
+	for _, id := range ids {
+		enqueueAIJob(id)
+	}
+	if len(ids) > 0 {
+		log.Printf("Recovered %d pending AI jobs", len(ids))
+	}
+}
+
+func enqueueAIJob(id uint) {
+	// 队列满时不阻塞请求，由后台协程等待
+	select {
+	case aiJobQueue <- id:
+	default:
+		go func() { aiJobQueue <- id }()
+	}
+}
+
+// decodeJobRequest 还原任务保存的生成参数
+func decodeJobRequest(job *models.AIJob) (AIGenerateRequest, error) {
+	var req AIGenerateRequest
+	b, err := json.Marshal(job.Request)
+	if err != nil {
+		return req, err
+	}
+	err = json.Unmarshal(b, &req)
+	return req, err
+}
+
+// toJSONArray 将任意切片转换为 models.JSONArray
+func toJSONArray(v interface{}) models.JSONArray {
+	arr := models.JSONArray{}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return arr
+	}
+	if err := json.Unmarshal(b, &arr); err != nil || arr == nil {
+		return models.JSONArray{}
+	}
+	return arr
+}
+
+// runAIJob 执行单个任务，只会领取 pending 状态的任务
+func runAIJob(id uint) {
+	now := time.Now()
+	claim := db.Model(&models.AIJob{}).
+		Where("id = ? AND status = ?", id, models.JobPending).
+		Updates(map[string]interface{}{"status": models.JobRunning, "started_at": &now})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	var job models.AIJob
+	if err := db.First(&job, id).Error; err != nil {
+		log.Printf("AI job %d disappeared: %v", id, err)
+		return
+	}
+
+	caller := AICaller{Endpoint: "job", User: job.User, APIKey: job.APIKey, JobID: &id}
+	ctx, cancel := context.WithCancel(withAICaller(context.Background(), caller))
+	aiJobCancelsMu.Lock()
+	aiJobCancels[id] = cancel
+	aiJobCancelsMu.Unlock()
+	defer func() {
+		aiJobCancelsMu.Lock()
+		delete(aiJobCancels, id)
+		aiJobCancelsMu.Unlock()
+		cancel()
+	}()
+
+	req, err := decodeJobRequest(&job)
+	if err != nil {
+		finishAIJob(id, map[string]interface{}{"status": models.JobFailed, "error": "任务参数无效: " + err.Error()})
+		return
+	}
+
+	// 按已经完整输出的题目数量估算进度
+	parser := &questionStreamParser{}
+	seen := 0
+	result, err := streamGenerate(ctx, aiGenerator, newGenerationRequest(req, userPrompt(job.Prompt)), func(delta string) error {
+		if n := len(parser.Feed(delta)); n > 0 && seen < req.Count {
+			seen += n
+			if seen > req.Count {
+				seen = req.Count
+			}
+			// 解析结束前最多到 99
+			db.Model(&models.AIJob{}).Where("id = ?", id).Update("progress", seen*99/req.Count)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("AI job %d cancelled", id)
+			return
+		}
+		log.Printf("AI job %d failed: %v", id, err)
+		finishAIJob(id, map[string]interface{}{"status": models.JobFailed, "error": err.Error()})
+		return
+	}
+
+	// 任务只记录模型真实返回的内容，不补充占位题目
+	generation := repairGeneratedQuestions(ctx, req, userPrompt(job.Prompt), result)
+	result = generation.Result
+	valid, parseErrors := finalizeGeneratedQuestions(generation.Questions, generation.Invalid, req, generation.ParseErr, false)
+	parseErrors = append(generation.Notes, parseErrors...)
+	parseErrors = append(parseErrors, flagDuplicateQuestions(valid)...)
+	setGenerationSource(valid, result.Model, req.Topic, &id)
+	if job.TemplateID != nil {
+		setPromptRef(valid, &PromptRef{TemplateID: *job.TemplateID, Version: job.TemplateVersion})
+	}
+	if req.verify() {
+		parseErrors = append(parseErrors, verifyGeneratedQuestions(ctx, valid)...)
+	}
+
+	finishAIJob(id, map[string]interface{}{
+		"status":       models.JobSucceeded,
+		"progress":     100,
+		"model":        result.Model,
+		"raw_output":   result.Content,
+		"questions":    toJSONArray(valid),
+		"parse_errors": toJSONArray(parseErrors),
+	})
+	log.Printf("AI job %d finished with %d questions", id, len(valid))
+}
+
+// finishAIJob 写入任务结果；任务已被取消时不覆盖
+func finishAIJob(id uint, fields map[string]interface{}) {
+	now := time.Now()
+	fields["finished_at"] = &now
+	if err := db.Model(&models.AIJob{}).
+		Where("id = ? AND status = ?", id, models.JobRunning).
+		Updates(fields).Error; err != nil {
+		log.Printf("Failed to save AI job %d: %v", id, err)
+	}
+}
+
+// 5.2 创建AI生成任务，任务结果中的题目需要调用导入接口保存
+func createAIJob(c *gin.Context) {
+	req, ok := bindAIGenerateRequest(c)
+	if !ok || rejectSaveOptions(c, req) {
+		return
+	}
+
+	prompt, template, err := resolvePrompt(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	caller := aiCallerFromContext(c.Request.Context())
+
+	var reqMap models.JSON
+	b, _ := json.Marshal(req)
+	json.Unmarshal(b, &reqMap)
+
+	job := models.AIJob{
+		Status:      models.JobPending,
+		Request:     reqMap,
+		Prompt:      prompt,
+		User:        caller.User,
+		APIKey:      caller.APIKey,
+		Questions:   models.JSONArray{},
+		ParseErrors: models.JSONArray{},
+	}
+	if template != nil {
+		job.TemplateID = &template.TemplateID
+		job.TemplateVersion = template.Version
+	}
+	if err := db.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	enqueueAIJob(job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
+
+// 5.3 任务列表
+func getAIJobs(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+	offset := (pagination.Page - 1) * pagination.PageSize
+
+	// 列表不返回原始输出，避免响应过大
+	query := db.Model(&models.AIJob{}).Omit("raw_output", "prompt")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var jobs []models.AIJob
+	if err := query.Order("id DESC").Offset(offset).Limit(pagination.PageSize).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  jobs,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
+
+// 5.4 任务详情
+func getAIJob(c *gin.Context) {
+	var job models.AIJob
+	if err := db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// 5.5 取消任务
+func cancelAIJob(c *gin.Context) {
+	var job models.AIJob
+	if err := db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if job.Finished() {
+		c.JSON(http.StatusConflict, gin.H{"error": "任务已结束，无法取消"})
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(&models.AIJob{}).
+		Where("id = ? AND status IN ?", job.ID, []models.JobStatus{models.JobPending, models.JobRunning}).
+		Updates(map[string]interface{}{"status": models.JobCancelled, "finished_at": &now}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	aiJobCancelsMu.Lock()
+	if cancel, ok := aiJobCancels[job.ID]; ok {
+		cancel()
+	}
+	aiJobCancelsMu.Unlock()
+
+	db.First(&job, job.ID)
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var startWorkersOnce sync.Once
+
+// startTestWorkers 启动两个任务执行协程，所有测试共用
+func startTestWorkers(t *testing.T) {
+	t.Helper()
+	t.Setenv("AI_JOB_WORKERS", "2")
+	startWorkersOnce.Do(startAIJobWorkers)
+}
+
+type jobResponse struct {
+	Data  models.AIJob `json:"data"`
+	Error string       `json:"error"`
+}
+
+// waitForJob 轮询任务详情，直到任务结束
+func waitForJob(t *testing.T, id uint) models.AIJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var resp jobResponse
+		apiRequest(t, http.MethodGet, fmt.Sprintf("/api/ai/jobs/%d", id), "", nil, &resp)
+		if resp.Data.Finished() {
+			return resp.Data
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish", id)
+	return models.AIJob{}
+}
+
+// insertJob 直接写入一个任务，不放入队列
+func insertJob(t *testing.T, status models.JobStatus) models.AIJob {
+	t.Helper()
+	request := models.JSON{"type": "single_choice", "count": 2, "difficulty": "easy", "language": "Go", "verify": false}
+	job := models.AIJob{Status: status, Request: request, Prompt: "请生成2道单选题", Questions: models.JSONArray{}, ParseErrors: models.JSONArray{}}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestAIJobRejectsSave(t *testing.T) {
+	useFixture(t, "valid_choice")
+	for name, change := range map[string]func(gin.H){
+		"save":    func(b gin.H) { b["save"] = true },
+		"bank_id": func(b gin.H) { b["bank_id"] = 1 },
+	} {
+		body := choiceRequest(2)
+		change(body)
+		var resp jobResponse
+		if code := apiRequest(t, http.MethodPost, "/api/ai/jobs", "", body, &resp); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, code)
+		}
+	}
+	if n := len(testStub.Requests()); n != 0 {
+		t.Errorf("rejected jobs called the model %d times", n)
+	}
+}
+
+func TestAIJobRunsInWorkerPool(t *testing.T) {
+	db.Exec("DELETE FROM ai_jobs")
+	useFixture(t, "valid_choice")
+	startTestWorkers(t)
+
+	body := choiceRequest(2)
+	body["verify"] = false
+	ids := make([]uint, 3)
+	for i := range ids {
+		var resp jobResponse
+		if code := apiRequest(t, http.MethodPost, "/api/ai/jobs", "alice", body, &resp); code != http.StatusAccepted {
+			t.Fatalf("create job: status = %d (%s)", code, resp.Error)
+		}
+		if resp.Data.Status != models.JobPending || resp.Data.User != "alice" {
+			t.Errorf("new job: status = %s, user = %q", resp.Data.Status, resp.Data.User)
+		}
+		ids[i] = resp.Data.ID
+	}
+
+	for _, id := range ids {
+		job := waitForJob(t, id)
+		if job.Status != models.JobSucceeded || job.Progress != 100 || len(job.Questions) != 2 || job.Model != "valid_choice" {
+			t.Errorf("job %d: status = %s, progress = %d, %d questions, model = %q (%s)",
+				id, job.Status, job.Progress, len(job.Questions), job.Model, job.Error)
+		}
+		if job.StartedAt == nil || job.FinishedAt == nil {
+			t.Errorf("job %d: started_at or finished_at missing", id)
+		}
+	}
+
+	var list struct {
+		Data  []models.AIJob `json:"data"`
+		Total int64          `json:"total"`
+	}
+	apiRequest(t, http.MethodGet, "/api/ai/jobs?status=succeeded", "", nil, &list)
+	if list.Total != 3 || list.Data[0].ID != ids[2] || list.Data[0].RawOutput != "" {
+		t.Errorf("listing: total = %d, first = %d, raw output returned = %v", list.Total, list.Data[0].ID, list.Data[0].RawOutput != "")
+	}
+}
+
+func TestRecoverAIJobs(t *testing.T) {
+	db.Exec("DELETE FROM ai_jobs")
+	useFixture(t, "valid_choice")
+	startTestWorkers(t)
+
+	// 服务重启前正在执行的任务重新执行
+	job := insertJob(t, models.JobRunning)
+	recoverAIJobs()
+	if got := waitForJob(t, job.ID); got.Status != models.JobSucceeded || len(got.Questions) != 2 {
+		t.Errorf("recovered job: status = %s, %d questions (%s)", got.Status, len(got.Questions), got.Error)
+	}
+}
+
+func TestCancelAIJob(t *testing.T) {
+	db.Exec("DELETE FROM ai_jobs")
+	useFixture(t, "valid_choice")
+	cancel := func(id uint) (int, jobResponse) {
+		var resp jobResponse
+		code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/ai/jobs/%d/cancel", id), "", nil, &resp)
+		return code, resp
+	}
+
+	// 排队中的任务取消后不再执行
+	pending := insertJob(t, models.JobPending)
+	if code, resp := cancel(pending.ID); code != http.StatusOK || resp.Data.Status != models.JobCancelled || resp.Data.FinishedAt == nil {
+		t.Fatalf("cancel pending: status = %d, job = %+v", code, resp.Data)
+	}
+	runAIJob(pending.ID)
+	if len(testStub.Requests()) != 0 {
+		t.Error("cancelled job called the model")
+	}
+	if code, _ := cancel(pending.ID); code != http.StatusConflict {
+		t.Errorf("cancel twice: status = %d, want 409", code)
+	}
+
+	// 执行中的任务取消时中断模型调用，之后写入的结果不覆盖取消状态
+	running := insertJob(t, models.JobRunning)
+	ctx, stop := context.WithCancel(context.Background())
+	aiJobCancelsMu.Lock()
+	aiJobCancels[running.ID] = stop
+	aiJobCancelsMu.Unlock()
+	defer func() {
+		aiJobCancelsMu.Lock()
+		delete(aiJobCancels, running.ID)
+		aiJobCancelsMu.Unlock()
+	}()
+	if code, _ := cancel(running.ID); code != http.StatusOK || ctx.Err() == nil {
+		t.Errorf("cancel running: status = %d, context cancelled = %v", code, ctx.Err() != nil)
+	}
+	finishAIJob(running.ID, map[string]interface{}{"status": models.JobSucceeded})
+	var job models.AIJob
+	db.First(&job, running.ID)
+	if job.Status != models.JobCancelled {
+		t.Errorf("after finish: status = %s, want cancelled", job.Status)
+	}
+
+	if code, _ := cancel(99999); code != http.StatusNotFound {
+		t.Errorf("cancel missing job: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"testing"
+
+	"homework-server/models"
+)
+
+func TestExtractJSONContent(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain array", `[{"a":1}]`, `[{"a":1}]`},
+		{"markdown fence", "```json\n[{\"a\":1}]\n```", `[{"a":1}]`},
+		{"leading and trailing text", "以下是题目：{\"a\":1} 希望有帮助", `{"a":1}`},
+		{"brackets inside strings", `[{"a":"]}"}] trailing`, `[{"a":"]}"}]`},
+		{"escaped quote", `[{"a":"\"]"}]x`, `[{"a":"\"]"}]`},
+		{"truncated", "```json\n[{\"a\":1},{\"b\":", "[{\"a\":1},{\"b\":"},
+		{"no json", "没有JSON", "没有JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractJSONContent(tt.in); got != tt.want {
+				t.Errorf("extractJSONContent(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanJSONContent(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"already valid", `[{"a":1}]`, `[{"a":1}]`},
+		{"trailing comma", `[{"a":1,},]`, `[{"a":1}]`},
+		{"single quotes and bare keys", `{a: 'x', b: true}`, `{"a":"x","b":true}`},
+		{"comments", "[\n// comment\n{\"a\":null}]", `[{"a":null}]`},
+		{"strings untouched", `{"a":"it's, ok // not a comment"}`, `{"a":"it's, ok // not a comment"}`},
+		{"unparsable returned as extracted", "```\n[{\"a\":\n```", `[{"a":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanJSONContent(tt.in); got != tt.want {
+				t.Errorf("cleanJSONContent(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMapToQuestion(t *testing.T) {
+	req := AIGenerateRequest{Type: models.SingleChoice, Count: 1, Difficulty: models.Medium, Language: "Go"}
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		want QuestionRequest
+	}{
+		{
+			name: "complete",
+			in: map[string]interface{}{
+				"type": "single_choice", "content": "题目", "answer": "B", "difficulty": "hard", "language": "Python",
+				"options": map[string]interface{}{"A": "1", "B": 2.0},
+			},
+			want: QuestionRequest{Type: models.SingleChoice, Content: "题目", Answer: "B", Difficulty: models.Hard, Language: "Python",
+				Options: models.JSON{"A": "1", "B": "2"}},
+		},
+		{
+			name: "chinese type and array answer",
+			in: map[string]interface{}{
+				"type": "多选题", "content": "题目", "answer": []interface{}{"A", "C"},
+				"options": `{"A":"x","C":"y"}`,
+			},
+			want: QuestionRequest{Type: models.MultipleChoice, Content: "题目", Answer: "A,C", Difficulty: models.Medium, Language: "Go",
+				Options: models.JSON{"A": "x", "C": "y"}},
+		},
+		{
+			name: "programming ignores options",
+			in:   map[string]interface{}{"type": "programming", "content": "写一个函数", "options": "", "answer": ""},
+			want: QuestionRequest{Type: models.Programming, Content: "写一个函数", Difficulty: models.Medium, Language: "Go"},
+		},
+		{
+			name: "numeric answer",
+			in:   map[string]interface{}{"type": "single_choice", "content": "1+1", "answer": 2.0, "options": map[string]interface{}{"A": "2"}},
+			want: QuestionRequest{Type: models.SingleChoice, Content: "1+1", Answer: "2", Difficulty: models.Medium, Language: "Go",
+				Options: models.JSON{"A": "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertMapToQuestion(tt.in, req)
+			if got.Type != tt.want.Type || got.Content != tt.want.Content || got.Answer != tt.want.Answer ||
+				got.Difficulty != tt.want.Difficulty || got.Language != tt.want.Language {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(got.Options) != len(tt.want.Options) {
+				t.Fatalf("options = %v, want %v", got.Options, tt.want.Options)
+			}
+			for k, v := range tt.want.Options {
+				if got.Options[k] != v {
+					t.Errorf("options[%s] = %v, want %v", k, got.Options[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGeneratedQuestions(t *testing.T) {
+	req := AIGenerateRequest{Type: models.SingleChoice, Count: 2, Difficulty: models.Easy, Language: "Go"}
+	valid := `{"type":"single_choice","content":"题目","options":{"A":"a","B":"b","C":"c","D":"d"},"answer":"a","difficulty":"easy","language":"Go"}`
+
+	t.Run("strict json", func(t *testing.T) {
+		questions, invalid, err := parseGeneratedQuestions("["+valid+"]", req)
+		if err != nil || len(questions) != 1 || len(invalid) != 0 {
+			t.Fatalf("questions = %v, invalid = %v, err = %v", questions, invalid, err)
+		}
+		if questions[0].Provenance != ProvenanceAI || questions[0].Answer != "A" {
+			t.Errorf("got %+v", questions[0])
+		}
+	})
+
+	t.Run("lenient json is marked repaired", func(t *testing.T) {
+		questions, _, err := parseGeneratedQuestions("```json\n["+valid+",]\n```", req)
+		if err != nil || len(questions) != 1 || questions[0].Provenance != ProvenanceRepaired {
+			t.Fatalf("questions = %v, err = %v", questions, err)
+		}
+	})
+
+	t.Run("single object", func(t *testing.T) {
+		questions, _, err := parseGeneratedQuestions(valid, req)
+		if err != nil || len(questions) != 1 {
+			t.Fatalf("questions = %v, err = %v", questions, err)
+		}
+	})
+
+	t.Run("invalid items keep their index", func(t *testing.T) {
+		_, invalid, err := parseGeneratedQuestions(`[`+valid+`, "text", {"type":"single_choice","content":"缺少答案"}]`, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(invalid) != 2 || invalid[0].Index != 1 || invalid[1].Index != 2 {
+			t.Fatalf("invalid = %+v", invalid)
+		}
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		if _, _, err := parseGeneratedQuestions("抱歉，我无法完成", req); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
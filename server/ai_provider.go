@@ -0,0 +1,858 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 支持的AI服务提供方
+const (
+	ProviderOpenAI = "openai" // OpenAI 兼容接口（SiliconFlow、DeepSeek、vLLM 等）
+	ProviderOllama = "ollama" // Ollama 本地模型服务
+	ProviderMock   = "mock"   // 确定性的本地桩，用于开发和测试
+)
+
+// 结构化输出方式
+const (
+	StructuredOutputNone       = "none"        // 只在提示词中描述格式
+	StructuredOutputJSONSchema = "json_schema" // response_format: json_schema（Ollama 为 format: schema）
+	StructuredOutputJSONObject = "json_object" // response_format: json_object（Ollama 为 format: json）
+	StructuredOutputTools      = "tools"       // 通过函数调用返回，参数即为结构化结果
+)
+
+// ChatMessage 对话消息
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// 模型调用的用途
+const (
+	TaskGenerate  = ""          // 出题
+	TaskExplain   = "explain"   // 为已有题目生成解析
+	TaskVerify    = "verify"    // 不看答案独立作答，用于校验答案
+	TaskClassify  = "classify"  // 估计难度和知识点标签
+	TaskTranslate = "translate" // 翻译题目
+)
+
+// GenerationRequest 一次模型调用的输入
+type GenerationRequest struct {
+	// Task 调用用途，见 Task* 常量；mock 实现据此返回对应格式的内容
+	Task     string
+	Messages []ChatMessage
+	// Spec 为出题参数，mock 实现依据它构造确定性的输出
+	Spec *AIGenerateRequest
+	// Schema 不为空且配置了结构化输出时，要求提供方按该 schema 输出
+	Schema *ResponseSchema
+	// OnModel 流式调用时在第一段增量之前回调提供方实际使用的模型，
+	// 提供方没有返回模型名时为配置的模型
+	OnModel func(model string)
+}
+
+// GenerationResult 一次模型调用的输出
+type GenerationResult struct {
+	Content string
+	Model   string
+	Usage   TokenUsage
+}
+
+// TokenUsage 提供方返回的 token 用量，提供方不返回时为 0
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// QuestionGenerator AI出题服务的统一接口，具体实现由配置决定
+type QuestionGenerator interface {
+	Name() string
+	Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error)
+}
+
+// StreamingGenerator 支持流式输出的提供方，onDelta 按到达顺序接收增量文本，
+// 返回的 GenerationResult 中 Content 为完整内容
+type StreamingGenerator interface {
+	QuestionGenerator
+	GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error)
+}
+
+// streamGenerate 优先使用流式接口，提供方不支持时退化为一次性返回全部内容
+func streamGenerate(ctx context.Context, g QuestionGenerator, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	if sg, ok := g.(StreamingGenerator); ok {
+		return sg.GenerateStream(ctx, req, onDelta)
+	}
+	result, err := g.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	req.reportModel(result.Model)
+	if err := onDelta(result.Content); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reportModel 通知调用方本次流式调用使用的模型
+func (r GenerationRequest) reportModel(model string) {
+	if r.OnModel != nil {
+		r.OnModel(model)
+	}
+}
+
+// newLineScanner 创建逐行读取流式响应的扫描器，单行最长 1MB
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scanner
+}
+
+// ProviderError 提供方返回了非200状态码
+type ProviderError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 响应头 Retry-After 给出的等待时间
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("AI服务返回错误，状态码: %d, 响应: %s", e.StatusCode, e.Body)
+}
+
+// Retryable 限流和服务端错误可以重试
+func (e *ProviderError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func newProviderError(statusCode int, header http.Header, body string) *ProviderError {
+	err := &ProviderError{StatusCode: statusCode, Body: body}
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, convErr := strconv.Atoi(v); convErr == nil && secs > 0 {
+			err.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return err
+}
+
+// AIConfig AI服务配置，从环境变量读取
+type AIConfig struct {
+	Provider    string
+	BaseURL     string
+	APIKey      string
+	Model       string
+	MaxTokens   int
+	Temperature *float64 // 为空时不发送，使用模型默认值
+	Timeout     time.Duration
+
+	StructuredOutput string // 结构化输出方式，见 StructuredOutput* 常量
+
+	MaxRetries     int           // 429/5xx 时的最大重试次数
+	RetryBaseDelay time.Duration // 首次重试的等待时间，之后每次翻倍
+	RepairAttempts int           // 输出无法解析时请求模型自行修正的次数
+}
+
+// loadAIConfig 读取 AI_PROVIDER、AI_BASE_URL、AI_API_KEY、AI_MODEL、AI_MAX_TOKENS、AI_TEMPERATURE、AI_TIMEOUT，
+// 以及重试相关的 AI_MAX_RETRIES、AI_RETRY_BASE_DELAY、AI_REPAIR_ATTEMPTS 和结构化输出方式 AI_STRUCTURED_OUTPUT
+func loadAIConfig() AIConfig {
+	cfg := AIConfig{
+		Provider:  strings.ToLower(strings.TrimSpace(os.Getenv("AI_PROVIDER"))),
+		BaseURL:   strings.TrimSpace(os.Getenv("AI_BASE_URL")),
+		APIKey:    os.Getenv("AI_API_KEY"),
+		Model:     strings.TrimSpace(os.Getenv("AI_MODEL")),
+		MaxTokens: 2000,
+		Timeout:   120 * time.Second,
+
+		StructuredOutput: StructuredOutputNone,
+
+		MaxRetries:     2,
+		RetryBaseDelay: time.Second,
+		RepairAttempts: 1,
+	}
+
+	applyProviderDefaults(&cfg)
+
+	if v := os.Getenv("AI_MAX_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxTokens = n
+		} else {
+			log.Printf("Warning: invalid AI_MAX_TOKENS %q, using %d", v, cfg.MaxTokens)
+		}
+	}
+	if v := os.Getenv("AI_TEMPERATURE"); v != "" {
+		if t, err := strconv.ParseFloat(v, 64); err == nil && t >= 0 {
+			cfg.Temperature = &t
+		} else {
+			log.Printf("Warning: invalid AI_TEMPERATURE %q, ignored", v)
+		}
+	}
+	if v := os.Getenv("AI_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Timeout = d
+		} else {
+			log.Printf("Warning: invalid AI_TIMEOUT %q, using %s", v, cfg.Timeout)
+		}
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("AI_STRUCTURED_OUTPUT"))); v != "" {
+		switch v {
+		case StructuredOutputNone, StructuredOutputJSONSchema, StructuredOutputJSONObject, StructuredOutputTools:
+			cfg.StructuredOutput = v
+		default:
+			log.Printf("Warning: invalid AI_STRUCTURED_OUTPUT %q, using %s", v, cfg.StructuredOutput)
+		}
+	}
+	if v := os.Getenv("AI_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRetries = n
+		} else {
+			log.Printf("Warning: invalid AI_MAX_RETRIES %q, using %d", v, cfg.MaxRetries)
+		}
+	}
+	if v := os.Getenv("AI_RETRY_BASE_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.RetryBaseDelay = d
+		} else {
+			log.Printf("Warning: invalid AI_RETRY_BASE_DELAY %q, using %s", v, cfg.RetryBaseDelay)
+		}
+	}
+	if v := os.Getenv("AI_REPAIR_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.RepairAttempts = n
+		} else {
+			log.Printf("Warning: invalid AI_REPAIR_ATTEMPTS %q, using %d", v, cfg.RepairAttempts)
+		}
+	}
+
+	return cfg
+}
+
+// applyProviderDefaults 补全提供方的默认地址和模型
+func applyProviderDefaults(cfg *AIConfig) {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.siliconflow.cn/v1/"
+		}
+		if cfg.Model == "" {
+			cfg.Model = "deepseek-ai/DeepSeek-V3"
+		}
+	case ProviderOllama:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "http://localhost:11434"
+		}
+		if cfg.Model == "" {
+			cfg.Model = "qwen2.5:7b"
+		}
+	case ProviderMock:
+		if cfg.Model == "" {
+			cfg.Model = "mock"
+		}
+	}
+}
+
+// newQuestionGenerator 根据配置创建对应的提供方实现
+func newQuestionGenerator(cfg AIConfig) (QuestionGenerator, error) {
+	var g QuestionGenerator
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		g = newOpenAIGenerator(cfg)
+	case ProviderOllama:
+		g = newOllamaGenerator(cfg)
+	case ProviderMock:
+		g = &mockGenerator{model: cfg.Model}
+	default:
+		return nil, fmt.Errorf("unknown AI_PROVIDER %q", cfg.Provider)
+	}
+
+	// 每次实际的模型调用（包括重试）都记录用量
+	g = &usageRecordingGenerator{inner: g, model: cfg.Model}
+	if cfg.MaxRetries > 0 {
+		g = &retryingGenerator{inner: g, maxRetries: cfg.MaxRetries, baseDelay: cfg.RetryBaseDelay}
+	}
+	return g, nil
+}
+
+// userPrompt 将单条提示词包装为对话消息
+func userPrompt(prompt string) []ChatMessage {
+	return []ChatMessage{{Role: "user", Content: prompt}}
+}
+
+// ---------------- 重试 ----------------
+
+// retryingGenerator 在提供方返回 429 或 5xx 时按指数退避重试
+type retryingGenerator struct {
+	inner      QuestionGenerator
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+func (g *retryingGenerator) Name() string { return g.inner.Name() }
+
+func (g *retryingGenerator) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := g.inner.Generate(ctx, req)
+		if err == nil || !g.shouldRetry(ctx, err, attempt) {
+			return result, err
+		}
+	}
+}
+
+// GenerateStream 只在还没有输出任何内容时重试，避免调用方收到重复的增量
+func (g *retryingGenerator) GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	for attempt := 0; ; attempt++ {
+		delivered := false
+		result, err := streamGenerate(ctx, g.inner, req, func(delta string) error {
+			delivered = true
+			return onDelta(delta)
+		})
+		if err == nil || delivered || !g.shouldRetry(ctx, err, attempt) {
+			return result, err
+		}
+	}
+}
+
+// shouldRetry 判断是否可以重试，可以时等待退避时间后返回 true
+func (g *retryingGenerator) shouldRetry(ctx context.Context, err error, attempt int) bool {
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !providerErr.Retryable() || attempt >= g.maxRetries {
+		return false
+	}
+
+	delay := g.baseDelay << uint(attempt)
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
+	}
+	if providerErr.RetryAfter > delay {
+		delay = providerErr.RetryAfter
+	}
+	log.Printf("AI provider returned %d, retry %d/%d in %s", providerErr.StatusCode, attempt+1, g.maxRetries, delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ---------------- OpenAI 兼容接口 ----------------
+
+type openAIGenerator struct {
+	cfg    AIConfig
+	client *resty.Client
+}
+
+func newOpenAIGenerator(cfg AIConfig) *openAIGenerator {
+	client := resty.New()
+	client.SetBaseURL(cfg.BaseURL)
+	client.SetTimeout(cfg.Timeout)
+	client.SetHeader("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		client.SetHeader("Authorization", "Bearer "+cfg.APIKey)
+	}
+	return &openAIGenerator{cfg: cfg, client: client}
+}
+
+func (g *openAIGenerator) Name() string { return ProviderOpenAI }
+
+// requestBody 组装 chat/completions 请求体
+func (g *openAIGenerator) requestBody(req GenerationRequest, stream bool) map[string]interface{} {
+	aiReq := map[string]interface{}{
+		"model":      g.cfg.Model,
+		"messages":   req.Messages,
+		"stream":     stream,
+		"max_tokens": g.cfg.MaxTokens,
+	}
+	if stream {
+		// 流式响应默认不带用量，需要显式要求在最后一个分片中返回
+		aiReq["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if g.cfg.Temperature != nil {
+		aiReq["temperature"] = *g.cfg.Temperature
+	}
+
+	if req.Schema == nil {
+		// 出题时遇到 null 即停止；其他用途输出的是自由文本，不能截断
+		if req.Task == TaskGenerate {
+			aiReq["stop"] = []string{"null"}
+		}
+		return aiReq
+	}
+	switch g.cfg.StructuredOutput {
+	case StructuredOutputJSONSchema:
+		aiReq["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   req.Schema.Name,
+				"schema": req.Schema.Schema,
+			},
+		}
+	case StructuredOutputJSONObject:
+		aiReq["response_format"] = map[string]interface{}{"type": "json_object"}
+	case StructuredOutputTools:
+		aiReq["tools"] = []map[string]interface{}{{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        req.Schema.Name,
+				"description": "返回生成的结果",
+				"parameters":  req.Schema.Schema,
+			},
+		}}
+		aiReq["tool_choice"] = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": req.Schema.Name},
+		}
+	}
+	return aiReq
+}
+
+// openAIToolCall 函数调用的返回，arguments 为JSON字符串
+type openAIToolCall struct {
+	Function struct {
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+func (g *openAIGenerator) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	resp, err := g.client.R().
+		SetContext(ctx).
+		SetBody(g.requestBody(req, false)).
+		Post("chat/completions")
+	if err != nil {
+		return nil, fmt.Errorf("AI服务调用失败: %w", err)
+	}
+
+	// 检查HTTP状态码
+	if resp.StatusCode() != http.StatusOK {
+		log.Printf("AI API returned non-200 status: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+		return nil, newProviderError(resp.StatusCode(), resp.Header(), string(resp.Body()))
+	}
+
+	var aiResp struct {
+		Model   string     `json:"model"`
+		Usage   TokenUsage `json:"usage"`
+		Choices []struct {
+			Message struct {
+				Content   string           `json:"content"`
+				ToolCalls []openAIToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	log.Printf("AI API raw response: %s\n", string(resp.Body()))
+
+	if err := json.Unmarshal(resp.Body(), &aiResp); err != nil {
+		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+
+	// 检查是否有错误
+	if aiResp.Error.Message != "" {
+		return nil, errors.New("AI服务错误: " + aiResp.Error.Message)
+	}
+
+	if len(aiResp.Choices) == 0 {
+		log.Printf("AI response has no choices, body: %s", string(resp.Body()))
+		return nil, errors.New("AI未返回任何内容")
+	}
+
+	// 使用函数调用时，结构化结果在参数里
+	message := aiResp.Choices[0].Message
+	content := message.Content
+	if len(message.ToolCalls) > 0 && message.ToolCalls[0].Function.Arguments != "" {
+		content = message.ToolCalls[0].Function.Arguments
+	}
+
+	model := aiResp.Model
+	if model == "" {
+		model = g.cfg.Model
+	}
+	return &GenerationResult{Content: content, Model: model, Usage: aiResp.Usage}, nil
+}
+
+func (g *openAIGenerator) GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	resp, err := g.client.R().
+		SetContext(ctx).
+		SetBody(g.requestBody(req, true)).
+		SetDoNotParseResponse(true).
+		Post("chat/completions")
+	if err != nil {
+		return nil, fmt.Errorf("AI服务调用失败: %w", err)
+	}
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.StatusCode() != http.StatusOK {
+		raw, _ := io.ReadAll(body)
+		log.Printf("AI API returned non-200 status: %d, body: %s", resp.StatusCode(), string(raw))
+		return nil, newProviderError(resp.StatusCode(), resp.Header(), string(raw))
+	}
+
+	var content strings.Builder
+	var usage TokenUsage
+	model := g.cfg.Model
+	scanner := newLineScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Model   string      `json:"model"`
+			Usage   *TokenUsage `json:"usage"`
+			Choices []struct {
+				Delta struct {
+					Content   string           `json:"content"`
+					ToolCalls []openAIToolCall `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("Skip malformed stream chunk: %s", data)
+			continue
+		}
+		if chunk.Error.Message != "" {
+			return nil, errors.New("AI服务错误: " + chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		if toolCalls := chunk.Choices[0].Delta.ToolCalls; len(toolCalls) > 0 {
+			delta += toolCalls[0].Function.Arguments
+		}
+		if delta == "" {
+			continue
+		}
+
+		if content.Len() == 0 {
+			req.reportModel(model)
+		}
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取AI流式响应失败: %w", err)
+	}
+	if content.Len() == 0 {
+		return nil, errors.New("AI未返回任何内容")
+	}
+
+	return &GenerationResult{Content: content.String(), Model: model, Usage: usage}, nil
+}
+
+// ---------------- Ollama ----------------
+
+type ollamaGenerator struct {
+	cfg    AIConfig
+	client *resty.Client
+}
+
+func newOllamaGenerator(cfg AIConfig) *ollamaGenerator {
+	client := resty.New()
+	client.SetBaseURL(cfg.BaseURL)
+	client.SetTimeout(cfg.Timeout)
+	client.SetHeader("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		client.SetHeader("Authorization", "Bearer "+cfg.APIKey)
+	}
+	return &ollamaGenerator{cfg: cfg, client: client}
+}
+
+func (g *ollamaGenerator) Name() string { return ProviderOllama }
+
+// requestBody 组装 api/chat 请求体
+func (g *ollamaGenerator) requestBody(req GenerationRequest, stream bool) map[string]interface{} {
+	options := map[string]interface{}{
+		"num_predict": g.cfg.MaxTokens,
+	}
+	if g.cfg.Temperature != nil {
+		options["temperature"] = *g.cfg.Temperature
+	}
+	aiReq := map[string]interface{}{
+		"model":    g.cfg.Model,
+		"messages": req.Messages,
+		"stream":   stream,
+		"options":  options,
+	}
+
+	// Ollama 没有函数调用形式的结构化输出，tools 同样使用 schema
+	if req.Schema != nil {
+		switch g.cfg.StructuredOutput {
+		case StructuredOutputJSONSchema, StructuredOutputTools:
+			aiReq["format"] = req.Schema.Schema
+		case StructuredOutputJSONObject:
+			aiReq["format"] = "json"
+		}
+	}
+	return aiReq
+}
+
+func (g *ollamaGenerator) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	resp, err := g.client.R().
+		SetContext(ctx).
+		SetBody(g.requestBody(req, false)).
+		Post("api/chat")
+	if err != nil {
+		return nil, fmt.Errorf("AI服务调用失败: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		log.Printf("Ollama returned non-200 status: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+		return nil, newProviderError(resp.StatusCode(), resp.Header(), string(resp.Body()))
+	}
+
+	var aiResp struct {
+		Model   string `json:"model"`
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+		Error           string `json:"error"`
+	}
+
+	log.Printf("Ollama raw response: %s\n", string(resp.Body()))
+
+	if err := json.Unmarshal(resp.Body(), &aiResp); err != nil {
+		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+	if aiResp.Error != "" {
+		return nil, errors.New("AI服务错误: " + aiResp.Error)
+	}
+	if aiResp.Message.Content == "" {
+		return nil, errors.New("AI未返回任何内容")
+	}
+
+	model := aiResp.Model
+	if model == "" {
+		model = g.cfg.Model
+	}
+	usage := TokenUsage{PromptTokens: aiResp.PromptEvalCount, CompletionTokens: aiResp.EvalCount}
+	return &GenerationResult{Content: aiResp.Message.Content, Model: model, Usage: usage}, nil
+}
+
+func (g *ollamaGenerator) GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	resp, err := g.client.R().
+		SetContext(ctx).
+		SetBody(g.requestBody(req, true)).
+		SetDoNotParseResponse(true).
+		Post("api/chat")
+	if err != nil {
+		return nil, fmt.Errorf("AI服务调用失败: %w", err)
+	}
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.StatusCode() != http.StatusOK {
+		raw, _ := io.ReadAll(body)
+		log.Printf("Ollama returned non-200 status: %d, body: %s", resp.StatusCode(), string(raw))
+		return nil, newProviderError(resp.StatusCode(), resp.Header(), string(raw))
+	}
+
+	// Ollama 的流式响应为逐行的 JSON 对象
+	var content strings.Builder
+	var usage TokenUsage
+	model := g.cfg.Model
+	scanner := newLineScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk struct {
+			Model   string `json:"model"`
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			log.Printf("Skip malformed stream chunk: %s", line)
+			continue
+		}
+		if chunk.Error != "" {
+			return nil, errors.New("AI服务错误: " + chunk.Error)
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Message.Content != "" {
+			if content.Len() == 0 {
+				req.reportModel(model)
+			}
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			// 用量只在最后一个对象中返回
+			usage = TokenUsage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取AI流式响应失败: %w", err)
+	}
+	if content.Len() == 0 {
+		return nil, errors.New("AI未返回任何内容")
+	}
+
+	return &GenerationResult{Content: content.String(), Model: model, Usage: usage}, nil
+}
+
+// ---------------- Mock ----------------
+
+// mockGenerator 不访问网络，按出题参数返回固定格式的题目，同样的输入总是得到同样的输出
+type mockGenerator struct {
+	model string
+}
+
+func (g *mockGenerator) Name() string { return ProviderMock }
+
+func (g *mockGenerator) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var content string
+	switch req.Task {
+	case TaskGenerate:
+		if req.Spec == nil {
+			return nil, errors.New("mock 生成器缺少出题参数")
+		}
+		b, err := json.Marshal(mockQuestions(req.Spec))
+		if err != nil {
+			return nil, err
+		}
+		content = string(b)
+	case TaskExplain:
+		content = "[mock] 解析：\n1. 阅读题干，明确考查的知识点。\n2. 逐项分析选项，排除错误选项。\n3. 得出题目给出的答案。"
+	case TaskVerify:
+		content = `{"answer": "A", "reasoning": "[mock] 固定选择A"}`
+	case TaskClassify:
+		content = `{"difficulty": "hard", "confidence": 0.85, "tags": [{"name": "基础语法", "confidence": 0.9}, {"name": "mock", "confidence": 0.4}], "reasoning": "[mock] 固定结果"}`
+	case TaskTranslate:
+		content = mockTranslation(req.Messages[len(req.Messages)-1].Content)
+	default:
+		return nil, fmt.Errorf("mock 生成器不支持 %q", req.Task)
+	}
+
+	// 按字符数估算用量，便于在本地验证用量统计和预算
+	usage := TokenUsage{CompletionTokens: utf8.RuneCountInString(content)}
+	for _, m := range req.Messages {
+		usage.PromptTokens += utf8.RuneCountInString(m.Content)
+	}
+	return &GenerationResult{Content: content, Model: g.model, Usage: usage}, nil
+}
+
+// mockTranslation 取出提示词末尾的源内容JSON，在每段文字前加上 [mock] 标记
+func mockTranslation(prompt string) string {
+	var source map[string]interface{}
+	if i := strings.LastIndex(prompt, "\n{"); i >= 0 {
+		json.Unmarshal([]byte(prompt[i+1:]), &source)
+	}
+	for k, v := range source {
+		switch val := v.(type) {
+		case string:
+			if val != "" {
+				source[k] = "[mock] " + val
+			}
+		case map[string]interface{}:
+			for opt, text := range val {
+				val[opt] = fmt.Sprintf("[mock] %v", text)
+			}
+		}
+	}
+	b, _ := json.Marshal(source)
+	return string(b)
+}
+
+// mockQuestions 按出题参数构造题目
+func mockQuestions(spec *AIGenerateRequest) []map[string]interface{} {
+	topic := spec.Topic
+	if topic == "" {
+		topic = "基础语法"
+	}
+
+	items := make([]map[string]interface{}, 0, spec.Count)
+	for i := 1; i <= spec.Count; i++ {
+		item := map[string]interface{}{
+			"type":       spec.Type,
+			"content":    fmt.Sprintf("[mock] %s %s %s 第%d题", spec.Language, topic, spec.Difficulty, i),
+			"difficulty": spec.Difficulty,
+			"language":   spec.Language,
+		}
+		if t := lookupQuestionType(spec.Type); t != nil {
+			for key, value := range t.Mock(i) {
+				item[key] = value
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// GenerateStream 将完整输出按固定长度切片后逐段回调，模拟流式返回
+func (g *mockGenerator) GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	result, err := g.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	req.reportModel(result.Model)
+	runes := []rune(result.Content)
+	const chunkSize = 32
+	for i := 0; i < len(runes); i += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := i + chunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if err := onDelta(string(runes[i:end])); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
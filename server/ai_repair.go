@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ParsedGeneration 一次生成（含修正）的最终结果
+type ParsedGeneration struct {
+	Result    *GenerationResult   // 最后一次被采用的模型输出
+	Questions []GeneratedQuestion // 通过校验的题目
+	Invalid   []InvalidQuestion   // 未通过校验的题目及字段错误
+	ParseErr  error               // 修正后仍无法解析时的错误
+	Notes     []string            // 修正过程记录，供调用方作为警告返回
+}
+
+// generateWithRepair 调用模型生成题目并解析。
+// 解析顺序为：严格JSON -> 宽松的 JSON5 语法 -> 把原输出和错误原因发回模型要求修正，
+// 修正次数由 AI_REPAIR_ATTEMPTS 控制。只有模型调用本身失败时才返回 error。
+func generateWithRepair(ctx context.Context, req AIGenerateRequest, prompt string) (*ParsedGeneration, error) {
+	messages := userPrompt(prompt)
+	result, err := aiGenerator.Generate(ctx, newGenerationRequest(req, messages))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Raw AI response content: %s\n", result.Content)
+
+	return repairGeneratedQuestions(ctx, req, messages, result), nil
+}
+
+// repairGeneratedQuestions 解析已有的模型输出，必要时继续对话要求模型修正。
+// messages 为得到 result 时发送的对话。
+func repairGeneratedQuestions(ctx context.Context, req AIGenerateRequest, messages []ChatMessage, result *GenerationResult) *ParsedGeneration {
+	out := &ParsedGeneration{Result: result}
+	out.Questions, out.Invalid, out.ParseErr = parseGeneratedQuestions(result.Content, req)
+	base := messages[:len(messages):len(messages)]
+	for attempt := 1; attempt <= aiConfig.RepairAttempts; attempt++ {
+		problem := describeParseProblem(out, req)
+		if problem == "" {
+			break
+		}
+		log.Printf("AI output rejected (%s), asking model to repair, attempt %d", problem, attempt)
+		out.Notes = append(out.Notes, fmt.Sprintf("第%d次请求模型修正输出: %s", attempt, problem))
+
+		// 每次修正只带上目前采用的输出和对应的问题，不累积之前的修正对话
+		messages = append(base,
+			ChatMessage{Role: "assistant", Content: out.Result.Content},
+			ChatMessage{Role: "user", Content: buildRepairPrompt(problem, req)},
+		)
+		repaired, err := aiGenerator.Generate(ctx, newGenerationRequest(req, messages))
+		if err != nil {
+			out.Notes = append(out.Notes, "模型修正失败: "+err.Error())
+			break
+		}
+		log.Printf("Repaired AI response content: %s\n", repaired.Content)
+
+		fixed, invalid, fixErr := parseGeneratedQuestions(repaired.Content, req)
+		if fixErr != nil || countUsable(fixed) < countUsable(out.Questions) {
+			out.Notes = append(out.Notes, "修正后的输出仍然无法使用")
+			continue
+		}
+
+		// 经过修正的题目统一标记为 repaired
+		for i := range fixed {
+			if fixed[i].Provenance == ProvenanceAI {
+				fixed[i].Provenance = ProvenanceRepaired
+			}
+		}
+		out.Result, out.Questions, out.Invalid, out.ParseErr = repaired, fixed, invalid, nil
+	}
+
+	if out.Invalid == nil {
+		out.Invalid = []InvalidQuestion{}
+	}
+	return out
+}
+
+// describeParseProblem 描述解析结果存在的问题，没有问题时返回空字符串
+func describeParseProblem(out *ParsedGeneration, req AIGenerateRequest) string {
+	if out.ParseErr != nil {
+		return "输出不是合法的JSON（" + out.ParseErr.Error() + "）"
+	}
+
+	var problems []string
+	for _, item := range out.Invalid {
+		problems = append(problems, fmt.Sprintf("第%d题字段错误（%s）", item.Index+1, joinFieldErrors(item.Errors)))
+	}
+	if usable := countUsable(out.Questions); usable < req.Count {
+		problems = append(problems, fmt.Sprintf("只有%d道有效题目，需要%d道", usable, req.Count))
+	}
+	return strings.Join(problems, "；")
+}
+
+// newGenerationRequest 组装题目生成的模型调用参数，开启结构化输出时附带 schema
+func newGenerationRequest(req AIGenerateRequest, messages []ChatMessage) GenerationRequest {
+	genReq := GenerationRequest{Messages: messages, Spec: &req}
+	if aiConfig.StructuredOutput != StructuredOutputNone {
+		genReq.Schema = questionResponseSchema(req)
+	}
+	return genReq
+}
+
+// countUsable 统计题型和内容都不为空的题目数量
+func countUsable(questions []GeneratedQuestion) int {
+	n := 0
+	for _, q := range questions {
+		if q.Type != "" && q.Content != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// buildRepairPrompt 要求模型根据错误原因修正上一次的输出
+func buildRepairPrompt(problem string, req AIGenerateRequest) string {
+	return fmt.Sprintf(`你上一次的输出存在问题：%s。
+请修正后重新输出全部%d道题目，格式与最初的要求完全一致。
+只返回JSON数组，不要包含markdown代码块标记或任何其他文字。`, problem, req.Count)
+}
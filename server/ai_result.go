@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"homework-server/models"
+
+	"gorm.io/gorm"
+)
+
+// 生成题目的来源
+const (
+	ProvenanceAI          = "ai"          // 模型返回的内容可直接解析，字段完整
+	ProvenanceRepaired    = "repaired"    // 经过格式修复或字段补全，需要人工核对
+	ProvenancePlaceholder = "placeholder" // 服务端补充的占位题目，不能直接保存
+)
+
+// GeneratedQuestion AI生成的题目及其来源
+type GeneratedQuestion struct {
+	QuestionRequest
+	Provenance string `json:"provenance"`
+	// Duplicates 题库中与该题相似的题目
+	Duplicates []SimilarQuestion `json:"duplicates,omitempty"`
+	// Verification 答案校验结果，未校验时为空
+	Verification *AnswerVerification `json:"verification,omitempty"`
+}
+
+// setGenerationSource 在生成的题目上记录来源信息，题目保存时一并写入题库
+func setGenerationSource(questions []GeneratedQuestion, model, topic string, jobID *uint) {
+	for i := range questions {
+		questions[i].Source = models.SourceAI
+		questions[i].Model = model
+		questions[i].Topic = topic
+		questions[i].JobID = jobID
+	}
+}
+
+// saveGeneratedQuestions 在一个事务中保存生成的题目及其指纹。
+// 占位题目和与题库完全相同的题目会跳过，跳过的原因作为警告返回；bankID 不为空时同时加入该题库，
+// author 为题目的创建者
+func saveGeneratedQuestions(questions []GeneratedQuestion, bankID *uint, author string) ([]models.Question, []string, error) {
+	saved := make([]models.Question, 0, len(questions))
+	var skipped []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, g := range questions {
+			if g.Provenance == ProvenancePlaceholder {
+				skipped = append(skipped, fmt.Sprintf("第%d题是占位题目，未保存", i+1))
+				continue
+			}
+			if hasExactDuplicate(g.Duplicates) {
+				skipped = append(skipped, fmt.Sprintf("第%d题与题库中的题目完全相同，未保存", i+1))
+				continue
+			}
+			if errs := prepareQuestionRequest(&g.QuestionRequest); len(errs) > 0 {
+				skipped = append(skipped, fmt.Sprintf("第%d题未通过校验，未保存: %s", i+1, joinFieldErrors(errs)))
+				continue
+			}
+			question := newQuestion(g.QuestionRequest, author)
+			tags, err := questionRequestTags(tx, g.QuestionRequest)
+			if err != nil {
+				return err
+			}
+			question.Tags = tags
+			if err := tx.Create(&question).Error; err != nil {
+				return err
+			}
+			if err := saveQuestionFingerprint(tx, &question); err != nil {
+				return err
+			}
+			if err := recordQuestionRevision(tx, question.ID, models.RevisionCreate, author); err != nil {
+				return err
+			}
+			saved = append(saved, question)
+		}
+		if bankID == nil {
+			return nil
+		}
+		ids := make([]uint, len(saved))
+		for i, q := range saved {
+			ids[i] = q.ID
+		}
+		_, err := addQuestionsToBank(tx, *bankID, ids)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return saved, skipped, nil
+}
+
+var placeholderContentRe = regexp.MustCompile(`^请在此处填写第\d+题题目内容$`)
+
+// isPlaceholderContent 判断题目内容是否为未修改的占位内容
+func isPlaceholderContent(content string) bool {
+	return placeholderContentRe.MatchString(content)
+}
+
+// fillPlaceholders 题目不足时是否补充占位题目
+func (r AIGenerateRequest) fillPlaceholders() bool {
+	return r.FillPlaceholders == nil || *r.FillPlaceholders
+}
+
+// parseGeneratedQuestions 解析AI返回的题目，逐题按 schema 校验并标注来源。
+// 通过校验的题目中，内容本身是合法JSON的标记为 ai，经过宽松语法解析的标记为 repaired；
+// 未通过校验的题目原样放入 invalid 并附带字段级错误，不做任何猜测和补全。
+func parseGeneratedQuestions(content string, req AIGenerateRequest) ([]GeneratedQuestion, []InvalidQuestion, error) {
+	items, strict, err := decodeQuestionItems(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provenance := ProvenanceAI
+	if !strict {
+		provenance = ProvenanceRepaired
+	}
+
+	var valid []GeneratedQuestion
+	var invalid []InvalidQuestion
+	for i, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			invalid = append(invalid, InvalidQuestion{
+				Index:  i,
+				Errors: []FieldError{{Message: "题目必须是JSON对象"}},
+			})
+			continue
+		}
+
+		canonicalizeQuestionItem(item)
+		if errs := validateQuestionItem(item, req); len(errs) > 0 {
+			invalid = append(invalid, InvalidQuestion{Index: i, Item: item, Errors: errs})
+			continue
+		}
+
+		question := normalizeQuestion(convertMapToQuestion(item, req), req)
+		if question.Payload != nil {
+			// schema 只约束结构，空位标记、配对重复等按题型定义再校验一次
+			check := question
+			if errs := prepareQuestionRequest(&check); len(errs) > 0 {
+				invalid = append(invalid, InvalidQuestion{Index: i, Item: item, Errors: errs})
+				continue
+			}
+		}
+		valid = append(valid, GeneratedQuestion{
+			QuestionRequest: question,
+			Provenance:      provenance,
+		})
+	}
+	return valid, invalid, nil
+}
+
+// joinFieldErrors 将字段错误拼接为一行说明
+func joinFieldErrors(errs []FieldError) string {
+	parts := make([]string, 0, len(errs))
+	for _, e := range errs {
+		parts = append(parts, e.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// finalizeGeneratedQuestions 丢弃无效题目、截断多余题目，并在允许时补充占位题目。
+// 返回的 warnings 说明了所有被调整的地方。
+func finalizeGeneratedQuestions(questions []GeneratedQuestion, invalid []InvalidQuestion, req AIGenerateRequest, parseErr error, fill bool) ([]GeneratedQuestion, []string) {
+	warnings := []string{}
+	if parseErr != nil {
+		warnings = append(warnings, "AI响应解析失败: "+parseErr.Error())
+	}
+	for _, item := range invalid {
+		warnings = append(warnings, fmt.Sprintf("第%d题未通过校验: %s", item.Index+1, joinFieldErrors(item.Errors)))
+	}
+
+	valid := make([]GeneratedQuestion, 0, len(questions))
+	for i, q := range questions {
+		if q.Type == "" || q.Content == "" {
+			warnings = append(warnings, fmt.Sprintf("第%d题缺少题型或内容，已丢弃", i+1))
+			continue
+		}
+		valid = append(valid, q)
+	}
+
+	if len(valid) > req.Count {
+		warnings = append(warnings, fmt.Sprintf("AI返回了%d道题目，只保留前%d道", len(valid), req.Count))
+		valid = valid[:req.Count]
+	}
+
+	repaired := 0
+	for _, q := range valid {
+		if q.Provenance == ProvenanceRepaired {
+			repaired++
+		}
+	}
+	if repaired > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d道题目经过格式修复，请核对后再保存", repaired))
+	}
+
+	if len(valid) < req.Count {
+		warnings = append(warnings, fmt.Sprintf("AI只生成了%d道题目，请求的是%d道", len(valid), req.Count))
+		if fill {
+			missing := req.Count - len(valid)
+			for i := len(valid); i < req.Count; i++ {
+				valid = append(valid, GeneratedQuestion{
+					QuestionRequest: createDefaultQuestion(req, i+1),
+					Provenance:      ProvenancePlaceholder,
+				})
+			}
+			warnings = append(warnings, fmt.Sprintf("已补充%d道占位题目，占位题目不能直接保存", missing))
+		}
+	}
+
+	return valid, warnings
+}
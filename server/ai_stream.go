@@ -0,0 +1,231 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// questionStreamParser 从流式输出中逐个切出完整的题目对象。
+// 支持题目数组、{"questions": [...]} 和单个题目对象三种结构；
+// 只跟踪括号嵌套和字符串状态，markdown 标记等外层内容直接忽略。
+type questionStreamParser struct {
+	buf      []byte
+	pos      int
+	stack    []byte // 未闭合的 { 和 [
+	starts   []int  // stack 中每个括号在 buf 中的位置
+	target   int    // 题目对象所在的嵌套深度，0 表示尚未确定
+	envelope bool   // 顶层对象的 questions 数组
+	emitted  bool   // 当前顶层结构中是否已经切出过题目
+	lastKey  string // 最近一个出现在对象中的字符串，用来识别 questions 键
+	quote    byte
+	strStart int
+	escaped  bool
+}
+
+// Feed 追加一段增量文本，返回本次新出现的完整题目对象
+func (p *questionStreamParser) Feed(delta string) []string {
+	p.buf = append(p.buf, delta...)
+
+	var objects []string
+	for ; p.pos < len(p.buf); p.pos++ {
+		ch := p.buf[p.pos]
+		if p.quote != 0 {
+			switch {
+			case p.escaped:
+				p.escaped = false
+			case ch == '\\':
+				p.escaped = true
+			case ch == p.quote:
+				p.quote = 0
+				if top := len(p.stack) - 1; top >= 0 && p.stack[top] == '{' {
+					p.lastKey = string(p.buf[p.strStart+1 : p.pos])
+				}
+			}
+			continue
+		}
+
+		switch ch {
+		case '"', '\'':
+			// 结构外的引号属于说明文字，不参与匹配
+			if len(p.stack) > 0 {
+				p.quote = ch
+				p.strStart = p.pos
+			}
+		case '{', '[':
+			depth := len(p.stack)
+			if ch == '[' && depth == 1 && p.stack[0] == '{' {
+				p.envelope = p.lastKey == "questions"
+			}
+			if ch == '{' && p.target == 0 && depth > 0 && p.stack[depth-1] == '[' &&
+				(depth == 1 || (depth == 2 && p.envelope)) {
+				p.target = depth + 1
+			}
+			p.stack = append(p.stack, ch)
+			p.starts = append(p.starts, p.pos)
+		case '}', ']':
+			top := len(p.stack) - 1
+			if top < 0 || (ch == '}') != (p.stack[top] == '{') {
+				continue
+			}
+			start := p.starts[top]
+			depth := len(p.stack)
+			p.stack = p.stack[:top]
+			p.starts = p.starts[:top]
+
+			if ch == '}' {
+				if depth == p.target {
+					objects = append(objects, string(p.buf[start:p.pos+1]))
+					p.emitted = true
+				} else if depth == 1 && !p.emitted {
+					// 单个题目对象
+					objects = append(objects, string(p.buf[start:p.pos+1]))
+				}
+			}
+			if len(p.stack) == 0 {
+				p.target = 0
+				p.envelope = false
+				p.emitted = false
+			}
+		}
+	}
+
+	// 顶层结构闭合后丢弃已经处理完的内容
+	if len(p.stack) == 0 && p.quote == 0 {
+		p.buf = p.buf[:0]
+		p.pos = 0
+	}
+
+	return objects
+}
+
+// bindAIGenerateRequest 解析并校验AI生成请求，失败时已写入响应
+func bindAIGenerateRequest(c *gin.Context) (AIGenerateRequest, bool) {
+	var req AIGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		log.Printf("AI generate request error: %v", err)
+		return req, false
+	}
+
+	// 验证必填字段
+	if req.Language == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "编程语言不能为空"})
+		return req, false
+	}
+	if questionItemSchema(req.Type) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的题目类型: " + string(req.Type)})
+		return req, false
+	}
+
+	return req, true
+}
+
+// rejectSaveOptions 流式生成和异步任务只返回生成结果，不保存题目；请求中指定 save 或 bank_id 时返回 400
+func rejectSaveOptions(c *gin.Context, req AIGenerateRequest) bool {
+	if req.Save || req.BankID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该接口不保存题目，不支持 save 和 bank_id，请在生成后通过 /api/questions/import 保存"})
+		return true
+	}
+	return false
+}
+
+// 5.1 AI流式生成接口（Server-Sent Events）
+//
+// 事件类型：
+//   - question: 每解析出一道完整题目推送一次，data 为 {"index": n, "data": GeneratedQuestion}
+//   - invalid:  题目未通过 schema 校验，data 为 {"item": 原始题目, "errors": 字段错误}
+//   - error:    调用失败，data 为 {"error": "..."}
+//   - done:     生成结束的汇总信息
+//
+// 流式生成不保存题目，也不校验答案，请求中指定 save、bank_id 或 verify=true 时返回 400
+func generateQuestionsStream(c *gin.Context) {
+	req, ok := bindAIGenerateRequest(c)
+	if !ok || rejectSaveOptions(c, req) {
+		return
+	}
+	if req.Verify != nil && *req.Verify {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "流式生成不校验答案，不支持 verify，请在保存后通过 /api/questions/verify 校验"})
+		return
+	}
+	prompt, template, err := resolvePrompt(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	parser := &questionStreamParser{}
+	emitted := 0
+	skipped := 0
+	invalidCount := 0
+	// 题目在流式输出的过程中推送，来源记录提供方在第一段增量之前报告的模型
+	model := aiConfig.Model
+
+	emit := func(object string) {
+		if emitted >= req.Count {
+			return
+		}
+		questions, invalid, err := parseGeneratedQuestions(object, req)
+		if err != nil {
+			log.Printf("Skip unparsable streamed question: %v, object: %s", err, object)
+			skipped++
+			return
+		}
+		for _, item := range invalid {
+			c.SSEvent("invalid", gin.H{"item": item.Item, "errors": item.Errors})
+			c.Writer.Flush()
+			invalidCount++
+		}
+		setPromptRef(questions, template)
+		setGenerationSource(questions, model, req.Topic, nil)
+		flagDuplicateQuestions(questions)
+		for _, question := range questions {
+			if emitted >= req.Count {
+				break
+			}
+			c.SSEvent("question", gin.H{"index": emitted, "data": question})
+			c.Writer.Flush()
+			emitted++
+		}
+	}
+
+	genReq := newGenerationRequest(req, userPrompt(prompt))
+	genReq.OnModel = func(m string) { model = m }
+	result, err := streamGenerate(ctx, aiGenerator, genReq, func(delta string) error {
+		for _, object := range parser.Feed(delta) {
+			emit(object)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		log.Printf("AI stream error: %v", err)
+		if ctx.Err() == nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	if emitted < req.Count {
+		log.Printf("Warning: AI只生成了%d道题目，请求的是%d道", emitted, req.Count)
+	}
+
+	c.SSEvent("done", gin.H{
+		"count":     emitted,
+		"requested": req.Count,
+		"skipped":   skipped,
+		"invalid":   invalidCount,
+		"model":     result.Model,
+		"template":  template,
+	})
+	c.Writer.Flush()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamEvents 调用流式生成接口，按事件类型返回各事件的 data
+func streamEvents(t *testing.T, body interface{}) map[string][]json.RawMessage {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/ai/generate/stream", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	events := make(map[string][]json.RawMessage)
+	event := ""
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			events[event] = append(events[event], json.RawMessage(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+		}
+	}
+	return events
+}
+
+func TestStreamRecordsReportedModel(t *testing.T) {
+	useFixture(t, "versioned_model")
+	events := streamEvents(t, choiceRequest(2))
+
+	questions := events["question"]
+	if len(questions) != 2 {
+		t.Fatalf("got %d question events, want 2: %v", len(questions), events)
+	}
+	for _, raw := range questions {
+		var event struct {
+			Data GeneratedQuestion `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Data.Model != "versioned_model-2024-08-06" {
+			t.Errorf("model = %q, want the model reported by the provider", event.Data.Model)
+		}
+	}
+
+	// 非流式接口记录的模型与流式接口一致
+	useFixture(t, "versioned_model")
+	code, resp := generate(t, choiceRequest(2))
+	if code != http.StatusOK || len(resp.Data) != 2 {
+		t.Fatalf("status = %d, data = %v, error = %s", code, resp.Data, resp.Error)
+	}
+	if resp.Data[0].Model != "versioned_model-2024-08-06" {
+		t.Errorf("non-stream model = %q", resp.Data[0].Model)
+	}
+}
+
+func TestStreamRejectsSaveAndVerify(t *testing.T) {
+	useFixture(t, "valid_choice")
+	for name, change := range map[string]func(gin.H){
+		"save":    func(b gin.H) { b["save"] = true },
+		"bank_id": func(b gin.H) { b["bank_id"] = 1 },
+		"verify":  func(b gin.H) { b["verify"] = true },
+	} {
+		body := choiceRequest(2)
+		change(body)
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if code := postJSON(t, "/api/ai/generate/stream", body, &resp); code != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("%s: status = %d, want 400", name, code)
+		}
+	}
+	if n := len(testStub.Requests()); n != 0 {
+		t.Errorf("rejected requests called the model %d times", n)
+	}
+
+	// verify=false 与不传相同
+	body := choiceRequest(2)
+	body["verify"] = false
+	if events := streamEvents(t, body); len(events["question"]) != 2 {
+		t.Errorf("verify=false: %d question events, want 2", len(events["question"]))
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var aiUsageConfig AIUsageConfig
+
+// AIUsageConfig 计费单价和用量预算，为 0 表示不限制
+type AIUsageConfig struct {
+	PromptPrice     float64 // 每百万输入 token 的价格
+	CompletionPrice float64 // 每百万输出 token 的价格
+
+	DailyTokenBudget       int64
+	MonthlyTokenBudget     int64
+	DailyCostBudget        float64
+	MonthlyCostBudget      float64
+	CallerDailyTokenBudget int64 // 单个用户或 API Key 每天的 token 上限
+}
+
+// loadAIUsageConfig 读取 AI_PROMPT_PRICE、AI_COMPLETION_PRICE 和各项预算
+// AI_DAILY_TOKEN_BUDGET、AI_MONTHLY_TOKEN_BUDGET、AI_DAILY_COST_BUDGET、AI_MONTHLY_COST_BUDGET、AI_CALLER_DAILY_TOKEN_BUDGET
+func loadAIUsageConfig() AIUsageConfig {
+	return AIUsageConfig{
+		PromptPrice:            envFloat("AI_PROMPT_PRICE"),
+		CompletionPrice:        envFloat("AI_COMPLETION_PRICE"),
+		DailyTokenBudget:       envInt64("AI_DAILY_TOKEN_BUDGET"),
+		MonthlyTokenBudget:     envInt64("AI_MONTHLY_TOKEN_BUDGET"),
+		DailyCostBudget:        envFloat("AI_DAILY_COST_BUDGET"),
+		MonthlyCostBudget:      envFloat("AI_MONTHLY_COST_BUDGET"),
+		CallerDailyTokenBudget: envInt64("AI_CALLER_DAILY_TOKEN_BUDGET"),
+	}
+}
+
+func envFloat(key string) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		log.Printf("Warning: invalid %s %q, ignored", key, v)
+		return 0
+	}
+	return f
+}
+
+func envInt64(key string) int64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid %s %q, ignored", key, v)
+		return 0
+	}
+	return n
+}
+
+// cost 按单价计算一次调用的费用
+func (cfg AIUsageConfig) cost(usage TokenUsage) float64 {
+	return (float64(usage.PromptTokens)*cfg.PromptPrice + float64(usage.CompletionTokens)*cfg.CompletionPrice) / 1e6
+}
+
+// ---------------- 调用方 ----------------
+
+// AICaller 发起AI调用的请求方，随 context 传递到提供方实现
+type AICaller struct {
+	Endpoint string
+	User     string
+	APIKey   string // API Key 指纹
+	JobID    *uint
+}
+
+type aiCallerKey struct{}
+
+func withAICaller(ctx context.Context, caller AICaller) context.Context {
+	return context.WithValue(ctx, aiCallerKey{}, caller)
+}
+
+func aiCallerFromContext(ctx context.Context) AICaller {
+	caller, _ := ctx.Value(aiCallerKey{}).(AICaller)
+	return caller
+}
+
+// requestAICaller 从请求头 X-User 和 X-API-Key（或 Authorization: Bearer）识别调用方
+func requestAICaller(c *gin.Context, endpoint string) AICaller {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return AICaller{
+		Endpoint: endpoint,
+		User:     requestUser(c),
+		APIKey:   fingerprintAPIKey(strings.TrimSpace(key)),
+	}
+}
+
+// fingerprintAPIKey 只保存 API Key 哈希的前 12 位，足以区分调用方
+func fingerprintAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// ---------------- 用量记录 ----------------
+
+// usageRecordingGenerator 记录每次模型调用的 token 用量、耗时和结果
+type usageRecordingGenerator struct {
+	inner QuestionGenerator
+	model string // 该提供方配置的模型，校验模型与出题模型可能不同
+}
+
+func (g *usageRecordingGenerator) Name() string { return g.inner.Name() }
+
+func (g *usageRecordingGenerator) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	start := time.Now()
+	result, err := g.inner.Generate(ctx, req)
+	recordAIUsage(ctx, g.inner.Name(), g.model, start, result, err)
+	return result, err
+}
+
+func (g *usageRecordingGenerator) GenerateStream(ctx context.Context, req GenerationRequest, onDelta func(delta string) error) (*GenerationResult, error) {
+	start := time.Now()
+	result, err := streamGenerate(ctx, g.inner, req, onDelta)
+	recordAIUsage(ctx, g.inner.Name(), g.model, start, result, err)
+	return result, err
+}
+
+// recordAIUsage 写入一条用量记录，提供方返回了模型名时以返回的为准
+// 写入失败只打印日志，不影响调用结果
+func recordAIUsage(ctx context.Context, provider, model string, start time.Time, result *GenerationResult, err error) {
+	caller := aiCallerFromContext(ctx)
+	usage := models.AIUsage{
+		Provider:  provider,
+		Model:     model,
+		Endpoint:  caller.Endpoint,
+		JobID:     caller.JobID,
+		User:      caller.User,
+		APIKey:    caller.APIKey,
+		Status:    models.UsageSuccess,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if result != nil {
+		if result.Model != "" {
+			usage.Model = result.Model
+		}
+		usage.PromptTokens = result.Usage.PromptTokens
+		usage.CompletionTokens = result.Usage.CompletionTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		usage.Cost = aiUsageConfig.cost(result.Usage)
+	}
+	if err != nil {
+		usage.Status = models.UsageError
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			usage.Status = models.UsageCancelled
+		}
+		usage.Error = err.Error()
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) {
+			usage.StatusCode = providerErr.StatusCode
+		}
+	}
+
+	if err := db.Create(&usage).Error; err != nil {
+		log.Printf("Failed to record AI usage: %v", err)
+	}
+}
+
+// ---------------- 预算 ----------------
+
+type usageTotals struct {
+	Tokens int64
+	Cost   float64
+}
+
+func sumAIUsage(since time.Time, caller *AICaller) usageTotals {
+	var totals usageTotals
+	query := db.Model(&models.AIUsage{}).Where("created_at >= ?", since)
+	if caller != nil {
+		if caller.User != "" {
+			query = query.Where("user = ?", caller.User)
+		} else {
+			query = query.Where("api_key = ?", caller.APIKey)
+		}
+	}
+	query.Select("COALESCE(SUM(total_tokens), 0) AS tokens, COALESCE(SUM(cost), 0) AS cost").Scan(&totals)
+	return totals
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// checkAIBudget 检查预算，超出时返回原因和额度恢复的时间
+func checkAIBudget(caller AICaller) (string, time.Time, bool) {
+	cfg := aiUsageConfig
+	now := time.Now()
+	day, month := startOfDay(now), startOfMonth(now)
+	tomorrow, nextMonth := day.AddDate(0, 0, 1), month.AddDate(0, 1, 0)
+
+	if cfg.DailyTokenBudget > 0 || cfg.DailyCostBudget > 0 {
+		daily := sumAIUsage(day, nil)
+		if cfg.DailyTokenBudget > 0 && daily.Tokens >= cfg.DailyTokenBudget {
+			return fmt.Sprintf("今日AI调用已用 %d token，超出预算 %d", daily.Tokens, cfg.DailyTokenBudget), tomorrow, false
+		}
+		if cfg.DailyCostBudget > 0 && daily.Cost >= cfg.DailyCostBudget {
+			return fmt.Sprintf("今日AI调用费用 %.4f，超出预算 %.4f", daily.Cost, cfg.DailyCostBudget), tomorrow, false
+		}
+	}
+	if cfg.MonthlyTokenBudget > 0 || cfg.MonthlyCostBudget > 0 {
+		monthly := sumAIUsage(month, nil)
+		if cfg.MonthlyTokenBudget > 0 && monthly.Tokens >= cfg.MonthlyTokenBudget {
+			return fmt.Sprintf("本月AI调用已用 %d token，超出预算 %d", monthly.Tokens, cfg.MonthlyTokenBudget), nextMonth, false
+		}
+		if cfg.MonthlyCostBudget > 0 && monthly.Cost >= cfg.MonthlyCostBudget {
+			return fmt.Sprintf("本月AI调用费用 %.4f，超出预算 %.4f", monthly.Cost, cfg.MonthlyCostBudget), nextMonth, false
+		}
+	}
+	if cfg.CallerDailyTokenBudget > 0 && (caller.User != "" || caller.APIKey != "") {
+		own := sumAIUsage(day, &caller)
+		if own.Tokens >= cfg.CallerDailyTokenBudget {
+			return fmt.Sprintf("当前用户今日已用 %d token，超出预算 %d", own.Tokens, cfg.CallerDailyTokenBudget), tomorrow, false
+		}
+	}
+	return "", time.Time{}, true
+}
+
+// aiUsageMiddleware 识别调用方并放入请求 context，预算用完时返回 429
+func aiUsageMiddleware(endpoint string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := requestAICaller(c, endpoint)
+		if reason, resetAt, ok := checkAIBudget(caller); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(resetAt).Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": reason, "reset_at": resetAt})
+			return
+		}
+		c.Request = c.Request.WithContext(withAICaller(c.Request.Context(), caller))
+		c.Next()
+	}
+}
+
+// ---------------- 统计接口 ----------------
+
+type UsageQuery struct {
+	Period  string `form:"period"`   // day 或 month，默认 day
+	From    string `form:"from"`     // 开始日期 2006-01-02，包含
+	To      string `form:"to"`       // 结束日期 2006-01-02，包含
+	GroupBy string `form:"group_by"` // 额外的分组字段：model、user、api_key、endpoint
+	User    string `form:"user"`
+	APIKey  string `form:"api_key"`
+	Model   string `form:"model"`
+}
+
+type UsageAggregate struct {
+	Period           string  `json:"period"`
+	Group            string  `json:"group,omitempty"`
+	Calls            int64   `json:"calls"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// 5.6 AI用量统计，按天或按月汇总
+func getAIUsage(c *gin.Context) {
+	var q UsageQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	// created_at 以 "2006-01-02 15:04:05" 开头的文本保存，截取前缀即可按天或按月分组
+	periodExpr := "substr(created_at, 1, 10)"
+	switch q.Period {
+	case "", "day":
+		q.Period = "day"
+	case "month":
+		periodExpr = "substr(created_at, 1, 7)"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period 只能是 day 或 month"})
+		return
+	}
+
+	groupExpr := "''"
+	switch q.GroupBy {
+	case "":
+	case "model", "user", "api_key", "endpoint":
+		groupExpr = q.GroupBy
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by 只能是 model、user、api_key 或 endpoint"})
+		return
+	}
+
+	query := db.Model(&models.AIUsage{})
+	if q.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", q.From, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from 日期格式应为 2006-01-02"})
+			return
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if q.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", q.To, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to 日期格式应为 2006-01-02"})
+			return
+		}
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	if q.User != "" {
+		query = query.Where("user = ?", q.User)
+	}
+	if q.APIKey != "" {
+		query = query.Where("api_key = ?", q.APIKey)
+	}
+	if q.Model != "" {
+		query = query.Where("model = ?", q.Model)
+	}
+
+	var rows []UsageAggregate
+	err := query.Select(periodExpr + ` AS period, ` + groupExpr + ` AS "group",
+		COUNT(*) AS calls,
+		SUM(CASE WHEN status = 'success' THEN 0 ELSE 1 END) AS errors,
+		SUM(prompt_tokens) AS prompt_tokens,
+		SUM(completion_tokens) AS completion_tokens,
+		SUM(total_tokens) AS total_tokens,
+		SUM(cost) AS cost,
+		AVG(latency_ms) AS avg_latency_ms`).
+		Group(periodExpr + ", " + groupExpr).
+		Order("period DESC").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []UsageAggregate{}
+	}
+
+	now := time.Now()
+	daily, monthly := sumAIUsage(startOfDay(now), nil), sumAIUsage(startOfMonth(now), nil)
+	c.JSON(http.StatusOK, gin.H{
+		"data":   rows,
+		"period": q.Period,
+		"budget": gin.H{
+			"daily_tokens":         daily.Tokens,
+			"daily_token_budget":   aiUsageConfig.DailyTokenBudget,
+			"daily_cost":           daily.Cost,
+			"daily_cost_budget":    aiUsageConfig.DailyCostBudget,
+			"monthly_tokens":       monthly.Tokens,
+			"monthly_token_budget": aiUsageConfig.MonthlyTokenBudget,
+			"monthly_cost":         monthly.Cost,
+			"monthly_cost_budget":  aiUsageConfig.MonthlyCostBudget,
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-server/models"
+)
+
+// useBudget 清空用量记录并使用给定的预算，测试结束后恢复原配置
+func useBudget(t *testing.T, cfg AIUsageConfig) {
+	t.Helper()
+	if err := db.Exec("DELETE FROM ai_usages").Error; err != nil {
+		t.Fatal(err)
+	}
+	saved := aiUsageConfig
+	aiUsageConfig = cfg
+	t.Cleanup(func() { aiUsageConfig = saved })
+}
+
+func addUsage(t *testing.T, user string, tokens int, cost float64, at time.Time) {
+	t.Helper()
+	usage := models.AIUsage{User: user, TotalTokens: tokens, Cost: cost, Status: models.UsageSuccess, CreatedAt: at}
+	if err := db.Create(&usage).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUsageRecordsProviderModel(t *testing.T) {
+	useBudget(t, AIUsageConfig{})
+	useFixture(t, "valid_choice")
+
+	// 校验模型与出题模型不同，调用失败没有返回模型名时也应记为校验模型
+	cfg := aiConfig
+	cfg.Model = "missing_verifier"
+	verifier, err := newQuestionGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Generate(context.Background(), GenerationRequest{Task: TaskVerify, Messages: userPrompt("1+1=?")}); err == nil {
+		t.Fatal("want error from unknown fixture")
+	}
+
+	var usage models.AIUsage
+	if err := db.Last(&usage).Error; err != nil {
+		t.Fatal(err)
+	}
+	if usage.Model != "missing_verifier" || usage.Status != models.UsageError || usage.StatusCode != http.StatusNotFound {
+		t.Errorf("usage = %+v, want model missing_verifier with status 404", usage)
+	}
+}
+
+func TestCheckAIBudget(t *testing.T) {
+	now := time.Now()
+	day, month := startOfDay(now), startOfMonth(now)
+	tomorrow, nextMonth := day.AddDate(0, 0, 1), month.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		cfg    AIUsageConfig
+		caller AICaller
+		want   string
+		reset  time.Time
+	}{
+		{"no budget", AIUsageConfig{}, AICaller{}, "", time.Time{}},
+		{"daily tokens", AIUsageConfig{DailyTokenBudget: 300}, AICaller{}, "今日AI调用已用 300 token", tomorrow},
+		{"daily tokens left", AIUsageConfig{DailyTokenBudget: 301}, AICaller{}, "", time.Time{}},
+		{"daily cost", AIUsageConfig{DailyCostBudget: 0.375}, AICaller{}, "今日AI调用费用 0.3750", tomorrow},
+		// 上月的用量不计入本月
+		{"monthly tokens", AIUsageConfig{MonthlyTokenBudget: 800}, AICaller{}, "本月AI调用已用 800 token", nextMonth},
+		{"monthly tokens left", AIUsageConfig{MonthlyTokenBudget: 801}, AICaller{}, "", time.Time{}},
+		{"monthly cost", AIUsageConfig{MonthlyCostBudget: 0.875}, AICaller{}, "本月AI调用费用 0.8750", nextMonth},
+		{"caller over budget", AIUsageConfig{CallerDailyTokenBudget: 200}, AICaller{User: "alice"}, "当前用户今日已用 200 token", tomorrow},
+		{"other caller", AIUsageConfig{CallerDailyTokenBudget: 200}, AICaller{User: "bob"}, "", time.Time{}},
+		{"anonymous caller", AIUsageConfig{CallerDailyTokenBudget: 200}, AICaller{}, "", time.Time{}},
+	}
+	for _, tt := range tests {
+		useBudget(t, tt.cfg)
+		addUsage(t, "alice", 200, 0.25, now)
+		addUsage(t, "bob", 100, 0.125, now)
+		addUsage(t, "alice", 500, 0.5, month)
+		addUsage(t, "alice", 1000, 1, month.Add(-time.Hour))
+
+		reason, resetAt, ok := checkAIBudget(tt.caller)
+		if ok != (tt.want == "") || !strings.HasPrefix(reason, tt.want) || !resetAt.Equal(tt.reset) {
+			t.Errorf("%s: reason = %q, reset = %v, ok = %v, want %q until %v", tt.name, reason, resetAt, ok, tt.want, tt.reset)
+		}
+	}
+}
+
+func TestUsageMiddlewareRejectsOverBudget(t *testing.T) {
+	useFixture(t, "valid_choice")
+	useBudget(t, AIUsageConfig{DailyTokenBudget: 100})
+	addUsage(t, "alice", 100, 0, time.Now())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ai/generate", strings.NewReader(`{"type":"single_choice","count":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+
+	var resp struct {
+		Error   string    `json:"error"`
+		ResetAt time.Time `json:"reset_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := startOfDay(time.Now()).AddDate(0, 0, 1)
+	if !resp.ResetAt.Equal(tomorrow) || !strings.Contains(resp.Error, "超出预算 100") {
+		t.Errorf("response = %+v, want reset at %v", resp, tomorrow)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > 24*60*60 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if n := len(testStub.Requests()); n != 0 {
+		t.Errorf("model called %d times over budget", n)
+	}
+}
@@ -0,0 +1,234 @@
+// Package aistub 实现一个兼容 OpenAI chat/completions 协议的本地服务，
+// 按请求中的 model 名称读取 fixture 文件并回放录制的响应，用于在不调用付费接口的情况下测试解析流程。
+//
+// fixture 为 <dir>/<model>.json，格式见 Fixture。同一个 fixture 被多次请求时依次返回 responses 中的响应，
+// 用完后重复最后一个，便于模拟“第一次输出有误、修正后正确”这类多轮对话。
+package aistub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// streamChunkRunes 流式返回时每个分片的字符数
+const streamChunkRunes = 16
+
+var fixtureNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Fixture 一组录制的响应
+type Fixture struct {
+	Description string     `json:"description"`
+	Responses   []Response `json:"responses"`
+}
+
+// Response 一次录制的响应
+type Response struct {
+	// Status HTTP状态码，默认 200
+	Status int `json:"status"`
+	// Content 助手消息内容，按 chat/completions 的格式包装后返回
+	Content string `json:"content"`
+	// Body 设置后原样作为响应体返回，忽略 Content，用于模拟错误响应或不符合协议的响应
+	Body string `json:"body"`
+	// Usage 返回的 token 用量，不设置时按字符数估算
+	Usage *Usage `json:"usage"`
+	// Model 响应中的模型名，不设置时与请求相同，用于模拟提供方返回带版本号的模型名
+	Model string `json:"model"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request 服务收到的请求，供测试断言
+type Request struct {
+	Model    string                 `json:"model"`
+	Stream   bool                   `json:"stream"`
+	Messages []Message              `json:"messages"`
+	Body     map[string]interface{} `json:"-"`
+}
+
+// Server 回放 fixture 的服务，实现 http.Handler
+type Server struct {
+	dir string
+
+	mu       sync.Mutex
+	calls    map[string]int
+	requests []Request
+}
+
+func New(dir string) *Server {
+	return &Server{dir: dir, calls: make(map[string]int)}
+}
+
+// Requests 返回已收到的请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset 清空请求记录和回放进度
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = make(map[string]int)
+	s.requests = nil
+}
+
+// LoadFixture 读取 fixture 文件
+func LoadFixture(dir, name string) (*Fixture, error) {
+	if !fixtureNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid fixture name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", name, err)
+	}
+	if len(f.Responses) == 0 {
+		return nil, fmt.Errorf("fixture %s has no responses", name)
+	}
+	return &f, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.Method+" "+r.URL.Path)
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	var req Request
+	b, _ := json.Marshal(body)
+	json.Unmarshal(b, &req)
+	req.Body = body
+
+	fixture, err := LoadFixture(s.dir, req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	n := s.calls[req.Model]
+	s.calls[req.Model] = n + 1
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if n >= len(fixture.Responses) {
+		n = len(fixture.Responses) - 1
+	}
+	resp := fixture.Responses[n]
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.Body != "" || status != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(resp.Body))
+		return
+	}
+
+	usage := resp.Usage
+	if usage == nil {
+		usage = estimateUsage(req.Messages, resp.Content)
+	}
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	if req.Stream {
+		writeStream(w, req, model, resp.Content, usage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     "chatcmpl-stub",
+		"object": "chat.completion",
+		"model":  model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       Message{Role: "assistant", Content: resp.Content},
+			"finish_reason": "stop",
+		}},
+		"usage": usage,
+	})
+}
+
+// writeStream 按 SSE 格式分片返回内容，请求了 stream_options.include_usage 时最后附带用量
+func writeStream(w http.ResponseWriter, req Request, model, content string, usage *Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(v interface{}) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	runes := []rune(content)
+	for i := 0; i < len(runes); i += streamChunkRunes {
+		end := i + streamChunkRunes
+		if end > len(runes) {
+			end = len(runes)
+		}
+		send(map[string]interface{}{
+			"model":   model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{"content": string(runes[i:end])}}},
+		})
+	}
+	send(map[string]interface{}{
+		"model":   model,
+		"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{}, "finish_reason": "stop"}},
+	})
+	if opts, ok := req.Body["stream_options"].(map[string]interface{}); ok && opts["include_usage"] == true {
+		send(map[string]interface{}{"model": model, "choices": []interface{}{}, "usage": usage})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// estimateUsage 按字符数估算用量
+func estimateUsage(messages []Message, content string) *Usage {
+	u := &Usage{CompletionTokens: utf8.RuneCountInString(content)}
+	for _, m := range messages {
+		u.PromptTokens += utf8.RuneCountInString(m.Content)
+	}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "aistub_error"},
+	})
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifyConcurrency 同时进行答案校验的数量
+const verifyConcurrency = 3
+
+var (
+	// aiVerifier 答案校验使用的模型，未单独配置时与出题使用同一个
+	aiVerifier QuestionGenerator
+	// aiVerifyByDefault 出题时是否默认校验答案，由 AI_VERIFY 配置
+	aiVerifyByDefault bool
+)
+
+// AnswerVerification 一道题目的答案校验结果
+type AnswerVerification struct {
+	Status         models.VerificationStatus `json:"status"`
+	Answer         string                    `json:"answer"`          // 题目给出的答案
+	VerifierAnswer string                    `json:"verifier_answer"` // 校验模型独立作答的答案
+	Reasoning      string                    `json:"reasoning,omitempty"`
+	Model          string                    `json:"model,omitempty"`
+	Error          string                    `json:"error,omitempty"`
+}
+
+type VerifyBatchRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=50"`
+}
+
+// VerifyResult 批量校验时单道题目的结果
+type VerifyResult struct {
+	QuestionID   uint                         `json:"question_id"`
+	Verification *models.QuestionVerification `json:"verification,omitempty"`
+	Error        string                       `json:"error,omitempty"`
+}
+
+// initAnswerVerifier 读取 AI_VERIFY 以及 AI_VERIFY_PROVIDER、AI_VERIFY_BASE_URL、AI_VERIFY_API_KEY、AI_VERIFY_MODEL。
+// 后四项都为空时复用出题模型，否则在出题配置的基础上覆盖这些字段，可以用另一家提供方交叉校验。
+func initAnswerVerifier() error {
+	if v := os.Getenv("AI_VERIFY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Warning: invalid AI_VERIFY %q, verification disabled by default", v)
+		}
+		aiVerifyByDefault = enabled
+	}
+
+	cfg := aiConfig
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("AI_VERIFY_PROVIDER")))
+	baseURL := strings.TrimSpace(os.Getenv("AI_VERIFY_BASE_URL"))
+	apiKey := os.Getenv("AI_VERIFY_API_KEY")
+	model := strings.TrimSpace(os.Getenv("AI_VERIFY_MODEL"))
+	if provider == "" && baseURL == "" && apiKey == "" && model == "" {
+		aiVerifier = aiGenerator
+		return nil
+	}
+
+	if provider != "" && provider != cfg.Provider {
+		// 换了提供方时，地址、密钥和模型都不能沿用
+		cfg.Provider, cfg.BaseURL, cfg.APIKey, cfg.Model = provider, "", "", ""
+	}
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if apiKey != "" {
+		cfg.APIKey = apiKey
+	}
+	if model != "" {
+		cfg.Model = model
+	}
+	applyProviderDefaults(&cfg)
+
+	var err error
+	aiVerifier, err = newQuestionGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("AI answer verifier: %s, model: %s", aiVerifier.Name(), cfg.Model)
+	return nil
+}
+
+// verify 本次出题是否校验答案
+func (r AIGenerateRequest) verify() bool {
+	if r.Verify != nil {
+		return *r.Verify
+	}
+	return aiVerifyByDefault
+}
+
+// isChoiceQuestion 只有选择题的答案可以自动比对
+func isChoiceQuestion(t models.QuestionType) bool {
+	return t == models.SingleChoice || t == models.MultipleChoice
+}
+
+// normalizeChoiceAnswer 统一选择题答案的写法：去空格、选项排序，与选项 ID 只有大小写不同的按选项 ID 写，
+// 其余转大写，如 "b, a" -> "A,B"
+func normalizeChoiceAnswer(answer string, options models.JSON) string {
+	var letters []string
+	for _, part := range strings.FieldsFunc(answer, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ' '
+	}) {
+		if part == "" {
+			continue
+		}
+		id := strings.ToUpper(part)
+		for k := range options {
+			if strings.EqualFold(k, part) {
+				id = k
+				break
+			}
+		}
+		letters = append(letters, id)
+	}
+	sort.Strings(letters)
+	return strings.Join(letters, ",")
+}
+
+// optionIDs 选项 ID，按字母顺序排列
+func optionIDs(options models.JSON) []string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// buildVerifyPrompt 只给出题目和选项，不给答案
+func buildVerifyPrompt(t models.QuestionType, content string, options models.JSON, language string) string {
+	keys := optionIDs(options)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "请独立解答下面这道%s题目。\n\n", language)
+	if t == models.MultipleChoice {
+		sb.WriteString("题目类型：多选题（可能有多个正确选项）\n")
+	} else {
+		sb.WriteString("题目类型：单选题（只有一个正确选项）\n")
+	}
+	fmt.Fprintf(&sb, "题目：%s\n选项：\n", content)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s. %v\n", k, options[k])
+	}
+	example := "A,C"
+	if len(keys) > 0 {
+		example = keys[0] + "," + keys[len(keys)-1]
+	}
+	fmt.Fprintf(&sb, `
+先逐项分析，再给出答案。只返回一个JSON对象，不要包含其他文字：
+{"answer": "正确选项的ID（%s），多选用逗号分隔，如 %s", "reasoning": "简要的分析过程"}`, strings.Join(keys, "、"), example)
+	return sb.String()
+}
+
+// verifyResponseSchema 校验输出的 schema，开启结构化输出时使用，答案只能是题目中的选项 ID
+func verifyResponseSchema(options models.JSON) *ResponseSchema {
+	return &ResponseSchema{
+		Name: "answer_verification",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"answer":    choiceSchema(optionIDs(options), true)["answer"],
+				"reasoning": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"answer", "reasoning"},
+		},
+	}
+}
+
+// parseVerifyResponse 解析校验模型的输出
+func parseVerifyResponse(content string, options models.JSON) (answer, reasoning string, err error) {
+	value, err := parseJSON5(extractJSONContent(content))
+	if err != nil {
+		return "", "", fmt.Errorf("无法解析校验结果: %w", err)
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return "", "", errors.New("无法解析校验结果: 不是JSON对象")
+	}
+
+	switch v := obj["answer"].(type) {
+	case string:
+		answer = v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, a := range v {
+			parts = append(parts, fmt.Sprintf("%v", a))
+		}
+		answer = strings.Join(parts, ",")
+	}
+	answer = normalizeChoiceAnswer(answer, options)
+	if answer == "" {
+		return "", "", errors.New("校验结果缺少答案")
+	}
+	reasoning, _ = obj["reasoning"].(string)
+	return answer, strings.TrimSpace(reasoning), nil
+}
+
+// verifyAnswer 让校验模型不看答案独立作答，并与题目答案比对
+func verifyAnswer(ctx context.Context, t models.QuestionType, content string, options models.JSON, language, answer string) AnswerVerification {
+	out := AnswerVerification{Answer: answer}
+	genReq := GenerationRequest{
+		Task:     TaskVerify,
+		Messages: userPrompt(buildVerifyPrompt(t, content, options, language)),
+	}
+	if aiConfig.StructuredOutput != StructuredOutputNone {
+		genReq.Schema = verifyResponseSchema(options)
+	}
+
+	result, err := aiVerifier.Generate(ctx, genReq)
+	if err != nil {
+		out.Status, out.Error = models.VerificationError, err.Error()
+		return out
+	}
+	out.Model = result.Model
+
+	out.VerifierAnswer, out.Reasoning, err = parseVerifyResponse(result.Content, options)
+	if err != nil {
+		out.Status, out.Error = models.VerificationError, err.Error()
+		return out
+	}
+	if out.VerifierAnswer == normalizeChoiceAnswer(answer, options) {
+		out.Status = models.VerificationAgreed
+	} else {
+		out.Status = models.VerificationDisagreed
+	}
+	return out
+}
+
+// verifyGeneratedQuestions 校验生成的选择题答案，结果记录在题目上，答案不一致时返回警告
+func verifyGeneratedQuestions(ctx context.Context, questions []GeneratedQuestion) []string {
+	sem := make(chan struct{}, verifyConcurrency)
+	var wg sync.WaitGroup
+	for i := range questions {
+		q := &questions[i]
+		if !isChoiceQuestion(q.Type) || q.Provenance == ProvenancePlaceholder {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			v := verifyAnswer(ctx, q.Type, q.Content, q.Options, q.Language, q.Answer)
+			q.Verification = &v
+		}()
+	}
+	wg.Wait()
+
+	var warnings []string
+	for i, q := range questions {
+		if q.Verification == nil {
+			continue
+		}
+		switch q.Verification.Status {
+		case models.VerificationDisagreed:
+			warnings = append(warnings, fmt.Sprintf("第%d题答案可能有误：题目答案为 %s，校验模型的答案为 %s",
+				i+1, q.Verification.Answer, q.Verification.VerifierAnswer))
+		case models.VerificationError:
+			warnings = append(warnings, fmt.Sprintf("第%d题答案校验失败: %s", i+1, q.Verification.Error))
+		}
+	}
+	return warnings
+}
+
+// 9.1 批量校验题库中题目的答案，每次校验都会保存记录
+func batchVerifyQuestions(c *gin.Context) {
+	var req VerifyBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var questions []models.Question
+	if err := db.Where("id IN ?", req.IDs).Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	byID := make(map[uint]models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	ctx := c.Request.Context()
+	results := make([]VerifyResult, len(req.IDs))
+	sem := make(chan struct{}, verifyConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range req.IDs {
+		results[i].QuestionID = id
+		q, ok := byID[id]
+		if !ok {
+			results[i].Error = "Question not found"
+			continue
+		}
+		if !isChoiceQuestion(q.Type) {
+			results[i].Error = "只能校验选择题的答案"
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, q models.Question) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			v := verifyAnswer(ctx, q.Type, q.Content, q.Options, q.Language, q.Answer)
+			record := models.QuestionVerification{
+				QuestionID:     q.ID,
+				Status:         v.Status,
+				Answer:         v.Answer,
+				VerifierAnswer: v.VerifierAnswer,
+				Reasoning:      v.Reasoning,
+				Model:          v.Model,
+				Error:          v.Error,
+			}
+			if err := db.Create(&record).Error; err != nil {
+				results[i].Error = "数据库错误: " + err.Error()
+				return
+			}
+			results[i].Verification = &record
+		}(i, q)
+	}
+	wg.Wait()
+
+	summary := map[models.VerificationStatus]int{}
+	for _, r := range results {
+		if r.Verification != nil {
+			summary[r.Verification.Status]++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      results,
+		"agreed":    summary[models.VerificationAgreed],
+		"disagreed": summary[models.VerificationDisagreed],
+		"errors":    summary[models.VerificationError],
+	})
+}
+
+// 9.2 校验记录列表，可按状态和题目筛选
+func getVerifications(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+	offset := (pagination.Page - 1) * pagination.PageSize
+
+	query := db.Model(&models.QuestionVerification{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if questionID := c.Query("question_id"); questionID != "" {
+		query = query.Where("question_id = ?", questionID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var verifications []models.QuestionVerification
+	if err := query.Order("id DESC").Offset(offset).Limit(pagination.PageSize).Find(&verifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  verifications,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
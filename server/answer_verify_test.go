@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifySchemaUsesOptionIDs(t *testing.T) {
+	options := models.JSON{"opt1": "map", "opt2": "int", "opt3": "chan"}
+	schema := verifyResponseSchema(options).Schema
+	for answer, valid := range map[string]bool{"opt2": true, "opt1,opt3": true, "A": false, "opt4": false} {
+		errs := validateJSONSchema(schema, map[string]interface{}{"answer": answer, "reasoning": ""}, "")
+		if (len(errs) == 0) != valid {
+			t.Errorf("answer %q: errors = %v, want valid = %v", answer, errs, valid)
+		}
+	}
+
+	// 与选项 ID 只有大小写不同的答案按选项 ID 写
+	if got := normalizeChoiceAnswer("OPT3, opt1", options); got != "opt1,opt3" {
+		t.Errorf("normalize = %q, want opt1,opt3", got)
+	}
+	if got := normalizeChoiceAnswer("b，a", models.JSON{"A": "x", "B": "y"}); got != "A,B" {
+		t.Errorf("normalize = %q, want A,B", got)
+	}
+	if prompt := buildVerifyPrompt(models.MultipleChoice, "哪些类型的零值是 nil", options, "Go"); !strings.Contains(prompt, "opt1、opt2、opt3") {
+		t.Errorf("prompt does not list the option IDs:\n%s", prompt)
+	}
+}
+
+func TestBatchVerify(t *testing.T) {
+	resetData(t)
+	useFixture(t, "verify_answers")
+	choice := func(content, answer string) gin.H {
+		return gin.H{"type": "single_choice", "content": content, "difficulty": "easy", "language": "Go",
+			"payload": gin.H{
+				"options": []gin.H{{"id": "opt1", "text": content + " map"}, {"id": "opt2", "text": content + " int"}, {"id": "opt3", "text": content + " chan"}},
+				"answers": []string{answer},
+			}}
+	}
+	agreed := createQuestion(t, "", choice("哪个类型的零值不是 nil", "opt2"))
+	disagreed := createQuestion(t, "", choice("哪个类型是值类型", "opt1"))
+	other := createQuestion(t, "", gin.H{"type": "true_false", "content": "nil 切片可以 append", "difficulty": "easy", "language": "Go",
+		"payload": gin.H{"correct": true}})
+
+	var resp struct {
+		Data      []VerifyResult `json:"data"`
+		Agreed    int            `json:"agreed"`
+		Disagreed int            `json:"disagreed"`
+		Errors    int            `json:"errors"`
+	}
+	ids := []uint{agreed.ID, disagreed.ID, other.ID, 99999}
+	if code := apiRequest(t, http.MethodPost, "/api/questions/verify", "", gin.H{"ids": ids}, &resp); code != http.StatusOK {
+		t.Fatalf("verify: status = %d", code)
+	}
+	if resp.Agreed != 1 || resp.Disagreed != 1 || len(resp.Data) != 4 {
+		t.Fatalf("agreed = %d, disagreed = %d, results = %+v", resp.Agreed, resp.Disagreed, resp.Data)
+	}
+	if v := resp.Data[1].Verification; v == nil || v.Status != models.VerificationDisagreed || v.Answer != "opt1" || v.VerifierAnswer != "opt2" {
+		t.Errorf("disagreed verification = %+v", v)
+	}
+	if resp.Data[2].Error == "" || resp.Data[3].Error != "Question not found" {
+		t.Errorf("errors = %q, %q", resp.Data[2].Error, resp.Data[3].Error)
+	}
+
+	var count int64
+	db.Model(&models.QuestionVerification{}).Count(&count)
+	if count != 2 {
+		t.Errorf("saved %d verifications, want 2", count)
+	}
+}
+
+func TestGenerateReportsAnswerMismatch(t *testing.T) {
+	resetData(t)
+	useFixture(t, "verify_mismatch")
+	body := choiceRequest(1)
+	body["verify"] = true
+	code, resp := generate(t, body)
+	if code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("status = %d, questions = %d (%s)", code, len(resp.Data), resp.Error)
+	}
+	if v := resp.Data[0].Verification; v == nil || v.Status != models.VerificationDisagreed || v.VerifierAnswer != "B" {
+		t.Errorf("verification = %+v, want disagreed with B", v)
+	}
+	if !containsWarning(resp.Warnings, "第1题答案可能有误：题目答案为 A，校验模型的答案为 B") {
+		t.Errorf("warnings = %v", resp.Warnings)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BankRequest 新建或修改题库的请求
+type BankRequest struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Visibility  models.BankVisibility `json:"visibility" binding:"omitempty,oneof=private public"`
+}
+
+// BankQuestionsRequest 向题库添加或移除题目
+type BankQuestionsRequest struct {
+	QuestionIDs []uint `json:"question_ids" binding:"required,min=1"`
+}
+
+// BankSummary 题库及其中未删除的题目数量
+type BankSummary struct {
+	models.QuestionBank
+	QuestionCount int64 `json:"question_count"`
+}
+
+// requestUser 调用方的用户名，取自请求头 X-User；浏览器只能发送 ASCII 请求头，中文用户名按 URL 编码传递
+func requestUser(c *gin.Context) string {
+	user := strings.TrimSpace(c.GetHeader("X-User"))
+	if decoded, err := url.PathUnescape(user); err == nil {
+		user = strings.TrimSpace(decoded)
+	}
+	return user
+}
+
+// canViewBank 公开题库、没有所有者的题库和自己的题库可见
+func canViewBank(bank models.QuestionBank, user string) bool {
+	return bank.Visibility == models.BankPublic || canEditBank(bank, user)
+}
+
+// canEditBank 只有所有者可以修改题库及其中的题目，没有所有者的题库所有人都可以修改
+func canEditBank(bank models.QuestionBank, user string) bool {
+	return bank.Owner == "" || bank.Owner == user
+}
+
+// lookupBank 查找题库并检查权限，不可见的题库按不存在处理，返回错误时附带HTTP状态码
+func lookupBank(id uint, user string, edit bool) (*models.QuestionBank, int, error) {
+	var bank models.QuestionBank
+	if err := db.First(&bank, id).Error; err != nil || !canViewBank(bank, user) {
+		return nil, http.StatusNotFound, errors.New("题库不存在")
+	}
+	if edit && !canEditBank(bank, user) {
+		return nil, http.StatusForbidden, errors.New("只有题库所有者可以修改该题库")
+	}
+	return &bank, 0, nil
+}
+
+// lookupBankParam 按路径参数 id 查找题库，失败时直接写入错误响应
+func lookupBankParam(c *gin.Context, edit bool) (*models.QuestionBank, bool) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	bank, status, err := lookupBank(id, requestUser(c), edit)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return bank, true
+}
+
+// lookupBankQuery 按查询参数 bank_id 查找题库，没有该参数时返回 nil
+func lookupBankQuery(c *gin.Context, edit bool) (*models.QuestionBank, bool) {
+	raw := c.Query("bank_id")
+	if raw == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID: " + raw})
+		return nil, false
+	}
+	bank, status, err := lookupBank(uint(id), requestUser(c), edit)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return bank, true
+}
+
+// bankFilterSubquery 题库中所有题目ID的子查询
+func bankFilterSubquery(bankID uint) *gorm.DB {
+	return db.Model(&models.QuestionBankItem{}).Select("question_id").Where("bank_id = ?", bankID)
+}
+
+// addQuestionsToBank 把题目加入题库，已在题库中的题目忽略，返回新加入的数量
+func addQuestionsToBank(tx *gorm.DB, bankID uint, questionIDs []uint) (int64, error) {
+	if len(questionIDs) == 0 {
+		return 0, nil
+	}
+	items := make([]models.QuestionBankItem, len(questionIDs))
+	for i, id := range questionIDs {
+		items[i] = models.QuestionBankItem{BankID: bankID, QuestionID: id}
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&items)
+	return result.RowsAffected, result.Error
+}
+
+// bankQuestionCounts 统计各题库中未删除的题目数量
+func bankQuestionCounts(bankIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		BankID uint
+		Count  int64
+	}
+	err := db.Model(&models.QuestionBankItem{}).
+		Select("question_bank_items.bank_id, count(*) AS count").
+		Joins("JOIN questions ON questions.id = question_bank_items.question_id AND questions.deleted_at IS NULL").
+		Where("question_bank_items.bank_id IN ?", bankIDs).
+		Group("question_bank_items.bank_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		counts[r.BankID] = r.Count
+	}
+	return counts, nil
+}
+
+// 14.1 题库列表，返回调用方可见的题库，支持按名称关键字和所有者筛选
+func getBanks(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+
+	query := db.Model(&models.QuestionBank{}).
+		Where("visibility = ? OR owner = '' OR owner = ?", models.BankPublic, requestUser(c))
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if owner := c.Query("owner"); owner != "" {
+		query = query.Where("owner = ?", owner)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var banks []models.QuestionBank
+	if err := query.Order("id").Offset((pagination.Page - 1) * pagination.PageSize).Limit(pagination.PageSize).Find(&banks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := make([]uint, len(banks))
+	for i, b := range banks {
+		ids[i] = b.ID
+	}
+	counts, err := bankQuestionCounts(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	data := make([]BankSummary, len(banks))
+	for i, b := range banks {
+		data[i] = BankSummary{QuestionBank: b, QuestionCount: counts[b.ID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
+
+// 14.2 新建题库，所有者为请求头 X-User 指定的用户
+func createBank(c *gin.Context) {
+	var req BankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	bank := models.QuestionBank{Owner: requestUser(c)}
+	if err := applyBankRequest(&bank, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Create(&bank).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": BankSummary{QuestionBank: bank}})
+}
+
+// applyBankRequest 校验请求并写入题库字段，未指定可见范围时为私有
+func applyBankRequest(bank *models.QuestionBank, req BankRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("题库名称不能为空")
+	}
+	bank.Name = name
+	bank.Description = req.Description
+	bank.Visibility = req.Visibility
+	if bank.Visibility == "" {
+		bank.Visibility = models.BankPrivate
+	}
+	return nil
+}
+
+// 14.3 题库详情
+func getBank(c *gin.Context) {
+	bank, ok := lookupBankParam(c, false)
+	if !ok {
+		return
+	}
+	counts, err := bankQuestionCounts([]uint{bank.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": BankSummary{QuestionBank: *bank, QuestionCount: counts[bank.ID]}})
+}
+
+// 14.4 修改题库名称、描述和可见范围
+func updateBank(c *gin.Context) {
+	var req BankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	bank, ok := lookupBankParam(c, true)
+	if !ok {
+		return
+	}
+	if err := applyBankRequest(bank, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Save(bank).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bank})
+}
+
+// 14.5 删除题库，题库中的题目保留
+func deleteBank(c *gin.Context) {
+	bank, ok := lookupBankParam(c, true)
+	if !ok {
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bank_id = ?", bank.ID).Delete(&models.QuestionBankItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(bank).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bank deleted successfully"})
+}
+
+// 14.6 向题库添加题目，已在题库中的题目忽略
+func addBankQuestions(c *gin.Context) {
+	var req BankQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	bank, ok := lookupBankParam(c, true)
+	if !ok {
+		return
+	}
+
+	ids := uniqueUints(req.QuestionIDs)
+	var count int64
+	db.Model(&models.Question{}).Where("id IN ?", ids).Count(&count)
+	if int(count) != len(ids) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "部分题目不存在"})
+		return
+	}
+	added, err := addQuestionsToBank(db, bank.ID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added})
+}
+
+// 14.7 从题库移除题目，题目本身保留
+func removeBankQuestions(c *gin.Context) {
+	var req BankQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	bank, ok := lookupBankParam(c, true)
+	if !ok {
+		return
+	}
+
+	result := db.Where("bank_id = ? AND question_id IN ?", bank.ID, req.QuestionIDs).Delete(&models.QuestionBankItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": result.RowsAffected})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createTestBank 以 user 的身份新建题库，失败时终止测试
+func createTestBank(t *testing.T, user string, body gin.H) BankSummary {
+	t.Helper()
+	var resp struct {
+		Data  BankSummary `json:"data"`
+		Error string      `json:"error"`
+	}
+	if code := apiRequest(t, http.MethodPost, "/api/banks", user, body, &resp); code != http.StatusCreated {
+		t.Fatalf("create bank: status = %d, error = %s", code, resp.Error)
+	}
+	return resp.Data
+}
+
+func TestBankVisibilityAndOwnership(t *testing.T) {
+	resetData(t)
+	bank := createTestBank(t, "alice", gin.H{"name": "Go 基础"})
+	if bank.Owner != "alice" || bank.Visibility != models.BankPrivate {
+		t.Fatalf("bank = %+v, want private bank owned by alice", bank.QuestionBank)
+	}
+	path := fmt.Sprintf("/api/banks/%d", bank.ID)
+
+	// 私有题库对其他用户不可见
+	if code := apiRequest(t, http.MethodGet, path, "bob", nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob get private bank: status = %d, want 404", code)
+	}
+	if code := apiRequest(t, http.MethodGet, path, "alice", nil, nil); code != http.StatusOK {
+		t.Errorf("alice get own bank: status = %d, want 200", code)
+	}
+	var list struct {
+		Data  []BankSummary `json:"data"`
+		Total int64         `json:"total"`
+	}
+	apiRequest(t, http.MethodGet, "/api/banks", "bob", nil, &list)
+	if list.Total != 0 {
+		t.Errorf("bob bank list: total = %d, want 0", list.Total)
+	}
+
+	// 公开后其他用户可以查看，但不能修改
+	update := gin.H{"name": "Go 基础", "visibility": models.BankPublic}
+	if code := apiRequest(t, http.MethodPut, path, "alice", update, nil); code != http.StatusOK {
+		t.Fatalf("alice publish bank: status = %d", code)
+	}
+	if code := apiRequest(t, http.MethodGet, path, "bob", nil, nil); code != http.StatusOK {
+		t.Errorf("bob get public bank: status = %d, want 200", code)
+	}
+	tests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodPut, path, gin.H{"name": "改名"}},
+		{http.MethodPost, path + "/questions", gin.H{"question_ids": []uint{1}}},
+		{http.MethodDelete, path + "/questions", gin.H{"question_ids": []uint{1}}},
+		{http.MethodDelete, path, nil},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if code := apiRequest(t, tt.method, tt.path, "bob", tt.body, &resp); code != http.StatusForbidden {
+			t.Errorf("bob %s %s: status = %d, want 403 (%s)", tt.method, tt.path, code, resp.Error)
+		}
+	}
+
+	if code := apiRequest(t, http.MethodPost, "/api/banks", "alice", gin.H{"name": "  "}, nil); code != http.StatusBadRequest {
+		t.Errorf("blank bank name: status = %d, want 400", code)
+	}
+}
+
+func TestBankQuestionsAndScopedDelete(t *testing.T) {
+	resetData(t)
+	bank := createTestBank(t, "alice", gin.H{"name": "并发"})
+	path := fmt.Sprintf("/api/banks/%d/questions", bank.ID)
+	inBank := createQuestion(t, "alice", choiceQuestion("goroutine 如何退出"))
+	other := createQuestion(t, "alice", choiceQuestion("map 是否并发安全"))
+
+	var added struct {
+		Added int `json:"added"`
+	}
+	body := gin.H{"question_ids": []uint{inBank.ID, inBank.ID}}
+	if code := apiRequest(t, http.MethodPost, path, "alice", body, &added); code != http.StatusOK || added.Added != 1 {
+		t.Fatalf("add questions: status = %d, added = %d, want 1", code, added.Added)
+	}
+	if code := apiRequest(t, http.MethodPost, path, "alice", gin.H{"question_ids": []uint{99999}}, nil); code != http.StatusBadRequest {
+		t.Errorf("add missing question: status = %d, want 400", code)
+	}
+
+	listPath := fmt.Sprintf("/api/questions?status=all&bank_id=%d", bank.ID)
+	var resp listResponse
+	apiRequest(t, http.MethodGet, listPath, "alice", nil, &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != inBank.ID {
+		t.Errorf("bank listing = %d questions, want only %d", len(resp.Data), inBank.ID)
+	}
+	if code := apiRequest(t, http.MethodGet, listPath, "bob", nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob list private bank: status = %d, want 404", code)
+	}
+
+	// 指定题库删除时，不在题库中的题目不能删除
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	scoped := fmt.Sprintf("/api/questions/%d?bank_id=%d", other.ID, bank.ID)
+	if code := apiRequest(t, http.MethodDelete, scoped, "alice", nil, &errResp); code != http.StatusNotFound || errResp.Error != "题目不在该题库中" {
+		t.Errorf("delete outside bank: status = %d, error = %q", code, errResp.Error)
+	}
+	scoped = fmt.Sprintf("/api/questions/%d?bank_id=%d", inBank.ID, bank.ID)
+	if code := apiRequest(t, http.MethodDelete, scoped, "bob", nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob delete in private bank: status = %d, want 404", code)
+	}
+	if code := apiRequest(t, http.MethodDelete, scoped, "alice", nil, nil); code != http.StatusOK {
+		t.Errorf("delete in bank: status = %d, want 200", code)
+	}
+
+	// 移除题目后题目本身保留
+	var removed struct {
+		Removed int64 `json:"removed"`
+	}
+	apiRequest(t, http.MethodPost, path, "alice", gin.H{"question_ids": []uint{other.ID}}, nil)
+	apiRequest(t, http.MethodDelete, path, "alice", gin.H{"question_ids": []uint{other.ID}}, &removed)
+	if removed.Removed != 1 {
+		t.Errorf("removed = %d, want 1", removed.Removed)
+	}
+	if err := db.First(&models.Question{}, other.ID).Error; err != nil {
+		t.Errorf("question removed from bank was deleted: %v", err)
+	}
+}
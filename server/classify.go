@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// classifyConcurrency 同时进行分类的数量
+const classifyConcurrency = 3
+
+// ClassifyRequest 按 ID 或筛选条件选择要分类的题目，IDs 不为空时忽略筛选条件
+type ClassifyRequest struct {
+	IDs        []uint              `json:"ids" binding:"max=50"`
+	Type       models.QuestionType `json:"type"`
+	Difficulty models.Difficulty   `json:"difficulty"`
+	Language   string              `json:"language"`
+	Keyword    string              `json:"keyword"`
+	Limit      int                 `json:"limit" binding:"omitempty,min=1,max=50"` // 按筛选条件选择时的数量上限，默认 20
+}
+
+type ApplyClassificationRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1"` // 建议ID
+	// Difficulty、Tags 是否应用难度和标签，不传时都应用
+	Difficulty *bool `json:"difficulty"`
+	Tags       *bool `json:"tags"`
+	// MinConfidence 置信度低于该值的难度和标签不应用
+	MinConfidence float64 `json:"min_confidence" binding:"min=0,max=1"`
+	// ReplaceTags 为 true 时用建议的标签替换原有标签，否则合并
+	ReplaceTags bool `json:"replace_tags"`
+}
+
+type DismissClassificationRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1"`
+}
+
+// TagSuggestion 建议的知识点标签
+type TagSuggestion struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ClassificationReview 待审核的分类建议，Changes 列出与题目当前值的差异
+type ClassificationReview struct {
+	models.ClassificationSuggestion
+	Changes []string `json:"changes"`
+}
+
+// ClassifyFailure 分类失败的题目
+type ClassifyFailure struct {
+	QuestionID uint   `json:"question_id"`
+	Error      string `json:"error"`
+}
+
+// buildClassifyPrompt 要求模型按统一的难度标准评估题目
+func buildClassifyPrompt(q models.Question) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "请评估下面这道%s题目的难度，并给出它考查的知识点标签。\n\n", q.Language)
+	sb.WriteString(`难度标准：
+- easy：只考查单个基础概念或语法，熟悉语言的初学者可以直接作答
+- medium：需要理解多个概念之间的关系，或需要一定的实践经验
+- hard：涉及底层原理、并发、边界情况，或需要综合分析和推理
+
+`)
+	fmt.Fprintf(&sb, "题目类型：%s\n题目：%s\n", q.Type, q.Content)
+	if len(q.Options) > 0 {
+		keys := make([]string, 0, len(q.Options))
+		for k := range q.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("选项：\n")
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "%s. %v\n", k, q.Options[k])
+		}
+	}
+	if q.Answer != "" {
+		fmt.Fprintf(&sb, "答案：%s\n", q.Answer)
+	}
+	sb.WriteString(`
+只返回一个JSON对象，不要包含其他文字：
+{"difficulty": "easy、medium或hard", "confidence": 0到1之间的小数, "tags": [{"name": "知识点", "confidence": 0到1之间的小数}], "reasoning": "简要理由"}
+标签1到5个，使用简短的名词，如"切片"、"goroutine"、"接口"。`)
+	return sb.String()
+}
+
+// classifyResponseSchema 分类输出的 schema，开启结构化输出时使用
+func classifyResponseSchema() *ResponseSchema {
+	confidence := map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1}
+	return &ResponseSchema{
+		Name: "question_classification",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"difficulty": map[string]interface{}{"type": "string", "enum": []string{string(models.Easy), string(models.Medium), string(models.Hard)}},
+				"confidence": confidence,
+				"tags": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"name":       map[string]interface{}{"type": "string", "minLength": 1},
+							"confidence": confidence,
+						},
+						"required": []string{"name", "confidence"},
+					},
+					"maxItems": 5,
+				},
+				"reasoning": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"difficulty", "confidence", "tags"},
+		},
+	}
+}
+
+// clampConfidence 置信度限制在 0 到 1 之间，缺失时为 0
+func clampConfidence(v interface{}) float64 {
+	f, _ := v.(float64)
+	if f < 0 || f != f {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// parseClassifyResponse 解析分类结果，难度不在取值范围内时报错，不做默认值回退
+func parseClassifyResponse(content string) (models.Difficulty, float64, []TagSuggestion, string, error) {
+	value, err := parseJSON5(extractJSONContent(content))
+	if err != nil {
+		return "", 0, nil, "", fmt.Errorf("无法解析分类结果: %w", err)
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return "", 0, nil, "", errors.New("无法解析分类结果: 不是JSON对象")
+	}
+
+	raw, _ := obj["difficulty"].(string)
+	difficulty := models.Difficulty(strings.ToLower(strings.TrimSpace(raw)))
+	switch difficulty {
+	case models.Easy, models.Medium, models.Hard:
+	default:
+		return "", 0, nil, "", fmt.Errorf("无效的难度: %q", raw)
+	}
+
+	var tags []TagSuggestion
+	seen := make(map[string]bool)
+	list, _ := obj["tags"].([]interface{})
+	for _, item := range list {
+		var tag TagSuggestion
+		switch v := item.(type) {
+		case string:
+			tag = TagSuggestion{Name: v, Confidence: clampConfidence(obj["confidence"])}
+		case map[string]interface{}:
+			name, _ := v["name"].(string)
+			tag = TagSuggestion{Name: name, Confidence: clampConfidence(v["confidence"])}
+		}
+		tag.Name = strings.TrimSpace(tag.Name)
+		if tag.Name == "" || seen[tag.Name] || len(tags) >= 5 {
+			continue
+		}
+		seen[tag.Name] = true
+		tags = append(tags, tag)
+	}
+
+	reasoning, _ := obj["reasoning"].(string)
+	return difficulty, clampConfidence(obj["confidence"]), tags, strings.TrimSpace(reasoning), nil
+}
+
+// classifyQuestion 调用模型估计难度和标签，结果保存为待审核的建议
+func classifyQuestion(ctx context.Context, q models.Question) (*models.ClassificationSuggestion, error) {
+	genReq := GenerationRequest{
+		Task:     TaskClassify,
+		Messages: userPrompt(buildClassifyPrompt(q)),
+	}
+	if aiConfig.StructuredOutput != StructuredOutputNone {
+		genReq.Schema = classifyResponseSchema()
+	}
+
+	result, err := aiGenerator.Generate(ctx, genReq)
+	if err != nil {
+		return nil, err
+	}
+	difficulty, confidence, tags, reasoning, err := parseClassifyResponse(result.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion := models.ClassificationSuggestion{
+		QuestionID:           q.ID,
+		Status:               models.SuggestionPending,
+		CurrentDifficulty:    q.Difficulty,
+		SuggestedDifficulty:  difficulty,
+		DifficultyConfidence: confidence,
+		CurrentTags:          tagNames(q.Tags),
+		SuggestedTags:        toJSONArray(tags),
+		Reasoning:            reasoning,
+		Model:                result.Model,
+	}
+	if err := db.Create(&suggestion).Error; err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
+
+// suggestedTags 还原建议中的标签
+func suggestedTags(s models.ClassificationSuggestion) []TagSuggestion {
+	tags := make([]TagSuggestion, 0, len(s.SuggestedTags))
+	for _, item := range s.SuggestedTags {
+		if m, ok := item.(map[string]interface{}); ok {
+			name, _ := m["name"].(string)
+			tags = append(tags, TagSuggestion{Name: name, Confidence: clampConfidence(m["confidence"])})
+		}
+	}
+	return tags
+}
+
+// newClassificationReview 列出建议与题目当前值的差异
+func newClassificationReview(s models.ClassificationSuggestion) ClassificationReview {
+	review := ClassificationReview{ClassificationSuggestion: s, Changes: []string{}}
+	if s.SuggestedDifficulty != s.CurrentDifficulty {
+		review.Changes = append(review.Changes, fmt.Sprintf("难度: %s -> %s（置信度 %.2f）",
+			s.CurrentDifficulty, s.SuggestedDifficulty, s.DifficultyConfidence))
+	}
+
+	current := make(map[string]bool, len(s.CurrentTags))
+	for _, t := range s.CurrentTags {
+		current[t] = true
+	}
+	for _, t := range suggestedTags(s) {
+		if !current[t.Name] {
+			review.Changes = append(review.Changes, fmt.Sprintf("新增标签: %s（置信度 %.2f）", t.Name, t.Confidence))
+		}
+	}
+	return review
+}
+
+// 10.1 AI估计题目难度和知识点标签，返回待审核的建议
+func classifyQuestions(c *gin.Context) {
+	var req ClassifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Question{})
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	} else {
+		if req.Type != "" {
+			query = query.Where("type = ?", req.Type)
+		}
+		if req.Difficulty != "" {
+			query = query.Where("difficulty = ?", req.Difficulty)
+		}
+		if req.Language != "" {
+			query = query.Where("language = ?", req.Language)
+		}
+		if req.Keyword != "" {
+			query = query.Where("content LIKE ?", "%"+req.Keyword+"%")
+		}
+		if req.Limit == 0 {
+			req.Limit = 20
+		}
+		query = query.Limit(req.Limit)
+	}
+
+	var questions []models.Question
+	if err := query.Preload("Tags").Order("id").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有符合条件的题目"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	suggestions := make([]*models.ClassificationSuggestion, len(questions))
+	errs := make([]error, len(questions))
+	sem := make(chan struct{}, classifyConcurrency)
+	var wg sync.WaitGroup
+	for i := range questions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			suggestions[i], errs[i] = classifyQuestion(ctx, questions[i])
+		}(i)
+	}
+	wg.Wait()
+
+	reviews := []ClassificationReview{}
+	failed := []ClassifyFailure{}
+	for i, s := range suggestions {
+		if errs[i] != nil {
+			log.Printf("Classify question %d error: %v", questions[i].ID, errs[i])
+			failed = append(failed, ClassifyFailure{QuestionID: questions[i].ID, Error: errs[i].Error()})
+			continue
+		}
+		reviews = append(reviews, newClassificationReview(*s))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reviews, "failed": failed})
+}
+
+// 10.2 分类建议列表，默认只返回待审核的建议
+func getClassifications(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+	offset := (pagination.Page - 1) * pagination.PageSize
+
+	status := c.DefaultQuery("status", string(models.SuggestionPending))
+	query := db.Model(&models.ClassificationSuggestion{}).Where("status = ?", status)
+	if questionID := c.Query("question_id"); questionID != "" {
+		query = query.Where("question_id = ?", questionID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var suggestions []models.ClassificationSuggestion
+	if err := query.Order("id").Offset(offset).Limit(pagination.PageSize).Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reviews := make([]ClassificationReview, 0, len(suggestions))
+	for _, s := range suggestions {
+		reviews = append(reviews, newClassificationReview(s))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  reviews,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
+
+// 10.3 批量应用分类建议，修改了难度或标签的题目退回草稿重新审核
+func applyClassifications(c *gin.Context) {
+	var req ApplyClassificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	applyDifficulty := req.Difficulty == nil || *req.Difficulty
+	applyTags := req.Tags == nil || *req.Tags
+
+	var suggestions []models.ClassificationSuggestion
+	if err := db.Where("id IN ? AND status = ?", req.IDs, models.SuggestionPending).Order("id").Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updated []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range suggestions {
+			s := &suggestions[i]
+			var question models.Question
+			if err := tx.Preload("Tags").First(&question, s.QuestionID).Error; err != nil {
+				// 题目已被删除，建议作废
+				s.Status = models.SuggestionDismissed
+				if err := tx.Save(s).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			changed := false
+			if applyDifficulty && s.DifficultyConfidence >= req.MinConfidence && s.SuggestedDifficulty != question.Difficulty {
+				if err := tx.Model(&question).Update("difficulty", s.SuggestedDifficulty).Error; err != nil {
+					return err
+				}
+				changed = true
+			}
+			if applyTags {
+				// 建议的标签按名称匹配已有标签，没有时在根级创建
+				tags := []models.Tag{}
+				if !req.ReplaceTags {
+					tags = append(tags, question.Tags...)
+				}
+				for _, t := range suggestedTags(*s) {
+					if t.Confidence < req.MinConfidence || containsString(tagNames(tags), t.Name) {
+						continue
+					}
+					tag, err := resolveTagRef(tx, t.Name)
+					if err != nil {
+						return err
+					}
+					tags = append(tags, *tag)
+				}
+				if !equalStrings(tagNames(tags), tagNames(question.Tags)) {
+					if err := tx.Model(&question).Association("Tags").Replace(tags); err != nil {
+						return err
+					}
+					changed = true
+				}
+			}
+
+			if changed {
+				if err := returnToDraft(tx, &question, "edit", requestUser(c)); err != nil {
+					return err
+				}
+				if err := recordQuestionRevision(tx, question.ID, models.RevisionUpdate, requestUser(c)); err != nil {
+					return err
+				}
+				updated = append(updated, question.ID)
+			}
+			s.Status = models.SuggestionApplied
+			if err := tx.Save(s).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if updated == nil {
+		updated = []uint{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suggestions, "updated_questions": updated})
+}
+
+// 10.4 忽略分类建议
+func dismissClassifications(c *gin.Context) {
+	var req DismissClassificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	result := db.Model(&models.ClassificationSuggestion{}).
+		Where("id IN ? AND status = ?", req.IDs, models.SuggestionPending).
+		Update("status", models.SuggestionDismissed)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Suggestions dismissed successfully", "count": result.RowsAffected})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClassifyAndApply(t *testing.T) {
+	resetData(t)
+	useFixture(t, "classify_tags")
+	body := choiceQuestion("从已关闭的 channel 读取会怎样")
+	body["tags"] = []string{"Go > 并发 > Channel", "Go/语法"}
+	q := createQuestion(t, "alice", body)
+
+	classify := func() ClassificationReview {
+		t.Helper()
+		var resp struct {
+			Data   []ClassificationReview `json:"data"`
+			Failed []ClassifyFailure      `json:"failed"`
+		}
+		if code := apiRequest(t, http.MethodPost, "/api/questions/classify", "", gin.H{"ids": []uint{q.ID}}, &resp); code != http.StatusOK || len(resp.Data) != 1 {
+			t.Fatalf("classify: status = %d, data = %+v, failed = %+v", code, resp.Data, resp.Failed)
+		}
+		return resp.Data[0]
+	}
+	apply := func(body gin.H) []uint {
+		t.Helper()
+		var resp struct {
+			Updated []uint `json:"updated_questions"`
+		}
+		if code := apiRequest(t, http.MethodPost, "/api/classifications/apply", "carol", body, &resp); code != http.StatusOK {
+			t.Fatalf("apply: status = %d", code)
+		}
+		return resp.Updated
+	}
+	current := func() (models.Difficulty, string) {
+		var question models.Question
+		db.Preload("Tags").First(&question, q.ID)
+		paths := make([]string, 0, len(question.Tags))
+		for _, tag := range question.Tags {
+			paths = append(paths, tag.Path)
+		}
+		sort.Strings(paths)
+		return question.Difficulty, strings.Join(paths, ", ")
+	}
+
+	// 建议只列出与当前值不同的难度和标签
+	review := classify()
+	want := []string{"难度: easy -> hard（置信度 0.60）", "新增标签: 调度器（置信度 0.40）", "新增标签: goroutine（置信度 0.95）"}
+	if !equalStrings(review.Changes, want) {
+		t.Errorf("changes = %v, want %v", review.Changes, want)
+	}
+	if difficulty, tags := current(); difficulty != models.Easy || tags != "Go/并发/Channel, Go/语法" {
+		t.Errorf("classify changed the question: %s, %s", difficulty, tags)
+	}
+
+	// 低于 min_confidence 的难度和标签不应用；新标签在根级创建，同名标签使用已有的
+	if updated := apply(gin.H{"ids": []uint{review.ID}, "min_confidence": 0.7}); len(updated) != 1 {
+		t.Errorf("updated = %v", updated)
+	}
+	if difficulty, tags := current(); difficulty != models.Easy || tags != "Go/并发/Channel, Go/语法, goroutine" {
+		t.Errorf("after merge: %s, %s", difficulty, tags)
+	}
+	if tag := findTagByPath(t, "goroutine"); tag.ParentID != nil {
+		t.Errorf("new tag parent = %v, want root", *tag.ParentID)
+	}
+	var count int64
+	db.Model(&models.Tag{}).Where("name = ?", "Channel").Count(&count)
+	if count != 1 {
+		t.Errorf("%d Channel tags, want the existing one reused", count)
+	}
+
+	// 已应用的建议不会再次应用
+	if updated := apply(gin.H{"ids": []uint{review.ID}, "replace_tags": true}); len(updated) != 0 {
+		t.Errorf("applied suggestion updated %v", updated)
+	}
+
+	// replace_tags 用建议的标签替换原有标签
+	review = classify()
+	if updated := apply(gin.H{"ids": []uint{review.ID}, "replace_tags": true}); len(updated) != 1 {
+		t.Errorf("updated = %v", updated)
+	}
+	if difficulty, tags := current(); difficulty != models.Hard || tags != "Go/并发/Channel, goroutine, 调度器" {
+		t.Errorf("after replace: %s, %s", difficulty, tags)
+	}
+}
@@ -0,0 +1,31 @@
+// aistub 在本地启动回放录制响应的 OpenAI 兼容服务，用法：
+//
+//	go run ./cmd/aistub -addr :9999 -fixtures testdata/ai
+//
+// 然后在 .env 中设置 AI_PROVIDER=openai、AI_BASE_URL=http://localhost:9999/v1/，
+// AI_MODEL 设置为要回放的 fixture 名称（不含 .json）。
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"homework-server/aistub"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	dir := flag.String("fixtures", "testdata/ai", "fixture directory")
+	flag.Parse()
+
+	stub := aistub.New(*dir)
+	log.Printf("aistub listening on %s, fixtures from %s", *addr, *dir)
+	err := http.ListenAndServe(*addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		stub.ServeHTTP(w, r)
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// explainConcurrency 批量生成解析时同时调用模型的数量
+const explainConcurrency = 3
+
+type ExplainBatchRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=20"`
+}
+
+type ReviewExplanationRequest struct {
+	// Content 审核时修改后的解析，不传则使用草稿内容
+	Content string `json:"content"`
+}
+
+// ExplainResult 批量生成解析时单道题目的结果
+type ExplainResult struct {
+	QuestionID  uint                        `json:"question_id"`
+	Explanation *models.QuestionExplanation `json:"explanation,omitempty"`
+	Error       string                      `json:"error,omitempty"`
+}
+
+// buildExplainPrompt 构建解析提示词，要求以题库中的答案为准
+func buildExplainPrompt(q models.Question) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "请为下面这道%s题目写一份详细的解析。\n\n", q.Language)
+	writeQuestionDetails(&sb, q)
+
+	requirements := []string{"分步骤说明解题思路；", "说明正确答案为什么正确；"}
+	if spec := lookupQuestionType(q.Type); spec != nil && spec.ExplainHint != "" {
+		requirements = append(requirements, spec.ExplainHint)
+	}
+	requirements = append(requirements, "以上面给出的正确答案为准，不要修改答案；", "直接输出解析正文，不要重复题目，不要输出JSON。")
+
+	sb.WriteString("\n要求：")
+	for i, r := range requirements {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, r)
+	}
+	return sb.String()
+}
+
+// writeQuestionDetails 按题型、题目、选项、答案的顺序写出题目内容，供提示词使用；
+// 填空题、简答题、连线题和编程题的答案、代码和测试用例取自结构化内容
+func writeQuestionDetails(sb *strings.Builder, q models.Question) {
+	fmt.Fprintf(sb, "题目类型：%s\n", q.Type)
+	fmt.Fprintf(sb, "题目：%s\n", q.Content)
+
+	if len(q.Options) > 0 {
+		keys := make([]string, 0, len(q.Options))
+		for k := range q.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("选项：\n")
+		for _, k := range keys {
+			fmt.Fprintf(sb, "%s. %v\n", k, q.Options[k])
+		}
+	}
+
+	p := q.Payload
+	switch {
+	case len(p.Blanks) > 0:
+		sb.WriteString("空位答案：\n")
+		for _, b := range p.Blanks {
+			fmt.Fprintf(sb, "{{%s}}：%s\n", b.ID, strings.Join(b.Answers, " / "))
+		}
+	case len(p.AcceptedAnswers) > 0 || len(p.Patterns) > 0:
+		if len(p.AcceptedAnswers) > 0 {
+			fmt.Fprintf(sb, "可接受的答案：%s\n", strings.Join(p.AcceptedAnswers, " / "))
+		}
+		if len(p.Patterns) > 0 {
+			fmt.Fprintf(sb, "答案需匹配的正则表达式：%s\n", strings.Join(p.Patterns, " / "))
+		}
+	case len(p.Pairs) > 0:
+		sb.WriteString("正确配对：\n")
+		for _, pair := range p.Pairs {
+			fmt.Fprintf(sb, "%s → %s\n", pair.Left, pair.Right)
+		}
+	case p.Correct != nil:
+		if *p.Correct {
+			sb.WriteString("正确答案：该陈述正确\n")
+		} else {
+			sb.WriteString("正确答案：该陈述错误\n")
+		}
+	case q.Answer != "":
+		fmt.Fprintf(sb, "正确答案：%s\n", q.Answer)
+	}
+	writeProgrammingDetails(sb, p)
+}
+
+// writeProgrammingDetails 写出编程题的起始代码、参考答案、测试用例和运行限制
+func writeProgrammingDetails(sb *strings.Builder, p models.QuestionPayload) {
+	for _, t := range p.Templates {
+		if t.Starter != "" {
+			fmt.Fprintf(sb, "起始代码（%s）：\n```\n%s\n```\n", t.Language, t.Starter)
+		}
+		if t.Solution != "" {
+			fmt.Fprintf(sb, "参考答案（%s）：\n```\n%s\n```\n", t.Language, t.Solution)
+		}
+	}
+	if len(p.TestCases) > 0 {
+		sb.WriteString("测试用例：\n")
+	}
+	for i, tc := range p.TestCases {
+		hidden := ""
+		if tc.Hidden {
+			hidden = "（隐藏）"
+		}
+		if tc.Kind == models.TestCaseFunction {
+			args, _ := json.Marshal(tc.Args)
+			expected, _ := json.Marshal(tc.Expected)
+			fmt.Fprintf(sb, "%d. %s(%s) 返回 %s%s\n", i+1, tc.Function, strings.Trim(string(args), "[]"), expected, hidden)
+		} else {
+			fmt.Fprintf(sb, "%d. 输入：%q 输出：%q%s\n", i+1, tc.Input, tc.Output, hidden)
+		}
+	}
+	if p.Limits != nil {
+		fmt.Fprintf(sb, "运行限制：时间 %d 毫秒，内存 %d MB\n", p.Limits.TimeMs, p.Limits.MemoryMB)
+	}
+}
+
+// explainQuestion 调用模型生成解析并保存为草稿
+func explainQuestion(ctx context.Context, q models.Question) (*models.QuestionExplanation, error) {
+	result, err := aiGenerator.Generate(ctx, GenerationRequest{
+		Task:     TaskExplain,
+		Messages: userPrompt(buildExplainPrompt(q)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSpace(result.Content)
+	if content == "" {
+		return nil, errors.New("AI未返回解析内容")
+	}
+
+	explanation := models.QuestionExplanation{
+		QuestionID: q.ID,
+		Status:     models.ExplanationDraft,
+		Content:    content,
+		Model:      result.Model,
+	}
+	if err := db.Create(&explanation).Error; err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// 8.1 为单道题目生成解析草稿
+func explainQuestionHandler(c *gin.Context) {
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	explanation, err := explainQuestion(c.Request.Context(), question)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Printf("Explain question %d error: %v", question.ID, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": explanation})
+}
+
+// 8.2 批量生成解析草稿，单道题目失败不影响其他题目
+func batchExplainQuestions(c *gin.Context) {
+	var req ExplainBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var questions []models.Question
+	if err := db.Where("id IN ?", req.IDs).Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	byID := make(map[uint]models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	ctx := c.Request.Context()
+	results := make([]ExplainResult, len(req.IDs))
+	sem := make(chan struct{}, explainConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range req.IDs {
+		results[i].QuestionID = id
+		q, ok := byID[id]
+		if !ok {
+			results[i].Error = "Question not found"
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, q models.Question) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			explanation, err := explainQuestion(ctx, q)
+			if err != nil {
+				log.Printf("Explain question %d error: %v", q.ID, err)
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Explanation = explanation
+		}(i, q)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results, "succeeded": len(results) - failed, "failed": failed})
+}
+
+// 8.3 题目的解析草稿列表
+func getQuestionExplanations(c *gin.Context) {
+	var explanations []models.QuestionExplanation
+	if err := db.Where("question_id = ?", c.Param("id")).Order("id DESC").Find(&explanations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": explanations})
+}
+
+// 8.4 待审核的解析列表，默认只返回草稿
+func getExplanations(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+	offset := (pagination.Page - 1) * pagination.PageSize
+
+	status := c.DefaultQuery("status", string(models.ExplanationDraft))
+	query := db.Model(&models.QuestionExplanation{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var explanations []models.QuestionExplanation
+	if err := query.Order("id").Offset(offset).Limit(pagination.PageSize).Find(&explanations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  explanations,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
+
+// 8.5 审核通过，发布到题目上
+func approveExplanation(c *gin.Context) {
+	var req ReviewExplanationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+	}
+	reviewExplanation(c, models.ExplanationApproved, strings.TrimSpace(req.Content))
+}
+
+// 8.6 审核不通过
+func rejectExplanation(c *gin.Context) {
+	reviewExplanation(c, models.ExplanationRejected, "")
+}
+
+// reviewExplanation 只有草稿可以审核；审核通过时把解析写入题目，题目退回草稿重新审核
+func reviewExplanation(c *gin.Context, status models.ExplanationStatus, content string) {
+	var explanation models.QuestionExplanation
+	if err := db.Where("id = ? AND question_id = ?", c.Param("eid"), c.Param("id")).First(&explanation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Explanation not found"})
+		return
+	}
+	if explanation.Status != models.ExplanationDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "解析已审核，状态为 " + string(explanation.Status)})
+		return
+	}
+
+	now := time.Now()
+	explanation.Status = status
+	explanation.ReviewedAt = &now
+	if content != "" {
+		explanation.Content = content
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&explanation).Error; err != nil {
+			return err
+		}
+		if status != models.ExplanationApproved {
+			return nil
+		}
+		var question models.Question
+		if err := tx.First(&question, explanation.QuestionID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&question).Update("explanation", explanation.Content).Error; err != nil {
+			return err
+		}
+		// 解析写入题目后同样需要重新审核
+		if err := returnToDraft(tx, &question, "edit", requestUser(c)); err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, explanation.QuestionID, models.RevisionUpdate, requestUser(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": explanation})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExplainPromptNumbering(t *testing.T) {
+	tests := []struct {
+		name string
+		q    models.Question
+		want []string
+	}{
+		{"with hint", models.Question{Type: models.SingleChoice, Content: "x", Language: "Go"},
+			[]string{"1. 分步骤", "2. 说明正确答案", "3. 逐一说明", "4. 以上面给出的正确答案为准", "5. 直接输出解析正文"}},
+		// 题型没有额外要求时编号保持连续
+		{"without hint", models.Question{Type: models.QuestionType("essay"), Content: "x", Language: "Go"},
+			[]string{"1. 分步骤", "2. 说明正确答案", "3. 以上面给出的正确答案为准", "4. 直接输出解析正文"}},
+	}
+	for _, tt := range tests {
+		prompt := buildExplainPrompt(tt.q)
+		for _, s := range tt.want {
+			if !strings.Contains(prompt, s) {
+				t.Errorf("%s: prompt missing %q:\n%s", tt.name, s, prompt)
+			}
+		}
+		if n := strings.Count(prompt, "\n"+fmt.Sprint(len(tt.want)+1)+". "); n != 0 {
+			t.Errorf("%s: prompt has more than %d requirements:\n%s", tt.name, len(tt.want), prompt)
+		}
+	}
+}
+
+func TestExplanationReview(t *testing.T) {
+	resetData(t)
+	useFixture(t, "explain_text")
+	q := createQuestion(t, "alice", choiceQuestion("哪个关键字用于启动协程"))
+
+	type explanationResponse struct {
+		Data  models.QuestionExplanation `json:"data"`
+		Error string                     `json:"error"`
+	}
+	explain := func() models.QuestionExplanation {
+		t.Helper()
+		var resp explanationResponse
+		if code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/questions/%d/explain", q.ID), "alice", nil, &resp); code != http.StatusCreated {
+			t.Fatalf("explain: status = %d (%s)", code, resp.Error)
+		}
+		return resp.Data
+	}
+	published := func() string {
+		var question models.Question
+		db.First(&question, q.ID)
+		return question.Explanation
+	}
+	review := func(e models.QuestionExplanation, action string, body interface{}) (int, explanationResponse) {
+		var resp explanationResponse
+		path := fmt.Sprintf("/api/questions/%d/explanations/%d/%s", e.QuestionID, e.ID, action)
+		return apiRequest(t, http.MethodPost, path, "bob", body, &resp), resp
+	}
+
+	// 生成的解析保存为草稿，不直接写入题目
+	draft := explain()
+	if draft.Status != models.ExplanationDraft || !strings.HasPrefix(draft.Content, "第一步") || published() != "" {
+		t.Fatalf("draft = %+v, published = %q", draft, published())
+	}
+	if prompt := testStub.Requests()[0].Messages[0].Content; !strings.Contains(prompt, "正确答案：A") {
+		t.Errorf("prompt does not include the answer:\n%s", prompt)
+	}
+	var list struct {
+		Data  []models.QuestionExplanation `json:"data"`
+		Total int64                        `json:"total"`
+	}
+	apiRequest(t, http.MethodGet, "/api/explanations", "", nil, &list)
+	if list.Total != 1 || list.Data[0].ID != draft.ID {
+		t.Errorf("drafts = %+v", list.Data)
+	}
+
+	// 审核通过时可以修改内容，审核过的解析不能再审核
+	if code, resp := review(draft, "approve", gin.H{"content": " 修改后的解析 "}); code != http.StatusOK || resp.Data.Status != models.ExplanationApproved {
+		t.Fatalf("approve: status = %d, explanation = %+v", code, resp.Data)
+	}
+	if got := published(); got != "修改后的解析" {
+		t.Errorf("published explanation = %q", got)
+	}
+	if code, _ := review(draft, "reject", nil); code != http.StatusConflict {
+		t.Errorf("reject approved: status = %d, want 409", code)
+	}
+
+	// 不通过的解析不影响题目上已发布的解析
+	rejected := explain()
+	if code, resp := review(rejected, "reject", nil); code != http.StatusOK || resp.Data.Status != models.ExplanationRejected || resp.Data.ReviewedAt == nil {
+		t.Errorf("reject: status = %d, explanation = %+v", code, resp.Data)
+	}
+	if got := published(); got != "修改后的解析" {
+		t.Errorf("published explanation after reject = %q", got)
+	}
+
+	other := createQuestion(t, "alice", choiceQuestion("哪个关键字用于延迟执行"))
+	rejected.QuestionID = other.ID
+	if code, _ := review(rejected, "approve", nil); code != http.StatusNotFound {
+		t.Errorf("approve through another question: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// parseJSON5 按宽松的 JSON5 语法解析文本，结果类型与 encoding/json 解析到 interface{} 时一致。
+// 在标准JSON之外额外支持：单引号字符串、未加引号的键名、// 和 /* */ 注释、尾随逗号、
+// 十六进制数字、前导 + 号、Infinity/NaN，以及字符串中未转义的换行（模型输出代码时常见）。
+func parseJSON5(text string) (interface{}, error) {
+	p := &json5Parser{src: text}
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("JSON之后存在多余内容")
+	}
+	return value, nil
+}
+
+type json5Parser struct {
+	src string
+	pos int
+}
+
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("JSON5解析错误(位置%d): %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace 跳过空白和注释
+func (p *json5Parser) skipSpace() error {
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "\u00a0"), strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			// 不换行空格和BOM
+			_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			p.pos += size
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end + 1
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				return p.errorf("注释未闭合")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *json5Parser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("内容意外结束")
+	}
+
+	switch ch := p.src[p.pos]; {
+	case ch == '{':
+		return p.parseObject()
+	case ch == '[':
+		return p.parseArray()
+	case ch == '"' || ch == '\'':
+		return p.parseString()
+	case ch == '-' || ch == '+' || ch == '.' || (ch >= '0' && ch <= '9'):
+		return p.parseNumber()
+	default:
+		ident := p.parseIdentifier()
+		switch ident {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "NaN":
+			return math.NaN(), nil
+		case "":
+			return nil, p.errorf("无法识别的字符 %q", ch)
+		default:
+			return nil, p.errorf("无法识别的值 %q", ident)
+		}
+	}
+}
+
+func (p *json5Parser) parseObject() (interface{}, error) {
+	p.pos++ // {
+	obj := make(map[string]interface{})
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("对象未闭合")
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			return obj, nil
+		}
+
+		var key string
+		if ch := p.src[p.pos]; ch == '"' || ch == '\'' {
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		} else {
+			key = p.parseIdentifier()
+			if key == "" {
+				return nil, p.errorf("缺少键名")
+			}
+		}
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != ':' {
+			return nil, p.errorf("键 %q 之后缺少冒号", key)
+		}
+		p.pos++
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = value
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("对象未闭合")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return obj, nil
+		default:
+			return nil, p.errorf("对象成员之间缺少逗号")
+		}
+	}
+}
+
+func (p *json5Parser) parseArray() (interface{}, error) {
+	p.pos++ // [
+	arr := []interface{}{}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("数组未闭合")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, value)
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("数组未闭合")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return arr, nil
+		default:
+			return nil, p.errorf("数组元素之间缺少逗号")
+		}
+	}
+}
+
+func (p *json5Parser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		switch {
+		case ch == quote:
+			p.pos++
+			return sb.String(), nil
+		case ch == '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(ch)
+			p.pos++
+		}
+	}
+	return "", p.errorf("字符串未闭合")
+}
+
+func (p *json5Parser) parseEscape(sb *strings.Builder) error {
+	p.pos++ // 反斜杠
+	if p.pos >= len(p.src) {
+		return p.errorf("转义字符不完整")
+	}
+
+	ch := p.src[p.pos]
+	p.pos++
+	switch ch {
+	case 'n':
+		sb.WriteByte('\n')
+	case 't':
+		sb.WriteByte('\t')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'v':
+		sb.WriteByte('\v')
+	case '0':
+		sb.WriteByte(0)
+	case '\n':
+		// 行尾反斜杠表示续行
+	case '\r':
+		if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+			p.pos++
+		}
+	case 'u':
+		r, err := p.parseHex4()
+		if err != nil {
+			return err
+		}
+		// 代理对
+		if utf16.IsSurrogate(r) && strings.HasPrefix(p.src[p.pos:], `\u`) {
+			p.pos += 2
+			r2, err := p.parseHex4()
+			if err != nil {
+				return err
+			}
+			r = utf16.DecodeRune(r, r2)
+		}
+		sb.WriteRune(r)
+	default:
+		// \" \' \\ \/ 以及其他未定义的转义都按字面字符处理
+		sb.WriteByte(ch)
+	}
+	return nil
+}
+
+func (p *json5Parser) parseHex4() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("\\u 转义不完整")
+	}
+	n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("\\u 转义无效")
+	}
+	p.pos += 4
+	return rune(n), nil
+}
+
+func (p *json5Parser) parseNumber() (interface{}, error) {
+	start := p.pos
+	sign := 1.0
+	if ch := p.src[p.pos]; ch == '+' || ch == '-' {
+		if ch == '-' {
+			sign = -1
+		}
+		p.pos++
+	}
+
+	if strings.HasPrefix(p.src[p.pos:], "Infinity") {
+		p.pos += len("Infinity")
+		return sign * math.Inf(1), nil
+	}
+	if strings.HasPrefix(p.src[p.pos:], "NaN") {
+		p.pos += len("NaN")
+		return math.NaN(), nil
+	}
+
+	if strings.HasPrefix(p.src[p.pos:], "0x") || strings.HasPrefix(p.src[p.pos:], "0X") {
+		p.pos += 2
+		digits := p.pos
+		for p.pos < len(p.src) && isHexDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		n, err := strconv.ParseUint(p.src[digits:p.pos], 16, 64)
+		if err != nil {
+			return nil, p.errorf("十六进制数字无效")
+		}
+		return sign * float64(n), nil
+	}
+
+	digits := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if (ch >= '0' && ch <= '9') || ch == '.' || ch == 'e' || ch == 'E' ||
+			((ch == '+' || ch == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	f, err := strconv.ParseFloat(p.src[digits:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("数字格式无效")
+	}
+	return sign * f, nil
+}
+
+// parseIdentifier 读取未加引号的标识符，允许非ASCII字符
+func (p *json5Parser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == '_' || ch == '$' || ch >= 0x80 ||
+			(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') ||
+			(p.pos > start && ch >= '0' && ch <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+func isHexDigit(ch byte) bool {
+	return (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError 字段级别的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// validateJSONSchema 按 JSON Schema 校验 encoding/json 解析出的值。
+// 只实现了题目 schema 用到的关键字：type、enum、properties、required、additionalProperties、
+// items、minItems、maxItems、minLength、maxLength、pattern、minProperties、minimum、maximum。
+func validateJSONSchema(schema map[string]interface{}, value interface{}, path string) []FieldError {
+	var errs []FieldError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t, ok := schema["type"]; ok && !matchesSchemaType(t, value) {
+		fail("类型应为 %s", describeSchemaType(t))
+		return errs
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if !inSchemaEnum(enum, value) {
+			fail("取值必须是以下之一: %s", describeSchemaEnum(enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n, ok := schemaNumber(schema, "minLength"); ok && float64(length) < n {
+			if n == 1 {
+				fail("不能为空")
+			} else {
+				fail("长度不能小于 %v", n)
+			}
+		}
+		if n, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > n {
+			if n == 0 {
+				fail("必须为空")
+			} else {
+				fail("长度不能大于 %v", n)
+			}
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("格式不正确，应匹配 %s", pattern)
+			}
+		}
+
+	case float64:
+		if n, ok := schemaNumber(schema, "minimum"); ok && v < n {
+			fail("不能小于 %v", n)
+		}
+		if n, ok := schemaNumber(schema, "maximum"); ok && v > n {
+			fail("不能大于 %v", n)
+		}
+
+	case []interface{}:
+		if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < n {
+			fail("至少需要 %v 项", n)
+		}
+		if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > n {
+			fail("最多只能有 %v 项", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+
+	case map[string]interface{}:
+		if n, ok := schemaNumber(schema, "minProperties"); ok && float64(len(v)) < n {
+			fail("至少需要 %v 个字段", n)
+		}
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, FieldError{Field: joinFieldPath(path, name), Message: "缺少必填字段"})
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := properties[k].(map[string]interface{}); ok {
+				errs = append(errs, validateJSONSchema(sub, v[k], joinFieldPath(path, k))...)
+			} else if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+				errs = append(errs, FieldError{Field: joinFieldPath(path, k), Message: "不允许的字段"})
+			}
+		}
+	}
+
+	return errs
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesSchemaType(t interface{}, value interface{}) bool {
+	for _, name := range schemaStrings(t) {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeSchemaType(t interface{}) string {
+	return strings.Join(schemaStrings(t), " 或 ")
+}
+
+func inSchemaEnum(enum interface{}, value interface{}) bool {
+	for _, candidate := range schemaValues(enum) {
+		if fmt.Sprintf("%T:%v", candidate, candidate) == fmt.Sprintf("%T:%v", value, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeSchemaEnum(enum interface{}) string {
+	var parts []string
+	for _, candidate := range schemaValues(enum) {
+		parts = append(parts, fmt.Sprintf("%v", candidate))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// schemaValues 兼容 Go 代码里直接构造的 []string 和从JSON解析出的 []interface{}
+func schemaValues(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		out := make([]interface{}, len(list))
+		for i, s := range list {
+			out[i] = s
+		}
+		return out
+	case string:
+		return []interface{}{list}
+	}
+	return nil
+}
+
+func schemaStrings(v interface{}) []string {
+	var out []string
+	for _, item := range schemaValues(v) {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch n := schema[key].(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
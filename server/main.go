@@ -6,20 +6,20 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"regexp"
+	"sort"
 	"strings"
 
 	"homework-server/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-resty/resty/v2"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var db *gorm.DB
-var aiClient *resty.Client
+var aiGenerator QuestionGenerator
+var aiConfig AIConfig
 
 type Pagination struct {
 	Page     int `json:"page" form:"page" binding:"min=1"`
@@ -33,6 +33,20 @@ type QuestionRequest struct {
 	Answer     string              `json:"answer"`
 	Difficulty models.Difficulty   `json:"difficulty"`
 	Language   string              `json:"language"`
+	Locale     string              `json:"locale,omitempty"` // 题目内容的自然语言，默认 zh
+	// 以下为来源信息，AI生成的题目已经填好，保存时原样提交即可；不传时视为手工录入
+	Source          models.QuestionSource `json:"source,omitempty" binding:"omitempty,oneof=manual ai import"`
+	Model           string                `json:"model,omitempty"`
+	TemplateID      *uint                 `json:"template_id,omitempty"` // 生成时使用的提示词模板，内置提示词时为空
+	TemplateVersion int                   `json:"template_version,omitempty"`
+	JobID           *uint                 `json:"job_id,omitempty"`
+	Topic           string                `json:"topic,omitempty"`
+	// TagIDs、Tags 题目的知识点标签，Tags 为标签路径如 "Go/并发"，不存在的标签自动创建；
+	// 编辑时两者都不传表示不修改标签
+	TagIDs []uint   `json:"tag_ids,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Payload 按题型区分的结构化内容，传入时忽略 options 和 answer，由它重新生成
+	Payload *models.QuestionPayload `json:"payload,omitempty"`
 }
 
 type AIGenerateRequest struct {
@@ -41,17 +55,19 @@ type AIGenerateRequest struct {
 	Difficulty models.Difficulty   `json:"difficulty" binding:"required"`
 	Language   string              `json:"language" binding:"required"`
 	Topic      string              `json:"topic"`
-}
-
-// 临时结构体用于灵活解析AI返回的数据
-type AIGeneratedQuestionTemp struct {
-	Type       interface{} `json:"type"`
-	Content    interface{} `json:"content"`
-	Options    interface{} `json:"options"`
-	Answer     interface{} `json:"answer"`
-	Difficulty interface{} `json:"difficulty"`
-	Language   interface{} `json:"language"`
-	Topic      interface{} `json:"topic,omitempty"`
+	// FillPlaceholders 题目数量不足时是否用占位题目补齐，不传时默认补齐
+	FillPlaceholders *bool `json:"fill_placeholders"`
+	// TemplateID 使用的提示词模板，不传时使用该题型的默认模板；TemplateVersion 为 0 表示最新版本
+	TemplateID      *uint `json:"template_id"`
+	TemplateVersion int   `json:"template_version"`
+	// Verify 是否用第二次模型调用校验选择题答案，不传时由 AI_VERIFY 决定；流式生成不校验，指定 true 时返回 400
+	Verify *bool `json:"verify"`
+	// Save 是否直接保存生成的题目，占位题目和与题库完全重复的题目不会保存
+	Save bool `json:"save"`
+	// BankID 保存到指定题库，设置后视为 save=true；流式生成和异步任务不保存题目，指定 save 或 bank_id 时返回 400
+	BankID *uint `json:"bank_id"`
+	// OptionIDs 选择题必须使用的选项 ID，生成变体时取自原题，为空时为 A、B、C、D
+	OptionIDs []string `json:"-"`
 }
 
 func main() {
@@ -61,29 +77,80 @@ func main() {
 	}
 
 	// 初始化数据库
+	if err := initDatabase("questions.db"); err != nil {
+		log.Fatal("Failed to init database:", err)
+	}
+	loadSimilarityThreshold()
+	backfillQuestionPayloads()
+	backfillQuestionFingerprints()
+	migrateLegacyQuestionTags()
+	backfillQuestionRevisions()
+
+	// 初始化AI客户端
+	aiConfig = loadAIConfig()
+	aiUsageConfig = loadAIUsageConfig()
 	var err error
-	db, err = gorm.Open(sqlite.Open("questions.db"), &gorm.Config{})
+	aiGenerator, err = newQuestionGenerator(aiConfig)
 	if err != nil {
-		log.Fatal("Failed to connect database:", err)
+		log.Fatal("Failed to init AI provider:", err)
+	}
+	log.Printf("AI provider: %s, model: %s", aiGenerator.Name(), aiConfig.Model)
+	if err := initAnswerVerifier(); err != nil {
+		log.Fatal("Failed to init AI answer verifier:", err)
 	}
 
-	// 自动迁移
-	db.AutoMigrate(&models.Question{})
+	// 启动AI异步任务
+	startAIJobWorkers()
 
-	// 初始化AI客户端
-	aiClient = resty.New()
-	aiClient.SetBaseURL("https://api.siliconflow.cn/v1/")
-	aiClient.SetHeader("Authorization", "Bearer "+os.Getenv("AI_API_KEY"))
-	aiClient.SetHeader("Content-Type", "application/json")
+	// 定期清理回收站
+	loadTrashRetention()
+	startTrashPurger()
+
+	r := setupRouter()
+
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		if _, err := os.Stat(certFile); err == nil {
+			if _, err := os.Stat(keyFile); err == nil {
+				if err := r.RunTLS(":8080", certFile, keyFile); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+		}
+	}
+	if err := r.Run(":8080"); err != nil {
+		log.Fatal(err)
+	}
+	// 启动服务器
+	//port := os.Getenv("PORT")
+	//if port == "" {
+	//	port = "8080"
+	//}
+	//log.Printf("Server starting on port %s...", port)
+	//r.Run(":" + port)
+}
+
+// initDatabase 打开数据库并自动迁移所有表
+func initDatabase(dsn string) error {
+	var err error
+	db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	return db.AutoMigrate(&models.Question{}, &models.AIJob{}, &models.PromptTemplate{}, &models.PromptTemplateVersion{}, &models.AIUsage{}, &models.QuestionFingerprint{}, &models.QuestionSimilarityBand{}, &models.QuestionExplanation{}, &models.QuestionVerification{}, &models.ClassificationSuggestion{}, &models.QuestionTranslation{}, &models.Tag{}, &models.QuestionBank{}, &models.QuestionBankItem{}, &models.QuestionRevision{}, &models.QuestionReview{})
+}
 
-	// 初始化Gin
+// setupRouter 注册中间件、API路由和前端静态文件
+func setupRouter() *gin.Engine {
 	r := gin.Default()
 
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-User, X-API-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -99,9 +166,11 @@ func main() {
 	{
 		// 1. 查询接口
 		api.GET("/questions", getQuestions)
+		api.GET("/questions/:id/similar", getSimilarQuestions)
 
 		// 2. 添加接口
 		api.POST("/questions", addQuestion)
+		api.POST("/questions/import", importQuestions)
 
 		// 3. 编辑接口
 		api.PUT("/questions/:id", updateQuestion)
@@ -111,10 +180,102 @@ func main() {
 		api.DELETE("/questions", batchDeleteQuestions)
 
 		// 5. AI生成接口
-		api.POST("/ai/generate", generateQuestions)
+		api.POST("/ai/generate", aiUsageMiddleware("generate"), generateQuestions)
+		api.POST("/ai/generate/stream", aiUsageMiddleware("stream"), generateQuestionsStream)
+		api.POST("/ai/batch", aiUsageMiddleware("batch"), batchGenerateQuestions)
+		api.POST("/ai/jobs", aiUsageMiddleware("job"), createAIJob)
+		api.GET("/ai/jobs", getAIJobs)
+		api.GET("/ai/jobs/:id", getAIJob)
+		api.POST("/ai/jobs/:id/cancel", cancelAIJob)
+		api.GET("/ai/usage", getAIUsage)
 
 		// 6. 获取学习心得
 		api.GET("/learning-note", getLearningNote)
+
+		// 7. 提示词模板接口
+		api.GET("/prompt-templates", getPromptTemplates)
+		api.POST("/prompt-templates", createPromptTemplate)
+		api.GET("/prompt-templates/:id", getPromptTemplate)
+		api.PUT("/prompt-templates/:id", updatePromptTemplate)
+		api.DELETE("/prompt-templates/:id", deletePromptTemplate)
+		api.GET("/prompt-templates/:id/versions", getPromptTemplateVersions)
+		api.POST("/prompt-templates/:id/default", setDefaultPromptTemplate)
+		api.POST("/prompt-templates/:id/preview", previewPromptTemplate)
+
+		// 8. 题目解析接口
+		api.POST("/questions/:id/explain", aiUsageMiddleware("explain"), explainQuestionHandler)
+		api.POST("/questions/explain", aiUsageMiddleware("explain"), batchExplainQuestions)
+		api.GET("/questions/:id/explanations", getQuestionExplanations)
+		api.GET("/explanations", getExplanations)
+		api.POST("/questions/:id/explanations/:eid/approve", approveExplanation)
+		api.POST("/questions/:id/explanations/:eid/reject", rejectExplanation)
+
+		// 9. 答案校验接口
+		api.POST("/questions/verify", aiUsageMiddleware("verify"), batchVerifyQuestions)
+		api.GET("/verifications", getVerifications)
+
+		// 10. 难度与知识点分类接口
+		api.POST("/questions/classify", aiUsageMiddleware("classify"), classifyQuestions)
+		api.GET("/classifications", getClassifications)
+		api.POST("/classifications/apply", applyClassifications)
+		api.POST("/classifications/dismiss", dismissClassifications)
+
+		// 11. 题目翻译接口
+		api.POST("/questions/:id/translate", aiUsageMiddleware("translate"), translateQuestionHandler)
+		api.GET("/questions/:id/translations", getQuestionTranslations)
+		api.PUT("/questions/:id/translations/:locale", saveQuestionTranslation)
+		api.DELETE("/questions/:id/translations/:locale", deleteQuestionTranslation)
+
+		// 12. 题目变体接口
+		api.POST("/questions/:id/variants", aiUsageMiddleware("variants"), createQuestionVariants)
+		api.GET("/questions/:id/variants", getQuestionVariants)
+
+		// 13. 标签接口
+		api.GET("/tags", getTags)
+		api.POST("/tags", createTag)
+		api.GET("/tags/:id", getTag)
+		api.PUT("/tags/:id", updateTag)
+		api.DELETE("/tags/:id", deleteTag)
+
+		// 14. 题库接口
+		api.GET("/banks", getBanks)
+		api.POST("/banks", createBank)
+		api.GET("/banks/:id", getBank)
+		api.PUT("/banks/:id", updateBank)
+		api.DELETE("/banks/:id", deleteBank)
+		api.POST("/banks/:id/questions", addBankQuestions)
+		api.DELETE("/banks/:id/questions", removeBankQuestions)
+
+		// 15. 修订历史接口
+		api.GET("/questions/:id/revisions", getQuestionRevisions)
+		api.GET("/questions/:id/revisions/diff", diffQuestionRevisions)
+		api.GET("/questions/:id/revisions/:rev", getQuestionRevision)
+		api.POST("/questions/:id/revisions/:rev/restore", restoreQuestionRevision)
+
+		// 16. 回收站接口
+		api.GET("/trash", getTrash)
+		api.POST("/trash/restore", restoreTrash)
+		api.DELETE("/trash", purgeTrash)
+
+		// 17. 审核流程接口
+		api.POST("/questions/:id/submit", submitQuestion)
+		api.POST("/questions/:id/approve", approveQuestion)
+		api.POST("/questions/:id/reject", rejectQuestion)
+		api.POST("/questions/:id/publish", publishQuestion)
+		api.POST("/questions/:id/archive", archiveQuestion)
+		api.POST("/questions/:id/reopen", reopenQuestion)
+		api.GET("/questions/:id/reviews", getQuestionReviews)
+
+		// 18. 题型接口
+		api.GET("/question-types", getQuestionTypes)
+		api.POST("/questions/:id/grade", gradeQuestion)
+
+		// 19. 编程题代码和测试用例接口
+		api.GET("/questions/:id/programming", getProgramming)
+		api.PUT("/questions/:id/programming", updateProgrammingHandler)
+		api.POST("/questions/:id/test-cases", addTestCase)
+		api.PUT("/questions/:id/test-cases/:cid", updateTestCase)
+		api.DELETE("/questions/:id/test-cases/:cid", deleteTestCase)
 	}
 
 	// 静态文件服务-放在最后
@@ -130,28 +291,7 @@ func main() {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Not Found"})
 	})
 
-	certFile := os.Getenv("TLS_CERT_FILE")
-	keyFile := os.Getenv("TLS_KEY_FILE")
-	if certFile != "" && keyFile != "" {
-		if _, err := os.Stat(certFile); err == nil {
-			if _, err := os.Stat(keyFile); err == nil {
-				if err := r.RunTLS(":8080", certFile, keyFile); err != nil {
-					log.Fatal(err)
-				}
-				return
-			}
-		}
-	}
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal(err)
-	}
-	// 启动服务器
-	//port := os.Getenv("PORT")
-	//if port == "" {
-	//	port = "8080"
-	//}
-	//log.Printf("Server starting on port %s...", port)
-	//r.Run(":" + port)
+	return r
 }
 
 // 1. 查询接口
@@ -167,7 +307,16 @@ func getQuestions(c *gin.Context) {
 	// 构建查询条件
 	query := db.Model(&models.Question{})
 
-	// 筛选条件
+	// 筛选条件，默认只返回已发布的题目，管理端用 status=all 查看全部；
+	// 编程题的参考答案和隐藏用例只在 view=author 时返回
+	statuses, err := parseStatusFilter(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if statuses != nil {
+		query = query.Where("status IN ?", statuses)
+	}
 	if typeStr := c.Query("type"); typeStr != "" {
 		query = query.Where("type = ?", typeStr)
 	}
@@ -177,15 +326,58 @@ func getQuestions(c *gin.Context) {
 	if keyword := c.Query("keyword"); keyword != "" {
 		query = query.Where("content LIKE ?", "%"+keyword+"%")
 	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if jobID := c.Query("job_id"); jobID != "" {
+		query = query.Where("job_id = ?", jobID)
+	}
+	if group := c.Query("variant_group"); group != "" {
+		query = query.Where("id = ? OR variant_group = ?", group, group)
+	}
+	// 按标签筛选时包含子标签，多个 tag 参数需要同时满足
+	for _, ref := range c.QueryArray("tag") {
+		tag, err := findTag(ref)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签不存在: " + ref})
+			return
+		}
+		query = query.Where("id IN (?)", tagFilterSubquery(tag))
+	}
+	bank, ok := lookupBankQuery(c, false)
+	if !ok {
+		return
+	}
+	if bank != nil {
+		query = query.Where("id IN (?)", bankFilterSubquery(bank.ID))
+	}
 
 	var total int64
 	query.Count(&total)
 
 	var questions []models.Question
-	if err := query.Offset(offset).Limit(pagination.PageSize).Find(&questions).Error; err != nil {
+	if err := query.Preload("Tags").Offset(offset).Limit(pagination.PageSize).Find(&questions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	redactQuestions(c, questions)
+
+	// 指定语言时返回译文，没有译文的题目返回原文
+	if locale := c.Query("locale"); locale != "" {
+		localized, err := localizeQuestions(questions, locale)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data":   localized,
+			"total":  total,
+			"page":   pagination.Page,
+			"size":   pagination.PageSize,
+			"locale": locale,
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data":  questions,
@@ -204,31 +396,149 @@ func addQuestion(c *gin.Context) {
 		return
 	}
 
-	// 验证必填字段
-	if req.Content == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "题目内容不能为空"})
+	// 按题型逐字段校验
+	if errs := prepareQuestionRequest(&req); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目校验失败", "errors": errs})
+		return
+	}
+	if isPlaceholderContent(req.Content) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "占位题目不能保存，请先填写题目内容"})
 		return
 	}
 
-	question := models.Question{
-		Type:       req.Type,
-		Content:    req.Content,
-		Options:    req.Options,
-		Answer:     req.Answer,
-		Difficulty: req.Difficulty,
-		Language:   req.Language,
+	// 查重，完全相同的题目需要 force=true 才能保存
+	duplicates, err := findSimilarQuestions(requestFingerprint(req), 0, similarityThreshold, defaultSimilarLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if hasExactDuplicate(duplicates) && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, gin.H{"error": "题库中已存在相同的题目", "duplicates": duplicates})
+		return
+	}
+
+	question := newQuestion(req, requestUser(c))
+	if question.Tags, err = questionRequestTags(db, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		if err := saveQuestionFingerprint(tx, &question); err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question.ID, models.RevisionCreate, requestUser(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": question, "duplicates": duplicates})
+}
+
+// ImportError 导入时未通过校验的题目
+type ImportError struct {
+	Index  int          `json:"index"`
+	Errors []FieldError `json:"errors"`
+}
+
+// 2.1 批量导入接口，任何一道题目未通过校验时都不导入，返回每道题目的字段错误；
+// 与题库完全相同的题目跳过，指定 bank_id 时导入到该题库
+func importQuestions(c *gin.Context) {
+	var items []QuestionRequest
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有要导入的题目"})
+		return
+	}
+	bank, ok := lookupBankQuery(c, true)
+	if !ok {
+		return
+	}
+
+	var invalid []ImportError
+	questions := make([]GeneratedQuestion, len(items))
+	for i := range items {
+		if items[i].Source == "" {
+			items[i].Source = models.SourceImport
+		}
+		errs := prepareQuestionRequest(&items[i])
+		if s := items[i].Source; s != models.SourceManual && s != models.SourceAI && s != models.SourceImport {
+			errs = append(errs, FieldError{Field: "source", Message: "来源必须是 manual、ai 或 import"})
+		}
+		if isPlaceholderContent(items[i].Content) {
+			errs = append(errs, FieldError{Field: "content", Message: "占位题目不能保存，请先填写题目内容"})
+		}
+		if len(errs) > 0 {
+			invalid = append(invalid, ImportError{Index: i, Errors: errs})
+		}
+		questions[i] = GeneratedQuestion{QuestionRequest: items[i]}
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目校验失败", "invalid": invalid})
+		return
 	}
 
-	if err := db.Create(&question).Error; err != nil {
+	warnings := flagDuplicateQuestions(questions)
+	var bankID *uint
+	if bank != nil {
+		bankID = &bank.ID
+	}
+	saved, skipped, err := saveGeneratedQuestions(questions, bankID, requestUser(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
 		log.Printf("Database error: %v", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": question})
+	c.JSON(http.StatusCreated, gin.H{"data": saved, "warnings": append(warnings, skipped...)})
+}
+
+// newQuestion 由请求构造待保存的题目，未指定来源时视为手工录入；新题目都是草稿，需要审核后发布
+func newQuestion(req QuestionRequest, author string) models.Question {
+	source := req.Source
+	if source == "" {
+		source = models.SourceManual
+	}
+	return models.Question{
+		Type:            req.Type,
+		Content:         req.Content,
+		Options:         req.Options,
+		Answer:          req.Answer,
+		Difficulty:      req.Difficulty,
+		Language:        req.Language,
+		Locale:          req.Locale,
+		Source:          source,
+		Model:           req.Model,
+		TemplateID:      req.TemplateID,
+		TemplateVersion: req.TemplateVersion,
+		JobID:           req.JobID,
+		Topic:           req.Topic,
+		Payload:         questionPayload(req.Type, req.Payload, req.Options, req.Answer),
+		Status:          models.StatusDraft,
+		Author:          author,
+	}
+}
+
+// questionRequestTags 解析新建题目时的标签，AI生成时使用的主题也作为标签
+func questionRequestTags(tx *gorm.DB, req QuestionRequest) ([]models.Tag, error) {
+	refs := req.Tags
+	if req.Topic != "" {
+		refs = append(append([]string{}, refs...), req.Topic)
+	}
+	return resolveRequestTags(tx, req.TagIDs, refs)
 }
 
-// 3. 编辑接口
+// 3. 编辑接口，不是草稿的题目修改后退回草稿重新审核
 func updateQuestion(c *gin.Context) {
 	id := c.Param("id")
 
@@ -238,32 +548,94 @@ func updateQuestion(c *gin.Context) {
 		return
 	}
 
+	legacy := req.Payload == nil
+	if errs := prepareQuestionRequest(&req); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目校验失败", "errors": errs})
+		return
+	}
+	if isPlaceholderContent(req.Content) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "占位题目不能保存，请先填写题目内容"})
+		return
+	}
+
 	var question models.Question
-	if err := db.First(&question, id).Error; err != nil {
+	if err := db.Preload("Tags").First(&question, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
 		return
 	}
+	if legacy && req.Type == models.Programming && question.Type == models.Programming {
+		keepProgrammingPayload(req.Payload, question.Payload)
+	}
+	replaceTags := req.TagIDs != nil || req.Tags != nil
+	var tags []models.Tag
+	if replaceTags {
+		var err error
+		if tags, err = resolveRequestTags(db, req.TagIDs, req.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	question.Type = req.Type
 	question.Content = req.Content
 	question.Options = req.Options
 	question.Answer = req.Answer
+	question.Payload = *req.Payload
 	question.Difficulty = req.Difficulty
 	question.Language = req.Language
+	if req.Locale != "" {
+		question.Locale = req.Locale
+	}
 
-	if err := db.Save(&question).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tags").Save(&question).Error; err != nil {
+			return err
+		}
+		if replaceTags {
+			if err := tx.Model(&question).Association("Tags").Replace(tags); err != nil {
+				return err
+			}
+			question.Tags = tags
+		}
+		if err := saveQuestionFingerprint(tx, &question); err != nil {
+			return err
+		}
+		if err := returnToDraft(tx, &question, "edit", requestUser(c)); err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question.ID, models.RevisionUpdate, requestUser(c))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": question})
+	duplicates, err := findSimilarQuestions(fingerprintQuestion(question.Type, question.Content, question.Payload), question.ID, similarityThreshold, defaultSimilarLimit)
+	if err != nil {
+		log.Printf("Duplicate check failed: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": question, "duplicates": duplicates})
 }
 
 // 4. 删除接口（单个）
+// 指定 bank_id 时只能删除该题库中的题目，并且需要有题库的修改权限
 func deleteQuestion(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := db.Delete(&models.Question{}, id).Error; err != nil {
+	query, ok := bankScopedDelete(c)
+	if !ok {
+		return
+	}
+	var ids []uint
+	if err := query.Model(&models.Question{}).Where("id = ?", id).Pluck("id", &ids).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(ids) == 0 && c.Query("bank_id") != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不在该题库中"})
+		return
+	}
+	if err := deleteQuestionsWithRevision(ids, requestUser(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -271,7 +643,7 @@ func deleteQuestion(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Question deleted successfully"})
 }
 
-// 4.1 批量删除接口
+// 4.1 批量删除接口，bank_id 的含义同单个删除，不在题库中的题目忽略
 func batchDeleteQuestions(c *gin.Context) {
 	var ids []uint
 	if err := c.ShouldBindJSON(&ids); err != nil {
@@ -279,146 +651,100 @@ func batchDeleteQuestions(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&models.Question{}, ids).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	query, ok := bankScopedDelete(c)
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Questions deleted successfully"})
-}
-
-// 5. AI生成接口
-func generateQuestions(c *gin.Context) {
-	var req AIGenerateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
-		log.Printf("AI generate request error: %v", err)
+	var existing []uint
+	if err := query.Model(&models.Question{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 验证必填字段
-	if req.Language == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "编程语言不能为空"})
+	if err := deleteQuestionsWithRevision(existing, requestUser(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 构建详细的AI提示词
-	prompt := buildAIPrompt(req)
+	c.JSON(http.StatusOK, gin.H{"message": "Questions deleted successfully", "deleted": len(existing)})
+}
 
-	// 调用AI API
-	aiReq := map[string]interface{}{
-		"model": "deepseek-ai/DeepSeek-V3",
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"stream":     false,
-		"max_tokens": 2000,
-		"stop":       []string{"null"},
+// bankScopedDelete 删除题目的查询，指定 bank_id 时限定在该题库内
+func bankScopedDelete(c *gin.Context) (*gorm.DB, bool) {
+	bank, ok := lookupBankQuery(c, true)
+	if !ok {
+		return nil, false
 	}
-
-	resp, err := aiClient.R().
-		SetBody(aiReq).
-		Post("chat/completions")
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务调用失败: " + err.Error()})
-		log.Printf("AI API call error: %v", err)
-		return
+	if bank == nil {
+		return db, true
 	}
+	return db.Where("id IN (?)", bankFilterSubquery(bank.ID)), true
+}
 
-	// 检查HTTP状态码
-	if resp.StatusCode() != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("AI服务返回错误，状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body())),
-		})
-		log.Printf("AI API returned non-200 status: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+// 5. AI生成接口
+func generateQuestions(c *gin.Context) {
+	req, ok := bindAIGenerateRequest(c)
+	if !ok {
 		return
 	}
-
-	var aiResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
+	if req.BankID != nil {
+		if _, status, err := lookupBank(*req.BankID, requestUser(c), true); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		req.Save = true
 	}
 
-	log.Printf("AI API raw response: %s\n", string(resp.Body()))
-
-	if err := json.Unmarshal(resp.Body(), &aiResp); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析AI响应失败: " + err.Error()})
-		log.Printf("Failed to parse AI response: %v, body: %s", err, string(resp.Body()))
+	// 构建详细的AI提示词，优先使用数据库中的模板
+	prompt, template, err := resolvePrompt(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 检查是否有错误
-	if aiResp.Error.Message != "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务错误: " + aiResp.Error.Message})
+	// 调用AI API并解析，输出格式有问题时会要求模型修正
+	generation, err := generateWithRepair(c.Request.Context(), req, prompt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Printf("AI API call error: %v", err)
 		return
 	}
-
-	if len(aiResp.Choices) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI未返回任何内容"})
-		log.Printf("AI response has no choices, body: %s", string(resp.Body()))
-		return
+	if generation.ParseErr != nil {
+		log.Printf("Failed to parse AI response: %v", generation.ParseErr)
+		log.Printf("Cleaned content: %s", cleanJSONContent(generation.Result.Content))
 	}
 
-	// 获取AI返回的内容
-	content := aiResp.Choices[0].Message.Content
-	log.Printf("Raw AI response content: %s\n", content)
-
-	// 解析AI返回的题目
-	generatedQuestions, err := parseAIResponse(content, req)
-	if err != nil {
-		log.Printf("Failed to parse AI response: %v", err)
-		log.Printf("Cleaned content: %s", cleanJSONContent(content))
-
-		// 创建默认题目作为fallback
-		generatedQuestions = createFallbackQuestions(req)
-		log.Printf("Using fallback questions, count: %d", len(generatedQuestions))
+	// 校验数量，不足时按需补充占位题目
+	generatedQuestions, warnings := finalizeGeneratedQuestions(generation.Questions, generation.Invalid, req, generation.ParseErr, req.fillPlaceholders())
+	warnings = append(generation.Notes, warnings...)
+	warnings = append(warnings, flagDuplicateQuestions(generatedQuestions)...)
+	if req.verify() {
+		warnings = append(warnings, verifyGeneratedQuestions(c.Request.Context(), generatedQuestions)...)
 	}
-
-	// 确保返回的题目数量符合要求
-	if len(generatedQuestions) > req.Count {
-		generatedQuestions = generatedQuestions[:req.Count]
-	} else if len(generatedQuestions) < req.Count {
-		log.Printf("Warning: AI只生成了%d道题目，请求的是%d道", len(generatedQuestions), req.Count)
-		// 补充默认题目
-		for i := len(generatedQuestions); i < req.Count; i++ {
-			generatedQuestions = append(generatedQuestions, createDefaultQuestion(req, i+1))
-		}
+	if len(generatedQuestions) == 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "AI未生成可用的题目", "warnings": warnings, "invalid": generation.Invalid, "template": template})
+		return
 	}
+	setPromptRef(generatedQuestions, template)
+	setGenerationSource(generatedQuestions, generation.Result.Model, req.Topic, nil)
 
-	// 最终验证
-	for i, q := range generatedQuestions {
-		if q.Type == "" || q.Content == "" {
-			generatedQuestions[i] = createDefaultQuestion(req, i+1)
+	log.Printf("Successfully generated %d questions", len(generatedQuestions))
+	if req.Save {
+		saved, skipped, err := saveGeneratedQuestions(generatedQuestions, req.BankID, requestUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+			log.Printf("Database error: %v", err)
+			return
 		}
+		warnings = append(warnings, skipped...)
+		c.JSON(http.StatusCreated, gin.H{"data": saved, "warnings": warnings, "invalid": generation.Invalid, "template": template})
+		return
 	}
-
-	log.Printf("Successfully generated %d questions", len(generatedQuestions))
-	c.JSON(http.StatusOK, gin.H{"data": generatedQuestions})
+	c.JSON(http.StatusOK, gin.H{"data": generatedQuestions, "warnings": warnings, "invalid": generation.Invalid, "template": template})
 }
 
 // 构建AI提示词
 func buildAIPrompt(req AIGenerateRequest) string {
-	typeDesc := ""
-	switch req.Type {
-	case models.SingleChoice:
-		typeDesc = "single_choice"
-	case models.MultipleChoice:
-		typeDesc = "multiple_choice"
-	case models.Programming:
-		typeDesc = "programming"
-	default:
-		typeDesc = string(req.Type)
-	}
+	typeDesc := string(req.Type)
 
 	difficultyDesc := ""
 	switch req.Difficulty {
@@ -432,11 +758,17 @@ func buildAIPrompt(req AIGenerateRequest) string {
 		difficultyDesc = string(req.Difficulty)
 	}
 
-	prompt := fmt.Sprintf(`请生成%d道编程题目，要求如下：
+	// 题目名称取自题型定义，如"单选题"、"连线题"
+	noun := "题目"
+	if spec := lookupQuestionType(req.Type); spec != nil {
+		noun = spec.Label
+	}
+
+	prompt := fmt.Sprintf(`请生成%d道%s，要求如下：
 1. 题目类型：%s
 2. 难度：%s  
 3. 编程语言：%s`,
-		req.Count, typeDesc, difficultyDesc, req.Language)
+		req.Count, noun, typeDesc, difficultyDesc, req.Language)
 
 	if req.Topic != "" {
 		prompt += fmt.Sprintf("\n4. 主题：%s", req.Topic)
@@ -446,89 +778,58 @@ func buildAIPrompt(req AIGenerateRequest) string {
 
 请严格按照以下JSON数组格式返回，不要包含任何其他文字：
 [
-  {
-    "type": "%s",
-    "content": "题目内容描述",
-    "options": {"A": "选项A内容", "B": "选项B内容", "C": "选项C内容", "D": "选项D内容"},
-    "answer": "答案",
-    "difficulty": "%s", 
-    "language": "%s"
-  }
+%s
 ]
 
 具体要求：
-1. 如果是选择题(type为single_choice或multiple_choice)：
-   - options字段必须是一个JSON对象，包含A、B、C、D四个选项
-   - answer字段：单选题如"A"，多选题如"A,B"（用逗号分隔，不要有空格）
-   
-2. 如果是编程题(type为programming)：
-   - options字段请返回空字符串
-   - answer字段请返回空字符串""
-   
-3. type字段必须是：single_choice、multiple_choice或programming
-4. difficulty字段必须是：easy、medium或hard
-5. 只返回JSON数组，不要有其他内容`,
-		typeDesc, difficultyDesc, req.Language)
-
-	return prompt
-}
-
-// 解析AI返回的题目并转换为QuestionRequest
-func parseAIResponse(content string, req AIGenerateRequest) ([]QuestionRequest, error) {
-	content = cleanJSONContent(content)
+%s`,
+		promptExample(req, typeDesc, difficultyDesc), typePromptRequirements(req.Type))
 
-	// 首先尝试直接解析为QuestionRequest数组
-	var questions []QuestionRequest
-	if err := json.Unmarshal([]byte(content), &questions); err == nil {
-		return normalizeQuestions(questions, req), nil
+	if schema := describeSchemaForPrompt(req); schema != "" {
+		prompt += "\n\n每道题目必须符合以下JSON Schema：\n" + schema
 	}
+	prompt += structuredOutputHint()
 
-	// 如果失败，使用临时结构体解析
-	var tempQuestions []AIGeneratedQuestionTemp
-	if err := json.Unmarshal([]byte(content), &tempQuestions); err == nil {
-		return convertTempToQuestions(tempQuestions, req), nil
-	}
+	return prompt
+}
 
-	// 尝试解析为单个对象
-	var tempQuestion AIGeneratedQuestionTemp
-	if err := json.Unmarshal([]byte(content), &tempQuestion); err == nil {
-		question := convertSingleTempToQuestion(tempQuestion, req)
-		return []QuestionRequest{question}, nil
+// promptExample 内置提示词中的示例题目，题型特有的字段取自题型定义
+func promptExample(req AIGenerateRequest, typeDesc, difficultyDesc string) string {
+	fields := []string{
+		fmt.Sprintf(`"type": %q`, typeDesc),
+		`"content": "题目内容描述"`,
 	}
-
-	// 如果还是失败，尝试解析为map数组
-	var rawArray []json.RawMessage
-	if err := json.Unmarshal([]byte(content), &rawArray); err == nil {
-		var questions []QuestionRequest
-		for _, raw := range rawArray {
-			if question, err := parseRawQuestion(raw, req); err == nil {
-				questions = append(questions, question)
-			}
+	if spec := lookupQuestionType(req.Type); spec != nil {
+		keys := make([]string, 0, len(spec.Example))
+		for key := range spec.Example {
+			keys = append(keys, key)
 		}
-		if len(questions) > 0 {
-			return questions, nil
+		sort.Strings(keys)
+		for _, key := range keys {
+			b, _ := json.Marshal(spec.Example[key])
+			fields = append(fields, fmt.Sprintf("%q: %s", key, b))
 		}
 	}
-
-	return nil, fmt.Errorf("无法解析AI响应")
+	fields = append(fields, fmt.Sprintf(`"difficulty": %q`, difficultyDesc), fmt.Sprintf(`"language": %q`, req.Language))
+	return "  {\n    " + strings.Join(fields, ",\n    ") + "\n  }"
 }
 
-// 解析单个原始JSON题目
-func parseRawQuestion(raw json.RawMessage, req AIGenerateRequest) (QuestionRequest, error) {
-	var question QuestionRequest
-
-	// 首先尝试直接解析
-	if err := json.Unmarshal(raw, &question); err == nil {
-		return normalizeQuestion(question, req), nil
-	}
-
-	// 如果失败，解析为map然后转换
-	var tempMap map[string]interface{}
-	if err := json.Unmarshal(raw, &tempMap); err != nil {
-		return question, err
+// typePromptRequirements 内置提示词中的具体要求，题型相关的部分取自题型定义，
+// 也作为提示词模板中的 {{.Requirements}} 变量
+func typePromptRequirements(t models.QuestionType) string {
+	var sb strings.Builder
+	n := 1
+	if spec := lookupQuestionType(t); spec != nil {
+		fmt.Fprintf(&sb, "%d. %s(type为%s)：\n", n, spec.Label, spec.Type)
+		for _, line := range spec.Prompt {
+			sb.WriteString("   - " + line + "\n")
+		}
+		n++
 	}
-
-	return convertMapToQuestion(tempMap, req), nil
+	fmt.Fprintf(&sb, "%d. type字段必须是：%s\n", n, t)
+	fmt.Fprintf(&sb, "%d. difficulty字段必须是：easy、medium或hard\n", n+1)
+	fmt.Fprintf(&sb, "%d. 只返回JSON数组，不要有其他内容", n+2)
+	return sb.String()
 }
 
 // 转换map到QuestionRequest
@@ -537,7 +838,7 @@ func convertMapToQuestion(m map[string]interface{}, req AIGenerateRequest) Quest
 
 	// 设置Type
 	if typeVal, ok := m["type"]; ok {
-		question.Type = validateAndConvertType(fmt.Sprintf("%v", typeVal))
+		question.Type, _ = validateAndConvertType(fmt.Sprintf("%v", typeVal))
 	} else {
 		question.Type = req.Type
 	}
@@ -618,6 +919,14 @@ func convertMapToQuestion(m map[string]interface{}, req AIGenerateRequest) Quest
 		}
 	}
 
+	// 设置Payload（判断题、填空题等题型的结构化内容）
+	if payloadVal, ok := m["payload"].(map[string]interface{}); ok {
+		var payload models.QuestionPayload
+		if b, err := json.Marshal(payloadVal); err == nil && json.Unmarshal(b, &payload) == nil {
+			question.Payload = &payload
+		}
+	}
+
 	// 设置Difficulty
 	if diffVal, ok := m["difficulty"]; ok {
 		question.Difficulty = validateAndConvertDifficulty(fmt.Sprintf("%v", diffVal))
@@ -640,140 +949,13 @@ func convertMapToQuestion(m map[string]interface{}, req AIGenerateRequest) Quest
 	return question
 }
 
-// 转换临时结构体数组到QuestionRequest数组
-func convertTempToQuestions(tempQuestions []AIGeneratedQuestionTemp, req AIGenerateRequest) []QuestionRequest {
-	var questions []QuestionRequest
-	for _, temp := range tempQuestions {
-		question := convertSingleTempToQuestion(temp, req)
-		questions = append(questions, question)
-	}
-	return questions
-}
-
-// 转换单个临时结构体到QuestionRequest
-func convertSingleTempToQuestion(temp AIGeneratedQuestionTemp, req AIGenerateRequest) QuestionRequest {
-	var question QuestionRequest
-
-	// 转换Type
-	if temp.Type != nil {
-		question.Type = validateAndConvertType(fmt.Sprintf("%v", temp.Type))
-	} else {
-		question.Type = req.Type
-	}
-
-	// 转换Content
-	if temp.Content != nil {
-		switch v := temp.Content.(type) {
-		case string:
-			question.Content = v
-		default:
-			question.Content = fmt.Sprintf("%v", v)
-		}
-	}
-
-	// 转换Options（选择题）
-	if question.Type == models.SingleChoice || question.Type == models.MultipleChoice {
-		if temp.Options != nil {
-			switch v := temp.Options.(type) {
-			case string:
-				// 如果是字符串，尝试解析为JSON
-				var optionsMap map[string]string
-				if err := json.Unmarshal([]byte(v), &optionsMap); err == nil {
-					question.Options = toModelsJSON(optionsMap)
-				}
-			case map[string]interface{}:
-				// 如果是map，转换为models.JSON
-				optionsMap := make(map[string]string)
-				for key, val := range v {
-					if strVal, ok := val.(string); ok {
-						optionsMap[key] = strVal
-					} else {
-						optionsMap[key] = fmt.Sprintf("%v", val)
-					}
-				}
-				question.Options = toModelsJSON(optionsMap)
-			default:
-				// 使用默认选项
-				question.Options = toModelsJSON(map[string]string{
-					"A": "选项A",
-					"B": "选项B",
-					"C": "选项C",
-					"D": "选项D",
-				})
-			}
-		} else {
-			// 没有options字段，使用默认
-			question.Options = toModelsJSON(map[string]string{
-				"A": "选项A",
-				"B": "选项B",
-				"C": "选项C",
-				"D": "选项D",
-			})
-		}
-	}
-
-	// 转换Answer
-	if temp.Answer != nil {
-		switch v := temp.Answer.(type) {
-		case string:
-			question.Answer = v
-		case []interface{}:
-			// 多选题答案数组
-			var answers []string
-			for _, item := range v {
-				if str, ok := item.(string); ok {
-					answers = append(answers, str)
-				} else {
-					answers = append(answers, fmt.Sprintf("%v", item))
-				}
-			}
-			question.Answer = strings.Join(answers, ",")
-		case []string:
-			question.Answer = strings.Join(v, ",")
-		case float64:
-			question.Answer = fmt.Sprintf("%.0f", v)
-		default:
-			question.Answer = fmt.Sprintf("%v", v)
-		}
-	}
-
-	// 转换Difficulty
-	if temp.Difficulty != nil {
-		question.Difficulty = validateAndConvertDifficulty(fmt.Sprintf("%v", temp.Difficulty))
-	} else {
-		question.Difficulty = req.Difficulty
-	}
-
-	// 转换Language
-	if temp.Language != nil {
-		switch v := temp.Language.(type) {
-		case string:
-			question.Language = v
-		default:
-			question.Language = req.Language
-		}
-	} else {
-		question.Language = req.Language
-	}
-
-	return question
-}
-
-// 规范化题目数组
-func normalizeQuestions(questions []QuestionRequest, req AIGenerateRequest) []QuestionRequest {
-	for i := range questions {
-		questions[i] = normalizeQuestion(questions[i], req)
-	}
-	return questions
-}
-
 // 规范化单个题目
 func normalizeQuestion(question QuestionRequest, req AIGenerateRequest) QuestionRequest {
-	// 确保Type不为空
+	// 确保Type不为空，无法识别的类型原样保留，交给调用方校验
 	if question.Type == "" {
 		question.Type = req.Type
-	} else {
-		question.Type = validateAndConvertType(string(question.Type))
+	} else if t, ok := validateAndConvertType(string(question.Type)); ok {
+		question.Type = t
 	}
 
 	// 确保Difficulty不为空
@@ -801,33 +983,37 @@ func normalizeQuestion(question QuestionRequest, req AIGenerateRequest) Question
 			})
 		}
 
-		// 清理Answer：去除空格，转换为大写
+		// 清理Answer：去除空格，不是选项ID的转换为大写
 		if question.Answer != "" {
-			question.Answer = strings.ToUpper(strings.ReplaceAll(question.Answer, " ", ""))
+			question.Answer = canonicalChoiceAnswer(question.Answer, question.Options)
 		}
-	} else if question.Type == models.Programming {
-		// 编程题的Answer应该为空
+	} else if lookupQuestionType(question.Type) != nil {
+		// 编程题和其他题型不使用旧格式的Answer和Options，其他题型的答案在Payload中
 		question.Answer = ""
-		// 编程题不需要Options
 		question.Options = nil
 	}
 
 	return question
 }
 
-// 验证和转换类型
-func validateAndConvertType(typeStr string) models.QuestionType {
-	lowerStr := strings.ToLower(typeStr)
-	switch {
-	case strings.Contains(lowerStr, "single") || strings.Contains(lowerStr, "单选题"):
-		return models.SingleChoice
-	case strings.Contains(lowerStr, "multiple") || strings.Contains(lowerStr, "多选题"):
-		return models.MultipleChoice
-	case strings.Contains(lowerStr, "programming") || strings.Contains(lowerStr, "编程题"):
-		return models.Programming
-	default:
-		return models.SingleChoice // 默认值
+// 验证和转换类型，无法识别时返回 false，不再默认按单选题处理。
+// 先精确匹配题型，再按题型定义的顺序匹配别名和中文名称
+func validateAndConvertType(typeStr string) (models.QuestionType, bool) {
+	lowerStr := strings.ToLower(strings.TrimSpace(typeStr))
+	if spec := lookupQuestionType(models.QuestionType(lowerStr)); spec != nil {
+		return spec.Type, true
 	}
+	for _, spec := range questionTypeSpecs {
+		if strings.Contains(lowerStr, spec.Label) {
+			return spec.Type, true
+		}
+		for _, alias := range spec.Aliases {
+			if strings.Contains(lowerStr, alias) {
+				return spec.Type, true
+			}
+		}
+	}
+	return "", false
 }
 
 // 验证和转换难度
@@ -847,74 +1033,74 @@ func validateAndConvertDifficulty(diffStr string) models.Difficulty {
 
 // cleanJSONContent 清理JSON内容，移除可能的markdown代码块标记和修复格式
 func cleanJSONContent(content string) string {
-	// 移除前后的空白字符
-	content = strings.TrimSpace(content)
+	content = extractJSONContent(content)
 
-	// 1. 移除所有可能的markdown代码块标记
-	content = strings.ReplaceAll(content, "```json", "")
-	content = strings.ReplaceAll(content, "```javascript", "")
-	content = strings.ReplaceAll(content, "```", "")
-
-	// 2. 查找并提取JSON部分
-	// 查找第一个 [ 或 {
-	startIndex := -1
-	for i, ch := range content {
-		if ch == '[' || ch == '{' {
-			startIndex = i
-			break
-		}
+	// 按宽松语法（单引号、未加引号的键名、注释、尾随逗号等）解析后重新输出为标准JSON，
+	// 字符串里的内容和 true/false/null 的类型都保持不变
+	value, err := parseJSON5(content)
+	if err != nil {
+		return content
 	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return content
+	}
+	return string(b)
+}
 
-	if startIndex != -1 {
-		content = content[startIndex:]
+// extractJSONContent 截取第一段完整的JSON，不修改其内容
+func extractJSONContent(content string) string {
+	// 移除前后的空白字符
+	content = strings.TrimSpace(content)
 
-		// 找到匹配的结束符号
-		bracketStack := []rune{}
-		for i, ch := range content {
-			if ch == '[' || ch == '{' {
-				bracketStack = append(bracketStack, ch)
-			} else if ch == ']' {
-				if len(bracketStack) > 0 && bracketStack[len(bracketStack)-1] == '[' {
-					bracketStack = bracketStack[:len(bracketStack)-1]
-				}
-			} else if ch == '}' {
-				if len(bracketStack) > 0 && bracketStack[len(bracketStack)-1] == '{' {
-					bracketStack = bracketStack[:len(bracketStack)-1]
-				}
+	// 1. 查找第一个 [ 或 {，之前的说明文字和markdown代码块标记一并去掉
+	startIndex := strings.IndexAny(content, "[{")
+	if startIndex == -1 {
+		return content
+	}
+	content = content[startIndex:]
+
+	// 2. 找到匹配的结束符号，字符串中的括号不参与匹配
+	bracketStack := []byte{}
+	var quote byte
+	escaped := false
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == quote:
+				quote = 0
 			}
+			continue
+		}
 
-			// 当栈为空时，表示找到了匹配的结束位置
-			if len(bracketStack) == 0 {
-				content = content[:i+1]
-				break
+		switch ch {
+		case '"', '\'':
+			quote = ch
+		case '[', '{':
+			bracketStack = append(bracketStack, ch)
+		case ']':
+			if len(bracketStack) > 0 && bracketStack[len(bracketStack)-1] == '[' {
+				bracketStack = bracketStack[:len(bracketStack)-1]
+			}
+		case '}':
+			if len(bracketStack) > 0 && bracketStack[len(bracketStack)-1] == '{' {
+				bracketStack = bracketStack[:len(bracketStack)-1]
 			}
 		}
-	}
-
-	// 3. 修复常见的JSON格式问题
-	// 3.1 修复未加引号的键名
-	re := regexp.MustCompile(`([{,]\s*)(\w+)(\s*:)`)
-	content = re.ReplaceAllString(content, `${1}"${2}"${3}`)
-
-	// 3.2 将单引号替换为双引号
-	content = strings.ReplaceAll(content, `'`, `"`)
-
-	// 3.3 修复布尔值和null值
-	content = strings.ReplaceAll(content, `: true`, `: "true"`)
-	content = strings.ReplaceAll(content, `: false`, `: "false"`)
-	content = strings.ReplaceAll(content, `: null`, `: ""`)
 
-	// 3.4 移除尾随逗号
-	re = regexp.MustCompile(`,\s*([}\]])`)
-	for re.MatchString(content) {
-		content = re.ReplaceAllString(content, `$1`)
+		// 当栈为空时，表示找到了匹配的结束位置
+		if len(bracketStack) == 0 {
+			return strings.TrimSpace(content[:i+1])
+		}
 	}
 
-	// 3.5 修复数字键名（options中的"A"等）
-	re = regexp.MustCompile(`"(\d+)":`)
-	content = re.ReplaceAllString(content, `"$1":`)
-
-	return strings.TrimSpace(content)
+	// 没有闭合（输出被截断），去掉结尾可能残留的代码块标记
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
 }
 
 // helper: 将 map[string]string 转换为 models.JSON（通过 marshal/unmarshal）
@@ -934,15 +1120,7 @@ func toModelsJSON(m map[string]string) models.JSON {
 	return j
 }
 
-// Fallback函数
-func createFallbackQuestions(req AIGenerateRequest) []QuestionRequest {
-	var questions []QuestionRequest
-	for i := 0; i < req.Count; i++ {
-		questions = append(questions, createDefaultQuestion(req, i+1))
-	}
-	return questions
-}
-
+// createDefaultQuestion 生成占位题目，内容需要人工填写后才能保存
 func createDefaultQuestion(req AIGenerateRequest, index int) QuestionRequest {
 	question := QuestionRequest{
 		Type:       req.Type,
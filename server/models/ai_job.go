@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// AIJob 异步AI生成任务，记录请求参数、模型原始输出和解析结果
+type AIJob struct {
+	ID      uint      `json:"id" gorm:"primaryKey"`
+	Status  JobStatus `json:"status" gorm:"type:varchar(20);index"`
+	Request JSON      `json:"request" gorm:"type:text"` // AIGenerateRequest
+	Prompt  string    `json:"prompt" gorm:"type:text"`
+	// 生成提示词所用的模板，使用内置提示词时为空
+	TemplateID      *uint `json:"template_id" gorm:"index"`
+	TemplateVersion int   `json:"template_version"`
+	// 创建任务的调用方，用于用量统计
+	User        string     `json:"user" gorm:"type:varchar(100)"`
+	APIKey      string     `json:"api_key" gorm:"type:varchar(20)"`
+	Model       string     `json:"model" gorm:"type:varchar(100)"`
+	Progress    int        `json:"progress"` // 0-100
+	RawOutput   string     `json:"raw_output" gorm:"type:text"`
+	Questions   JSONArray  `json:"questions" gorm:"type:text"`
+	ParseErrors JSONArray  `json:"parse_errors" gorm:"type:text"`
+	Error       string     `json:"error" gorm:"type:text"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Finished 任务是否已经结束
+func (j *AIJob) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+type UsageStatus string
+
+const (
+	UsageSuccess   UsageStatus = "success"
+	UsageError     UsageStatus = "error"
+	UsageCancelled UsageStatus = "cancelled"
+)
+
+// AIUsage 每次调用AI服务的记录，重试和修正都会单独记录一条
+type AIUsage struct {
+	ID               uint        `json:"id" gorm:"primaryKey"`
+	Provider         string      `json:"provider" gorm:"type:varchar(20)"`
+	Model            string      `json:"model" gorm:"type:varchar(100);index"`
+	Endpoint         string      `json:"endpoint" gorm:"type:varchar(50)"` // 发起调用的接口，如 generate、stream、job
+	JobID            *uint       `json:"job_id" gorm:"index"`
+	User             string      `json:"user" gorm:"type:varchar(100);index"`
+	APIKey           string      `json:"api_key" gorm:"type:varchar(20);index"` // 调用方 API Key 的指纹，不保存原文
+	Status           UsageStatus `json:"status" gorm:"type:varchar(20)"`
+	StatusCode       int         `json:"status_code"` // 提供方返回的HTTP状态码，网络错误时为 0
+	Error            string      `json:"error" gorm:"type:text"`
+	PromptTokens     int         `json:"prompt_tokens"`
+	CompletionTokens int         `json:"completion_tokens"`
+	TotalTokens      int         `json:"total_tokens"`
+	Cost             float64     `json:"cost"`
+	LatencyMs        int64       `json:"latency_ms"`
+	CreatedAt        time.Time   `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BankVisibility 题库的可见范围
+type BankVisibility string
+
+const (
+	BankPrivate BankVisibility = "private" // 只有所有者可见
+	BankPublic  BankVisibility = "public"  // 所有人可见，只有所有者可以修改
+)
+
+// QuestionBank 题库，一道题目可以属于多个题库
+type QuestionBank struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"type:varchar(100)"`
+	Description string `json:"description" gorm:"type:text"`
+	// Owner 创建者，取自请求头 X-User，为空表示不属于任何人，所有人都可以修改
+	Owner      string         `json:"owner" gorm:"type:varchar(100);index"`
+	Visibility BankVisibility `json:"visibility" gorm:"type:varchar(20);default:'private';index"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// QuestionBankItem 题库与题目的关联
+type QuestionBankItem struct {
+	BankID     uint      `json:"bank_id" gorm:"primaryKey"`
+	QuestionID uint      `json:"question_id" gorm:"primaryKey;index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
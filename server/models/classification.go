@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+type SuggestionStatus string
+
+const (
+	SuggestionPending   SuggestionStatus = "pending"
+	SuggestionApplied   SuggestionStatus = "applied"
+	SuggestionDismissed SuggestionStatus = "dismissed"
+)
+
+// ClassificationSuggestion AI对题目难度和知识点标签的估计，人工确认后才会写入题目
+type ClassificationSuggestion struct {
+	ID                   uint             `json:"id" gorm:"primaryKey"`
+	QuestionID           uint             `json:"question_id" gorm:"index"`
+	Status               SuggestionStatus `json:"status" gorm:"type:varchar(20);index"`
+	CurrentDifficulty    Difficulty       `json:"current_difficulty" gorm:"type:varchar(10)"`
+	SuggestedDifficulty  Difficulty       `json:"suggested_difficulty" gorm:"type:varchar(10)"`
+	DifficultyConfidence float64          `json:"difficulty_confidence"`
+	CurrentTags          StringList       `json:"current_tags" gorm:"type:text"`
+	SuggestedTags        JSONArray        `json:"suggested_tags" gorm:"type:text"` // [{"name": "...", "confidence": 0.9}]
+	Reasoning            string           `json:"reasoning" gorm:"type:text"`
+	Model                string           `json:"model" gorm:"type:varchar(100)"`
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
+}
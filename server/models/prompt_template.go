@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PromptTemplate 出题提示词模板，Content 为 Go text/template 格式，始终保存最新版本
+type PromptTemplate struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"type:varchar(100)"`
+	Description  string         `json:"description" gorm:"type:text"`
+	QuestionType QuestionType   `json:"question_type" gorm:"type:varchar(20);index"` // 为空表示适用于所有题型
+	IsDefault    bool           `json:"is_default" gorm:"index"`
+	Version      int            `json:"version"`
+	Content      string         `json:"content" gorm:"type:text"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PromptTemplateVersion 模板的历史版本，创建后不再修改
+type PromptTemplateVersion struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TemplateID uint      `json:"template_id" gorm:"uniqueIndex:idx_template_version"`
+	Version    int       `json:"version" gorm:"uniqueIndex:idx_template_version"`
+	Content    string    `json:"content" gorm:"type:text"`
+	Comment    string    `json:"comment" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -10,11 +10,18 @@ import (
 
 type QuestionType string
 type Difficulty string
+type QuestionSource string
+type QuestionStatus string
 
 const (
 	SingleChoice   QuestionType = "single_choice"
 	MultipleChoice QuestionType = "multiple_choice"
 	Programming    QuestionType = "programming"
+	TrueFalse      QuestionType = "true_false"   // 判断题
+	Cloze          QuestionType = "cloze"        // 填空题，可以有多个空位
+	ShortAnswer    QuestionType = "short_answer" // 简答题
+	Matching       QuestionType = "matching"     // 连线题
+	Ordering       QuestionType = "ordering"     // 排序题
 )
 
 const (
@@ -23,17 +30,56 @@ const (
 	Hard   Difficulty = "hard"
 )
 
+// 题目来源
+const (
+	SourceManual QuestionSource = "manual" // 手工录入
+	SourceAI     QuestionSource = "ai"     // AI生成
+	SourceImport QuestionSource = "import" // 批量导入
+)
+
+// 题目的审核状态，只有已发布的题目对学习者可见
+const (
+	StatusDraft     QuestionStatus = "draft"     // 草稿，可以继续编辑
+	StatusInReview  QuestionStatus = "in_review" // 已提交，等待审核
+	StatusApproved  QuestionStatus = "approved"  // 审核通过，等待发布
+	StatusPublished QuestionStatus = "published" // 已发布
+	StatusArchived  QuestionStatus = "archived"  // 已归档，不再使用
+)
+
 type Question struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	Type       QuestionType   `json:"type" gorm:"type:varchar(20)"`
-	Content    string         `json:"content" gorm:"type:text"`
-	Options    JSON           `json:"options" gorm:"type:text"` // JSON格式存储选项
-	Answer     string         `json:"answer" gorm:"type:text"`
-	Difficulty Difficulty     `json:"difficulty" gorm:"type:varchar(10)"`
-	Language   string         `json:"language" gorm:"type:varchar(20)"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID      uint         `json:"id" gorm:"primaryKey"`
+	Type    QuestionType `json:"type" gorm:"type:varchar(20)"`
+	Content string       `json:"content" gorm:"type:text"`
+	Options JSON         `json:"options" gorm:"type:text"` // JSON格式存储选项
+	Answer  string       `json:"answer" gorm:"type:text"`
+	// Payload 按题型区分的结构化内容，Options 和 Answer 由它派生
+	Payload QuestionPayload `json:"payload" gorm:"type:text"`
+	// Explanation 已发布的题目解析，只能通过审核解析草稿修改
+	Explanation string `json:"explanation" gorm:"type:text"`
+	// Tags 知识点标签，多对多关联到 tags 表
+	Tags       []Tag      `json:"tags" gorm:"many2many:question_tags"`
+	Difficulty Difficulty `json:"difficulty" gorm:"type:varchar(10)"`
+	Language   string     `json:"language" gorm:"type:varchar(20)"`
+	// Locale 题目内容使用的自然语言，其他语言的内容见 QuestionTranslation
+	Locale string `json:"locale" gorm:"type:varchar(20);default:'zh'"`
+	// ParentID 变体题目由哪道题目生成，原创题目为空
+	ParentID *uint `json:"parent_id,omitempty" gorm:"index"`
+	// VariantGroup 同一道原题及其所有变体共用的分组，取原题ID，没有变体时为空
+	VariantGroup *uint `json:"variant_group,omitempty" gorm:"index"`
+	// Source 题目来源，AI生成的题目同时记录模型、提示词模板、生成任务和主题
+	Source          QuestionSource `json:"source" gorm:"type:varchar(20);default:'manual';index"`
+	Model           string         `json:"model,omitempty" gorm:"type:varchar(100)"`
+	TemplateID      *uint          `json:"template_id,omitempty"`
+	TemplateVersion int            `json:"template_version,omitempty"`
+	JobID           *uint          `json:"job_id,omitempty" gorm:"index"`
+	Topic           string         `json:"topic,omitempty" gorm:"type:varchar(200)"`
+	// Status 审核状态，新题目为草稿；增加该字段前已有的题目视为已发布
+	Status QuestionStatus `json:"status" gorm:"type:varchar(20);default:'published';index"`
+	// Author 创建者，取自请求头 X-User，审核人不能是创建者
+	Author    string         `json:"author,omitempty" gorm:"type:varchar(100)"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type JSON map[string]interface{}
@@ -73,4 +119,82 @@ func (j *JSON) Scan(value interface{}) error {
 	}
 
 	return json.Unmarshal(bytes, j)
-}
\ No newline at end of file
+}
+
+// JSONArray JSON数组类型，存储为文本
+type JSONArray []interface{}
+
+func (j JSONArray) GormDataType() string {
+	return "text"
+}
+
+// Value 实现 driver.Valuer 接口
+func (j JSONArray) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return json.Marshal(j)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (j *JSONArray) Scan(value interface{}) error {
+	if value == nil {
+		*j = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("cannot scan non-string value into JSONArray")
+	}
+
+	if len(bytes) == 0 {
+		*j = JSONArray{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, j)
+}
+
+// StringList 字符串数组，存储为JSON文本
+type StringList []string
+
+func (l StringList) GormDataType() string {
+	return "text"
+}
+
+// Value 实现 driver.Valuer 接口，空数组存为 "[]"
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner 接口
+func (l *StringList) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*l = StringList{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("cannot scan non-string value into StringList")
+	}
+
+	if len(bytes) == 0 {
+		*l = StringList{}
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+type ExplanationStatus string
+
+const (
+	ExplanationDraft    ExplanationStatus = "draft"
+	ExplanationApproved ExplanationStatus = "approved"
+	ExplanationRejected ExplanationStatus = "rejected"
+)
+
+// QuestionExplanation AI生成的题目解析草稿，审核通过后写入 Question.Explanation
+type QuestionExplanation struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	QuestionID uint              `json:"question_id" gorm:"index"`
+	Status     ExplanationStatus `json:"status" gorm:"type:varchar(20);index"`
+	Content    string            `json:"content" gorm:"type:text"`
+	Model      string            `json:"model" gorm:"type:varchar(100)"`
+	ReviewedAt *time.Time        `json:"reviewed_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
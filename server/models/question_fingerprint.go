@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// QuestionFingerprint 题目的相似度指纹，题目内容或结构化内容变化时重新计算
+type QuestionFingerprint struct {
+	QuestionID  uint      `json:"question_id" gorm:"primaryKey;autoIncrement:false"`
+	ContentHash string    `json:"content_hash" gorm:"type:varchar(64);index"` // 规范化文本的哈希，用于判断完全重复
+	Signature   string    `json:"signature" gorm:"type:text"`                 // MinHash 签名，十六进制编码
+	Version     int       `json:"version"`                                    // 计算指纹的规则版本，低于当前版本的指纹在启动时重新计算
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// QuestionSimilarityBand MinHash 签名分段后的桶，同一段落入同一个桶的题目才会进一步比较
+type QuestionSimilarityBand struct {
+	ID         uint   `gorm:"primaryKey"`
+	QuestionID uint   `gorm:"index"`
+	Band       int    `gorm:"index:idx_band_bucket"`
+	Bucket     string `gorm:"type:varchar(16);index:idx_band_bucket"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// ChoiceOption 选择题的一个选项或排序题的一个条目，ID 在调整顺序时保持不变，答案通过 ID 引用选项
+type ChoiceOption struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// ClozeBlank 填空题的一个空位，题目内容中用 {{ID}} 标记空位的位置
+type ClozeBlank struct {
+	ID string `json:"id"`
+	// Answers 该空位所有可接受的答案
+	Answers []string `json:"answers"`
+	// CaseSensitive 判分时是否区分大小写，默认不区分
+	CaseSensitive bool `json:"case_sensitive,omitempty"`
+}
+
+// MatchPair 连线题的一组正确配对
+type MatchPair struct {
+	ID    string `json:"id"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// CodeTemplate 编程题某种语言的起始代码和参考答案
+type CodeTemplate struct {
+	Language string `json:"language"`
+	// Starter 发给学习者的起始代码
+	Starter string `json:"starter,omitempty"`
+	// Solution 参考答案，不展示给学习者
+	Solution string `json:"solution,omitempty"`
+}
+
+// TestCaseKind 测试用例的类型
+type TestCaseKind string
+
+const (
+	TestCaseStdio    TestCaseKind = "stdio"    // 从标准输入读取，比对标准输出
+	TestCaseFunction TestCaseKind = "function" // 调用指定函数，比对返回值
+)
+
+// TestCase 编程题的一个测试用例，按 Kind 只使用对应的字段
+type TestCase struct {
+	ID   string       `json:"id"`
+	Kind TestCaseKind `json:"kind"`
+	// Input、Output 标准输入和期望的标准输出
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Function、Args、Expected 调用的函数名、参数和期望的返回值
+	Function string        `json:"function,omitempty"`
+	Args     []interface{} `json:"args,omitempty"`
+	Expected interface{}   `json:"expected,omitempty"`
+	// Hidden 隐藏用例只用于判分，不展示给学习者
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// ExecutionLimits 编程题运行的时间和内存限制
+type ExecutionLimits struct {
+	TimeMs   int `json:"time_ms"`
+	MemoryMB int `json:"memory_mb"`
+}
+
+// QuestionPayload 按题型区分的结构化题目内容，只使用与题型对应的字段。
+// Question.Options 和 Question.Answer 由它派生，保留给按旧格式读取题目的接口
+type QuestionPayload struct {
+	// Options 选择题的选项，按显示顺序排列
+	Options []ChoiceOption `json:"options,omitempty"`
+	// Answers 选择题正确选项的 ID，单选题只有一个
+	Answers []string `json:"answers,omitempty"`
+	// Correct 判断题的正确答案
+	Correct *bool `json:"correct,omitempty"`
+	// Blanks 填空题的空位
+	Blanks []ClozeBlank `json:"blanks,omitempty"`
+	// AcceptedAnswers 简答题可接受的答案，判分时忽略大小写和多余空白
+	AcceptedAnswers []string `json:"accepted_answers,omitempty"`
+	// Patterns 简答题可接受答案的正则表达式
+	Patterns []string `json:"patterns,omitempty"`
+	// Pairs 连线题的正确配对
+	Pairs []MatchPair `json:"pairs,omitempty"`
+	// Items 排序题的条目，按正确顺序排列
+	Items []ChoiceOption `json:"items,omitempty"`
+	// Templates 编程题每种语言的起始代码和参考答案
+	Templates []CodeTemplate `json:"templates,omitempty"`
+	// TestCases 编程题的测试用例，包括公开用例和隐藏用例
+	TestCases []TestCase `json:"test_cases,omitempty"`
+	// Limits 编程题的时间和内存限制
+	Limits *ExecutionLimits `json:"limits,omitempty"`
+}
+
+func (p QuestionPayload) GormDataType() string {
+	return "text"
+}
+
+// Value 实现 driver.Valuer 接口
+func (p QuestionPayload) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (p *QuestionPayload) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("cannot scan non-string value into QuestionPayload")
+	}
+	if len(bytes) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// QuestionReview 题目状态流转记录，包括审核意见
+type QuestionReview struct {
+	ID         uint `json:"id" gorm:"primaryKey"`
+	QuestionID uint `json:"question_id" gorm:"index"`
+	// Action 流转操作，修改或回滚题目后退回草稿时为 edit、restore
+	Action     string         `json:"action" gorm:"type:varchar(20)"`
+	FromStatus QuestionStatus `json:"from_status" gorm:"type:varchar(20)"`
+	ToStatus   QuestionStatus `json:"to_status" gorm:"type:varchar(20)"`
+	// Reviewer 操作人，取自请求头 X-User
+	Reviewer  string    `json:"reviewer" gorm:"type:varchar(100)"`
+	Comment   string    `json:"comment" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
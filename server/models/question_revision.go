@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// RevisionAction 产生修订版本的操作
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+)
+
+// QuestionRevision 题目的修订版本，每次新建、修改、删除、回滚都追加一条，创建后不再修改
+type QuestionRevision struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	QuestionID uint             `json:"question_id" gorm:"uniqueIndex:idx_question_revision"`
+	Revision   int              `json:"revision" gorm:"uniqueIndex:idx_question_revision"` // 同一道题目内从 1 开始递增
+	Action     RevisionAction   `json:"action" gorm:"type:varchar(20)"`
+	Snapshot   QuestionSnapshot `json:"snapshot" gorm:"type:text"`
+	// Author 操作人，取自请求头 X-User
+	Author    string    `json:"author" gorm:"type:varchar(100)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QuestionSnapshot 题目在某个版本的完整内容，标签保存为路径
+type QuestionSnapshot struct {
+	Type    QuestionType `json:"type"`
+	Content string       `json:"content"`
+	Options JSON         `json:"options"`
+	Answer  string       `json:"answer"`
+	// Payload 结构化内容，增加该字段之前记录的版本为空
+	Payload     *QuestionPayload `json:"payload,omitempty"`
+	Explanation string           `json:"explanation"`
+	Difficulty  Difficulty       `json:"difficulty"`
+	Language    string           `json:"language"`
+	Locale      string           `json:"locale"`
+	Tags        []string         `json:"tags"`
+}
+
+func (s QuestionSnapshot) GormDataType() string {
+	return "text"
+}
+
+// Value 实现 driver.Valuer 接口
+func (s QuestionSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (s *QuestionSnapshot) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("cannot scan non-string value into QuestionSnapshot")
+	}
+	if len(bytes) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// QuestionTranslation 题目在其他语言下的内容，每道题目每种语言一条
+type QuestionTranslation struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	QuestionID  uint   `json:"question_id" gorm:"uniqueIndex:idx_question_locale"`
+	Locale      string `json:"locale" gorm:"type:varchar(20);uniqueIndex:idx_question_locale"`
+	Content     string `json:"content" gorm:"type:text"`
+	Options     JSON   `json:"options" gorm:"type:text"`
+	Explanation string `json:"explanation" gorm:"type:text"`
+	// Payload 结构化内容的译文，只有选项、空位答案、可接受答案、配对和排序条目的文字
+	Payload QuestionPayload `json:"payload"`
+	// SourceHash 翻译时原文的哈希，原文修改后不一致，译文不再使用
+	SourceHash string `json:"source_hash" gorm:"type:varchar(64)"`
+	// Stale 原文在翻译后是否修改过，不保存
+	Stale     bool      `json:"stale" gorm:"-"`
+	Source    string    `json:"source" gorm:"type:varchar(20)"` // ai 或 manual
+	Model     string    `json:"model" gorm:"type:varchar(100)"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
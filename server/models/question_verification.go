@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+type VerificationStatus string
+
+const (
+	VerificationAgreed    VerificationStatus = "agreed"    // 校验模型的答案与题目答案一致
+	VerificationDisagreed VerificationStatus = "disagreed" // 答案不一致，需要人工核对
+	VerificationError     VerificationStatus = "error"     // 校验调用失败或输出无法解析
+)
+
+// QuestionVerification 对题库中题目答案的一次校验记录
+type QuestionVerification struct {
+	ID             uint               `json:"id" gorm:"primaryKey"`
+	QuestionID     uint               `json:"question_id" gorm:"index"`
+	Status         VerificationStatus `json:"status" gorm:"type:varchar(20);index"`
+	Answer         string             `json:"answer" gorm:"type:text"`          // 校验时题目的答案
+	VerifierAnswer string             `json:"verifier_answer" gorm:"type:text"` // 校验模型给出的答案
+	Reasoning      string             `json:"reasoning" gorm:"type:text"`
+	Model          string             `json:"model" gorm:"type:varchar(100)"`
+	Error          string             `json:"error" gorm:"type:text"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag 知识点标签，通过 ParentID 组成层级，如 Go > 并发 > Channel
+type Tag struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"type:varchar(100)"`
+	ParentID *uint  `json:"parent_id" gorm:"index"`
+	// Path 从根标签到当前标签的完整路径，如 "Go/并发/Channel"，改名或移动时连同子标签一起更新
+	Path      string    `json:"path" gorm:"type:varchar(500);uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"homework-server/models"
+)
+
+// 选择题的选项数量限制
+const (
+	minChoiceOptions = 2
+	maxChoiceOptions = 10
+)
+
+var optionIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,36}$`)
+
+// prepareQuestionRequest 校验新建、编辑、导入的题目并整理结构化内容。
+// 请求没有 payload 时由旧格式的 options、answer 转换，错误也按旧字段名返回；
+// 通过校验后 options、answer 按 payload 重新生成，保证两种格式一致
+func prepareQuestionRequest(req *QuestionRequest) []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(req.Content) == "" {
+		errs = append(errs, FieldError{Field: "content", Message: "题目内容不能为空"})
+	}
+	if req.Difficulty != "" && req.Difficulty != models.Easy && req.Difficulty != models.Medium && req.Difficulty != models.Hard {
+		errs = append(errs, FieldError{Field: "difficulty", Message: "难度必须是 easy、medium 或 hard"})
+	}
+	spec := lookupQuestionType(req.Type)
+	if spec == nil {
+		return append(errs, FieldError{Field: "type", Message: "不支持的题目类型: " + string(req.Type)})
+	}
+
+	legacy := req.Payload == nil
+	if legacy && !spec.LegacyInput {
+		return append(errs, FieldError{Field: "payload", Message: spec.Label + "必须提交 payload"})
+	}
+	var payload models.QuestionPayload
+	if legacy {
+		payload = payloadFromLegacy(req.Type, req.Options, req.Answer)
+	} else {
+		payload = *req.Payload
+	}
+	normalizePayload(&payload)
+
+	for _, e := range validatePayload(req.Type, req.Content, payload) {
+		// content 为题目内容本身的错误，如填空题的空位标记，保持原字段名
+		if e.Field != "content" {
+			if legacy {
+				e.Field = legacyFieldName(e.Field)
+			} else {
+				e.Field = "payload." + e.Field
+			}
+		}
+		errs = append(errs, e)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	req.Payload = &payload
+	req.Options, req.Answer = spec.Legacy(payload)
+	return nil
+}
+
+// payloadFromLegacy 把旧格式的选项和答案转换为结构化内容：选项按键排序，空选项忽略；
+// 选择题的答案按逗号拆分并且不区分大小写地匹配选项，其他题型的答案原样保留，交给校验报错
+func payloadFromLegacy(t models.QuestionType, options models.JSON, answer string) models.QuestionPayload {
+	var p models.QuestionPayload
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		text := strings.TrimSpace(fmt.Sprintf("%v", options[key]))
+		if options[key] == nil || text == "" {
+			continue
+		}
+		p.Options = append(p.Options, models.ChoiceOption{ID: key, Text: text})
+	}
+
+	if t != models.SingleChoice && t != models.MultipleChoice {
+		if strings.TrimSpace(answer) != "" {
+			p.Answers = []string{answer}
+		}
+		return p
+	}
+	for _, a := range strings.Split(answer, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		for _, o := range p.Options {
+			if strings.EqualFold(o.ID, a) {
+				a = o.ID
+				break
+			}
+		}
+		p.Answers = append(p.Answers, a)
+	}
+	return p
+}
+
+// normalizePayload 去掉首尾空白，没有 ID 的选项和排序条目按 A、B、C… 分配未使用的 ID，
+// 没有 ID 的连线配对和测试用例按 1、2、3… 分配
+func normalizePayload(p *models.QuestionPayload) {
+	assignOptionIDs(p.Options)
+	assignOptionIDs(p.Items)
+	for i := range p.Answers {
+		p.Answers[i] = strings.TrimSpace(p.Answers[i])
+	}
+	for i := range p.Blanks {
+		p.Blanks[i].ID = strings.TrimSpace(p.Blanks[i].ID)
+		trimStrings(p.Blanks[i].Answers)
+	}
+	trimStrings(p.AcceptedAnswers)
+
+	for i := range p.Pairs {
+		p.Pairs[i].ID = strings.TrimSpace(p.Pairs[i].ID)
+		p.Pairs[i].Left = strings.TrimSpace(p.Pairs[i].Left)
+		p.Pairs[i].Right = strings.TrimSpace(p.Pairs[i].Right)
+	}
+	assignNumericIDs(len(p.Pairs), func(i int) *string { return &p.Pairs[i].ID })
+	normalizeProgramming(p)
+}
+
+// assignNumericIDs 为没有 ID 的条目按 1、2、3… 分配未使用的 ID，id 返回第 i 个条目的 ID
+func assignNumericIDs(n int, id func(i int) *string) {
+	used := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		used[*id(i)] = true
+	}
+	next := 1
+	for i := 0; i < n; i++ {
+		if *id(i) != "" {
+			continue
+		}
+		for ; used[strconv.Itoa(next)]; next++ {
+		}
+		*id(i) = strconv.Itoa(next)
+		used[*id(i)] = true
+	}
+}
+
+func assignOptionIDs(options []models.ChoiceOption) {
+	used := make(map[string]bool)
+	for i := range options {
+		options[i].ID = strings.TrimSpace(options[i].ID)
+		options[i].Text = strings.TrimSpace(options[i].Text)
+		used[options[i].ID] = true
+	}
+	next := 0
+	for i := range options {
+		if options[i].ID != "" {
+			continue
+		}
+		for ; next < 26 && used[string(rune('A'+next))]; next++ {
+		}
+		if next < 26 {
+			options[i].ID = string(rune('A' + next))
+			used[options[i].ID] = true
+		}
+	}
+}
+
+func trimStrings(list []string) {
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+}
+
+// validatePayload 按题型校验结构化内容，题型没有使用的字段不能设置；字段名相对于 payload
+func validatePayload(t models.QuestionType, content string, p models.QuestionPayload) []FieldError {
+	spec := lookupQuestionType(t)
+	if spec == nil {
+		return []FieldError{{Field: "type", Message: "不支持的题目类型: " + string(t)}}
+	}
+	var errs []FieldError
+	for _, f := range payloadFields {
+		if payloadFieldSet(p, f.Name) && !containsString(spec.Fields, f.Name) {
+			errs = append(errs, FieldError{Field: f.Name, Message: spec.Label + "不能设置" + f.Label})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return spec.Validate(content, p)
+}
+
+// legacyFieldName 把 payload 中的字段名换成旧格式的字段名
+func legacyFieldName(field string) string {
+	if strings.HasPrefix(field, "answers") {
+		return "answer"
+	}
+	if strings.HasPrefix(field, "options") {
+		return "options"
+	}
+	return field
+}
+
+// questionPayload 题目的结构化内容，旧数据没有 payload 时由 options、answer 转换
+func questionPayload(t models.QuestionType, payload *models.QuestionPayload, options models.JSON, answer string) models.QuestionPayload {
+	if payload != nil {
+		return *payload
+	}
+	if converted, ok := validateAndConvertType(string(t)); ok {
+		t = converted
+	}
+	p := payloadFromLegacy(t, options, answer)
+	normalizePayload(&p)
+	return p
+}
+
+// backfillQuestionPayloads 为增加 payload 字段之前的题目生成结构化内容，不做校验
+func backfillQuestionPayloads() {
+	var questions []models.Question
+	if err := db.Where("payload IS NULL OR payload = ''").Find(&questions).Error; err != nil {
+		log.Printf("Failed to load questions for payload backfill: %v", err)
+		return
+	}
+	for _, q := range questions {
+		payload := questionPayload(q.Type, nil, q.Options, q.Answer)
+		if err := db.Model(&q).UpdateColumn("payload", payload).Error; err != nil {
+			log.Printf("Failed to convert payload for question %d: %v", q.ID, err)
+		}
+	}
+	if len(questions) > 0 {
+		log.Printf("Converted structured payloads for %d questions", len(questions))
+	}
+}
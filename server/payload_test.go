@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type validationResponse struct {
+	Data    models.Question `json:"data"`
+	Error   string          `json:"error"`
+	Errors  []FieldError    `json:"errors"`
+	Invalid []ImportError   `json:"invalid"`
+}
+
+func errorFields(errs []FieldError) []string {
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestQuestionFieldErrors(t *testing.T) {
+	resetData(t)
+	choice := func(change func(q gin.H)) gin.H {
+		q := choiceQuestion("goroutine 泄漏的原因")
+		change(q)
+		return q
+	}
+	payload := func(p gin.H) gin.H {
+		return gin.H{"type": "multiple_choice", "content": "哪些类型是引用类型", "difficulty": "easy", "language": "Go", "payload": p}
+	}
+
+	tests := []struct {
+		name string
+		body gin.H
+		want []string
+	}{
+		{"blank content", choice(func(q gin.H) { q["content"] = " " }), []string{"content"}},
+		{"bad difficulty", choice(func(q gin.H) { q["difficulty"] = "expert" }), []string{"difficulty"}},
+		{"unknown type", choice(func(q gin.H) { q["type"] = "essay" }), []string{"type"}},
+		// 旧格式的错误按 options、answer 返回
+		{"legacy answer not an option", choice(func(q gin.H) { q["answer"] = "E" }), []string{"answer"}},
+		{"legacy missing answer", choice(func(q gin.H) { q["answer"] = "" }), []string{"answer"}},
+		{"legacy duplicate option text", choice(func(q gin.H) { q["options"] = gin.H{"A": "chan", "B": "chan"} }), []string{"options"}},
+		{"legacy single choice with two answers", choice(func(q gin.H) { q["answer"] = "A,B" }), []string{"answer"}},
+		// payload 的错误带 payload. 前缀和下标
+		{"payload too few options", payload(gin.H{"options": []gin.H{{"text": "map"}}, "answers": []string{"A"}}), []string{"payload.options"}},
+		{"payload empty option", payload(gin.H{
+			"options": []gin.H{{"text": "map"}, {"text": " "}, {"text": "slice"}},
+			"answers": []string{"A", "Z"},
+		}), []string{"payload.answers[1]", "payload.options[1].text"}},
+		{"payload field of another type", payload(gin.H{
+			"options":          []gin.H{{"text": "map"}, {"text": "slice"}},
+			"answers":          []string{"A"},
+			"accepted_answers": []string{"map"},
+		}), []string{"payload.accepted_answers"}},
+	}
+	for _, tt := range tests {
+		var resp validationResponse
+		code := apiRequest(t, http.MethodPost, "/api/questions", "", tt.body, &resp)
+		if got := errorFields(resp.Errors); code != http.StatusBadRequest || !equalStrings(got, tt.want) {
+			t.Errorf("%s: status = %d, fields = %v, want %v (%+v)", tt.name, code, got, tt.want, resp.Errors)
+		}
+	}
+
+	// 通过校验后 options、answer 按 payload 重新生成
+	var resp validationResponse
+	body := payload(gin.H{"options": []gin.H{{"text": " map "}, {"text": "slice"}, {"text": "int"}}, "answers": []string{"B", "A"}})
+	if code := apiRequest(t, http.MethodPost, "/api/questions", "", body, &resp); code != http.StatusCreated {
+		t.Fatalf("valid payload: status = %d, errors = %+v", code, resp.Errors)
+	}
+	if resp.Data.Answer != "A,B" || resp.Data.Options["A"] != "map" || len(resp.Data.Payload.Options) != 3 {
+		t.Errorf("saved answer = %q, options = %v", resp.Data.Answer, resp.Data.Options)
+	}
+}
+
+func TestImportReportsInvalidIndexes(t *testing.T) {
+	resetData(t)
+	bad := choiceQuestion("channel 关闭后还能读取吗")
+	bad["answer"] = "F"
+	items := []gin.H{choiceQuestion("channel 的缓冲区"), bad, {"type": "single_choice", "content": " ", "difficulty": "expert"}}
+
+	var resp validationResponse
+	if code := apiRequest(t, http.MethodPost, "/api/questions/import", "", items, &resp); code != http.StatusBadRequest {
+		t.Fatalf("import: status = %d, want 400", code)
+	}
+	if len(resp.Invalid) != 2 || resp.Invalid[0].Index != 1 || resp.Invalid[1].Index != 2 {
+		t.Fatalf("invalid = %+v, want indexes 1 and 2 (%s)", resp.Invalid, resp.Error)
+	}
+	if got := errorFields(resp.Invalid[0].Errors); !equalStrings(got, []string{"answer"}) {
+		t.Errorf("item 1 fields = %v", got)
+	}
+	if got := errorFields(resp.Invalid[1].Errors); !containsString(got, "content") || !containsString(got, "difficulty") {
+		t.Errorf("item 2 fields = %v, want content and difficulty", got)
+	}
+
+	// 任何一道题目未通过校验时都不导入
+	if _, list := listQuestions(t, ""); len(list.Data) != 0 {
+		t.Errorf("imported %d questions despite validation errors", len(list.Data))
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 编程题测试用例和运行限制的取值范围，未指定限制时使用默认值
+const (
+	maxTestCases         = 50
+	defaultTimeLimitMs   = 1000
+	maxTimeLimitMs       = 10000
+	defaultMemoryLimitMB = 256
+	minMemoryLimitMB     = 16
+	maxMemoryLimitMB     = 1024
+)
+
+var functionNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+var errNotProgramming = errors.New("只有编程题可以设置代码和测试用例")
+
+// ProgrammingRequest 修改编程题的代码、测试用例和运行限制，未传的字段保持不变
+type ProgrammingRequest struct {
+	Templates *[]models.CodeTemplate  `json:"templates"`
+	TestCases *[]models.TestCase      `json:"test_cases"`
+	Limits    *models.ExecutionLimits `json:"limits"`
+}
+
+// ProgrammingDetail 编程题的代码、测试用例和运行限制
+type ProgrammingDetail struct {
+	Language  string                  `json:"language"`
+	Templates []models.CodeTemplate   `json:"templates"`
+	TestCases []models.TestCase       `json:"test_cases"`
+	Limits    *models.ExecutionLimits `json:"limits"`
+}
+
+// normalizeProgramming 去掉语言名的首尾空白，测试用例默认按标准输入输出比对，
+// 有测试用例但没有指定的运行限制使用默认值
+func normalizeProgramming(p *models.QuestionPayload) {
+	for i := range p.Templates {
+		p.Templates[i].Language = strings.TrimSpace(p.Templates[i].Language)
+	}
+	for i := range p.TestCases {
+		p.TestCases[i].ID = strings.TrimSpace(p.TestCases[i].ID)
+		p.TestCases[i].Function = strings.TrimSpace(p.TestCases[i].Function)
+		if p.TestCases[i].Kind == "" {
+			if p.TestCases[i].Function != "" {
+				p.TestCases[i].Kind = models.TestCaseFunction
+			} else {
+				p.TestCases[i].Kind = models.TestCaseStdio
+			}
+		}
+	}
+	assignNumericIDs(len(p.TestCases), func(i int) *string { return &p.TestCases[i].ID })
+
+	if len(p.TestCases) > 0 && p.Limits == nil {
+		p.Limits = &models.ExecutionLimits{}
+	}
+	if p.Limits != nil {
+		if p.Limits.TimeMs == 0 {
+			p.Limits.TimeMs = defaultTimeLimitMs
+		}
+		if p.Limits.MemoryMB == 0 {
+			p.Limits.MemoryMB = defaultMemoryLimitMB
+		}
+	}
+}
+
+// validateProgrammingPayload 每种语言只能有一份代码；测试用例按类型填写对应的字段，
+// 并且至少有一个公开用例给学习者参考；运行限制在允许的范围内
+func validateProgrammingPayload(content string, p models.QuestionPayload) []FieldError {
+	var errs []FieldError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	languages := make(map[string]bool)
+	for i, t := range p.Templates {
+		field := fmt.Sprintf("templates[%d]", i)
+		key := strings.ToLower(t.Language)
+		if t.Language == "" {
+			fail(field+".language", "请指定编程语言")
+		} else if languages[key] {
+			fail(field+".language", "编程语言重复: %s", t.Language)
+		}
+		languages[key] = true
+		if strings.TrimSpace(t.Starter) == "" && strings.TrimSpace(t.Solution) == "" {
+			fail(field, "起始代码和参考答案至少填写一项")
+		}
+	}
+
+	if len(p.TestCases) > maxTestCases {
+		fail("test_cases", "测试用例不能超过%d个，当前%d个", maxTestCases, len(p.TestCases))
+	}
+	ids := make(map[string]bool)
+	public := 0
+	for i, tc := range p.TestCases {
+		field := fmt.Sprintf("test_cases[%d]", i)
+		if !optionIDRe.MatchString(tc.ID) {
+			fail(field+".id", "ID只能包含字母、数字、下划线和连字符，长度不超过36")
+		} else if ids[tc.ID] {
+			fail(field+".id", "ID重复: %s", tc.ID)
+		}
+		ids[tc.ID] = true
+		if !tc.Hidden {
+			public++
+		}
+
+		switch tc.Kind {
+		case models.TestCaseStdio:
+			if tc.Output == "" {
+				fail(field+".output", "请填写期望的标准输出")
+			}
+			if tc.Function != "" || len(tc.Args) > 0 || tc.Expected != nil {
+				fail(field, "标准输入输出用例不能设置 function、args、expected")
+			}
+		case models.TestCaseFunction:
+			if !functionNameRe.MatchString(tc.Function) {
+				fail(field+".function", "函数名无效: %s", tc.Function)
+			}
+			if tc.Expected == nil {
+				fail(field+".expected", "请填写期望的返回值")
+			}
+			if tc.Input != "" || tc.Output != "" {
+				fail(field, "函数调用用例不能设置 input、output")
+			}
+		default:
+			fail(field+".kind", "测试用例类型必须是 stdio 或 function")
+		}
+	}
+	if len(p.TestCases) > 0 && public == 0 {
+		fail("test_cases", "至少需要一个公开的测试用例")
+	}
+
+	if l := p.Limits; l != nil {
+		if l.TimeMs < 1 || l.TimeMs > maxTimeLimitMs {
+			fail("limits.time_ms", "时间限制需要在1到%d毫秒之间", maxTimeLimitMs)
+		}
+		if l.MemoryMB < minMemoryLimitMB || l.MemoryMB > maxMemoryLimitMB {
+			fail("limits.memory_mb", "内存限制需要在%d到%dMB之间", minMemoryLimitMB, maxMemoryLimitMB)
+		}
+	}
+	return errs
+}
+
+// keepProgrammingPayload 旧格式的编辑请求不包含代码和测试用例，沿用题目原有的内容
+func keepProgrammingPayload(dst *models.QuestionPayload, old models.QuestionPayload) {
+	dst.Templates = old.Templates
+	dst.TestCases = old.TestCases
+	dst.Limits = old.Limits
+}
+
+// isAuthorView 请求是否指定了 view=author，出题端查看时才返回参考答案和隐藏用例
+func isAuthorView(c *gin.Context) bool {
+	return c.Query("view") == "author"
+}
+
+// learnerPayload 学习者看到的编程题内容：只有起始代码和公开用例
+func learnerPayload(p models.QuestionPayload) models.QuestionPayload {
+	if len(p.Templates) > 0 {
+		templates := make([]models.CodeTemplate, 0, len(p.Templates))
+		for _, t := range p.Templates {
+			if t.Starter != "" {
+				templates = append(templates, models.CodeTemplate{Language: t.Language, Starter: t.Starter})
+			}
+		}
+		p.Templates = templates
+	}
+	if len(p.TestCases) > 0 {
+		cases := make([]models.TestCase, 0, len(p.TestCases))
+		for _, tc := range p.TestCases {
+			if !tc.Hidden {
+				cases = append(cases, tc)
+			}
+		}
+		p.TestCases = cases
+	}
+	return p
+}
+
+// redactQuestions 不是出题端查看时，去掉题目中编程题的参考答案和隐藏用例
+func redactQuestions(c *gin.Context, questions []models.Question) {
+	if isAuthorView(c) {
+		return
+	}
+	for i := range questions {
+		questions[i].Payload = learnerPayload(questions[i].Payload)
+	}
+}
+
+func programmingDetail(q models.Question) ProgrammingDetail {
+	d := ProgrammingDetail{
+		Language:  q.Language,
+		Templates: q.Payload.Templates,
+		TestCases: q.Payload.TestCases,
+		Limits:    q.Payload.Limits,
+	}
+	if d.Templates == nil {
+		d.Templates = []models.CodeTemplate{}
+	}
+	if d.TestCases == nil {
+		d.TestCases = []models.TestCase{}
+	}
+	return d
+}
+
+// updateProgramming 修改编程题的 payload，校验通过后保存并记录一个新版本，题目退回草稿；
+// change 修改失败时返回响应的状态码和错误
+func updateProgramming(c *gin.Context, change func(p *models.QuestionPayload) (int, error)) {
+	var question models.Question
+	if err := db.Preload("Tags").First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if question.Type != models.Programming {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNotProgramming.Error()})
+		return
+	}
+
+	payload := question.Payload
+	if status, err := change(&payload); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	normalizePayload(&payload)
+	if errs := validatePayload(question.Type, question.Content, payload); len(errs) > 0 {
+		for i := range errs {
+			errs[i].Field = "payload." + errs[i].Field
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目校验失败", "errors": errs})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&question).Update("payload", payload).Error; err != nil {
+			return err
+		}
+		question.Payload = payload
+		if err := saveQuestionFingerprint(tx, &question); err != nil {
+			return err
+		}
+		if err := returnToDraft(tx, &question, "edit", requestUser(c)); err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question.ID, models.RevisionUpdate, requestUser(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": programmingDetail(question)})
+}
+
+// 19.1 编程题的代码、测试用例和运行限制，默认只返回起始代码和公开用例，
+// view=author 时返回参考答案和全部用例
+func getProgramming(c *gin.Context) {
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if question.Type != models.Programming {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNotProgramming.Error()})
+		return
+	}
+
+	if !isAuthorView(c) {
+		question.Payload = learnerPayload(question.Payload)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": programmingDetail(question)})
+}
+
+// 19.2 修改编程题的代码、测试用例和运行限制，传入的字段整体替换
+func updateProgrammingHandler(c *gin.Context) {
+	var req ProgrammingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	updateProgramming(c, func(p *models.QuestionPayload) (int, error) {
+		if req.Templates != nil {
+			p.Templates = *req.Templates
+		}
+		if req.TestCases != nil {
+			p.TestCases = *req.TestCases
+		}
+		if req.Limits != nil {
+			p.Limits = req.Limits
+		}
+		return 0, nil
+	})
+}
+
+// 19.3 添加测试用例，不指定 ID 时自动分配
+func addTestCase(c *gin.Context) {
+	var tc models.TestCase
+	if err := c.ShouldBindJSON(&tc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	tc.ID = strings.TrimSpace(tc.ID)
+
+	updateProgramming(c, func(p *models.QuestionPayload) (int, error) {
+		for _, existing := range p.TestCases {
+			if tc.ID != "" && existing.ID == tc.ID {
+				return http.StatusConflict, errors.New("测试用例已存在: " + tc.ID)
+			}
+		}
+		p.TestCases = append(p.TestCases, tc)
+		return 0, nil
+	})
+}
+
+// 19.4 修改测试用例
+func updateTestCase(c *gin.Context) {
+	var tc models.TestCase
+	if err := c.ShouldBindJSON(&tc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	tc.ID = c.Param("cid")
+
+	updateProgramming(c, func(p *models.QuestionPayload) (int, error) {
+		for i := range p.TestCases {
+			if p.TestCases[i].ID == tc.ID {
+				p.TestCases[i] = tc
+				return 0, nil
+			}
+		}
+		return http.StatusNotFound, errors.New("测试用例不存在: " + tc.ID)
+	})
+}
+
+// 19.5 删除测试用例
+func deleteTestCase(c *gin.Context) {
+	id := c.Param("cid")
+
+	updateProgramming(c, func(p *models.QuestionPayload) (int, error) {
+		for i := range p.TestCases {
+			if p.TestCases[i].ID == id {
+				p.TestCases = append(p.TestCases[:i:i], p.TestCases[i+1:]...)
+				return 0, nil
+			}
+		}
+		return http.StatusNotFound, errors.New("测试用例不存在: " + id)
+	})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// programmingQuestion 带参考答案、一个公开用例和一个隐藏用例的编程题
+func programmingQuestion(content string) gin.H {
+	return gin.H{
+		"type":       "programming",
+		"content":    content,
+		"difficulty": "easy",
+		"language":   "Go",
+		"payload": gin.H{
+			"templates": []gin.H{{"language": "Go", "starter": "func Add(a, b int) int {\n}", "solution": "func Add(a, b int) int {\n\treturn a + b\n}"}},
+			"test_cases": []gin.H{
+				{"function": "Add", "args": []int{1, 2}, "expected": 3},
+				{"input": "secret-input", "output": "secret-output", "hidden": true},
+			},
+		},
+	}
+}
+
+func TestLearnerResponsesHideSolutions(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", programmingQuestion("实现两数相加"))
+	for _, s := range []struct{ action, user string }{{"submit", "alice"}, {"approve", "bob"}, {"publish", "alice"}} {
+		if code, resp := transition(t, q.ID, s.action, s.user, nil); code != http.StatusOK {
+			t.Fatalf("%s: status = %d (%s)", s.action, code, resp.Error)
+		}
+	}
+
+	paths := []string{
+		"/api/questions",
+		"/api/questions?status=all",
+		fmt.Sprintf("/api/questions/%d/variants", q.ID),
+		fmt.Sprintf("/api/questions/%d/programming", q.ID),
+	}
+	for _, path := range paths {
+		assertLearnerView(t, path)
+	}
+
+	// 去掉的只是响应中的内容，数据库中的题目保持不变
+	var saved models.Question
+	db.First(&saved, q.ID)
+	if len(saved.Payload.TestCases) != 2 || saved.Payload.Templates[0].Solution == "" {
+		t.Errorf("stored payload changed: %+v", saved.Payload)
+	}
+}
+
+// assertLearnerView 检查默认的响应不包含参考答案和隐藏用例，view=author 时包含
+func assertLearnerView(t *testing.T, path string) {
+	t.Helper()
+	body := rawGet(t, path)
+	if !strings.Contains(body, "func Add(a, b int) int {\\n}") {
+		t.Errorf("%s: starter code missing: %s", path, body)
+	}
+	for _, secret := range []string{"return a + b", "secret-input", "secret-output", `"solution"`} {
+		if strings.Contains(body, secret) {
+			t.Errorf("%s: learner response contains %q", path, secret)
+		}
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	author := rawGet(t, path+sep+"view=author")
+	for _, secret := range []string{"return a + b", "secret-input"} {
+		if !strings.Contains(author, secret) {
+			t.Errorf("%s with view=author: missing %q", path, secret)
+		}
+	}
+}
+
+// rawGet 返回响应的原始 JSON，用于检查响应中是否包含某段文本
+func rawGet(t *testing.T, path string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status = %d", path, w.Code)
+	}
+	return w.Body.String()
+}
+
+type programmingResponse struct {
+	Data   ProgrammingDetail `json:"data"`
+	Error  string            `json:"error"`
+	Errors []FieldError      `json:"errors"`
+}
+
+func TestTestCaseCRUD(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", programmingQuestion("实现两数之和"))
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+	request := func(method, path string, body interface{}) (int, programmingResponse) {
+		t.Helper()
+		var resp programmingResponse
+		code := apiRequest(t, method, base+path, "alice", body, &resp)
+		return code, resp
+	}
+
+	// 不指定 ID 时分配未使用的编号，种类按字段推断，运行限制使用默认值
+	code, resp := request(http.MethodPost, "/test-cases", gin.H{"input": "2 3", "output": "5"})
+	if code != http.StatusOK || len(resp.Data.TestCases) != 3 {
+		t.Fatalf("add: status = %d, cases = %d (%s)", code, len(resp.Data.TestCases), resp.Error)
+	}
+	added := resp.Data.TestCases[2]
+	if added.ID != "3" || added.Kind != models.TestCaseStdio {
+		t.Errorf("added case = %+v, want id 3 and kind stdio", added)
+	}
+	if l := resp.Data.Limits; l == nil || l.TimeMs != defaultTimeLimitMs || l.MemoryMB != defaultMemoryLimitMB {
+		t.Errorf("limits = %+v, want defaults", l)
+	}
+
+	if code, resp = request(http.MethodPost, "/test-cases", gin.H{"id": "3", "input": "1", "output": "1"}); code != http.StatusConflict {
+		t.Errorf("add duplicate id: status = %d, want 409 (%s)", code, resp.Error)
+	}
+	if code, resp = request(http.MethodPost, "/test-cases", gin.H{"kind": "function", "function": "1bad"}); code != http.StatusBadRequest ||
+		!equalStrings(errorFields(resp.Errors), []string{"payload.test_cases[3].expected", "payload.test_cases[3].function"}) {
+		t.Errorf("add invalid case: status = %d, errors = %+v", code, resp.Errors)
+	}
+
+	code, resp = request(http.MethodPut, "/test-cases/3", gin.H{"input": "2 3", "output": "5", "hidden": true})
+	if code != http.StatusOK || !resp.Data.TestCases[2].Hidden {
+		t.Errorf("update: status = %d, cases = %+v", code, resp.Data.TestCases)
+	}
+	if code, resp = request(http.MethodPut, "/test-cases/9", gin.H{"input": "1", "output": "1"}); code != http.StatusNotFound {
+		t.Errorf("update missing case: status = %d, want 404 (%s)", code, resp.Error)
+	}
+
+	// 删除后只剩隐藏用例时不能保存
+	if code, resp = request(http.MethodDelete, "/test-cases/1", nil); code != http.StatusBadRequest {
+		t.Errorf("delete last public case: status = %d, want 400", code)
+	}
+	request(http.MethodPost, "/test-cases", gin.H{"id": "sample", "input": "0 0", "output": "0"})
+	if code, resp = request(http.MethodDelete, "/test-cases/1", nil); code != http.StatusOK || len(resp.Data.TestCases) != 3 {
+		t.Errorf("delete: status = %d, cases = %d", code, len(resp.Data.TestCases))
+	}
+	if code, resp = request(http.MethodDelete, "/test-cases/1", nil); code != http.StatusNotFound {
+		t.Errorf("delete twice: status = %d, want 404", code)
+	}
+
+	code, resp = request(http.MethodPut, "/programming", gin.H{"limits": gin.H{"time_ms": 20000, "memory_mb": 8}})
+	if code != http.StatusBadRequest || !equalStrings(errorFields(resp.Errors), []string{"payload.limits.memory_mb", "payload.limits.time_ms"}) {
+		t.Errorf("invalid limits: status = %d, errors = %+v", code, resp.Errors)
+	}
+
+	// 每次修改都记录一个版本
+	var revisions int64
+	db.Model(&models.QuestionRevision{}).Where("question_id = ?", q.ID).Count(&revisions)
+	if revisions != 5 {
+		t.Errorf("revisions = %d, want 5", revisions)
+	}
+
+	choice := createQuestion(t, "alice", choiceQuestion("defer 的参数何时求值"))
+	path := fmt.Sprintf("/api/questions/%d/test-cases", choice.ID)
+	if code := apiRequest(t, http.MethodPost, path, "", gin.H{"input": "1", "output": "1"}, nil); code != http.StatusBadRequest {
+		t.Errorf("add case to choice question: status = %d, want 400", code)
+	}
+	if code := apiRequest(t, http.MethodPost, "/api/questions/99999/test-cases", "", gin.H{"input": "1", "output": "1"}, nil); code != http.StatusNotFound {
+		t.Errorf("add case to missing question: status = %d, want 404", code)
+	}
+}
+
+func TestValidateProgrammingPayload(t *testing.T) {
+	limits := func(timeMs, memoryMB int) *models.ExecutionLimits {
+		return &models.ExecutionLimits{TimeMs: timeMs, MemoryMB: memoryMB}
+	}
+	stdio := models.TestCase{ID: "1", Kind: models.TestCaseStdio, Input: "1", Output: "1"}
+	fn := models.TestCase{ID: "2", Kind: models.TestCaseFunction, Function: "pkg.Add", Args: []interface{}{1, 2}, Expected: 3}
+	many := make([]models.TestCase, maxTestCases+1)
+	for i := range many {
+		many[i] = stdio
+		many[i].ID = fmt.Sprint(i + 1)
+	}
+
+	tests := []struct {
+		name string
+		p    models.QuestionPayload
+		want []string
+	}{
+		{"empty", models.QuestionPayload{}, nil},
+		{"valid", models.QuestionPayload{
+			Templates: []models.CodeTemplate{{Language: "Go", Starter: "package main"}, {Language: "Python", Solution: "print(1)"}},
+			TestCases: []models.TestCase{stdio, fn}, Limits: limits(1000, 256)}, nil},
+		{"template errors", models.QuestionPayload{Templates: []models.CodeTemplate{{Language: "Go", Starter: "x"}, {Language: "go", Starter: "y"}, {Starter: " "}}},
+			[]string{"templates[1].language", "templates[2]", "templates[2].language"}},
+		{"duplicate and bad ids", models.QuestionPayload{TestCases: []models.TestCase{stdio, stdio, {ID: "a b", Kind: models.TestCaseStdio, Output: "1"}}},
+			[]string{"test_cases[1].id", "test_cases[2].id"}},
+		{"stdio with function fields", models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseStdio, Function: "f", Expected: 1}}},
+			[]string{"test_cases[0]", "test_cases[0].output"}},
+		{"function with stdio fields", models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseFunction, Function: "f()", Input: "1"}}},
+			[]string{"test_cases[0]", "test_cases[0].expected", "test_cases[0].function"}},
+		{"unknown kind", models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: "http"}}}, []string{"test_cases[0].kind"}},
+		{"only hidden cases", models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseStdio, Output: "1", Hidden: true}}},
+			[]string{"test_cases"}},
+		{"too many cases", models.QuestionPayload{TestCases: many}, []string{"test_cases"}},
+		{"limits out of range", models.QuestionPayload{Limits: limits(0, 2048)}, []string{"limits.memory_mb", "limits.time_ms"}},
+	}
+	for _, tt := range tests {
+		got := errorFields(validateProgrammingPayload("", tt.p))
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeProgramming(t *testing.T) {
+	p := models.QuestionPayload{
+		Templates: []models.CodeTemplate{{Language: " Go ", Starter: "x"}},
+		TestCases: []models.TestCase{
+			{Input: "1", Output: "1"},
+			{ID: " 1 ", Function: " Add ", Expected: 2},
+			{ID: "", Kind: models.TestCaseStdio, Output: "3"},
+		},
+	}
+	normalizeProgramming(&p)
+
+	if p.Templates[0].Language != "Go" {
+		t.Errorf("language = %q, want trimmed", p.Templates[0].Language)
+	}
+	want := []struct {
+		id   string
+		kind models.TestCaseKind
+	}{{"2", models.TestCaseStdio}, {"1", models.TestCaseFunction}, {"3", models.TestCaseStdio}}
+	for i, w := range want {
+		if tc := p.TestCases[i]; tc.ID != w.id || tc.Kind != w.kind {
+			t.Errorf("case %d: id = %q, kind = %s, want %q, %s", i, tc.ID, tc.Kind, w.id, w.kind)
+		}
+	}
+	if p.TestCases[1].Function != "Add" {
+		t.Errorf("function = %q, want trimmed", p.TestCases[1].Function)
+	}
+	if p.Limits == nil || p.Limits.TimeMs != defaultTimeLimitMs || p.Limits.MemoryMB != defaultMemoryLimitMB {
+		t.Errorf("limits = %+v, want defaults", p.Limits)
+	}
+
+	// 指定的运行限制保留，没有测试用例时不补充运行限制
+	custom := models.QuestionPayload{Limits: &models.ExecutionLimits{TimeMs: 500}}
+	normalizeProgramming(&custom)
+	if custom.Limits.TimeMs != 500 || custom.Limits.MemoryMB != defaultMemoryLimitMB {
+		t.Errorf("custom limits = %+v", custom.Limits)
+	}
+	empty := models.QuestionPayload{}
+	normalizeProgramming(&empty)
+	if empty.Limits != nil {
+		t.Errorf("limits without test cases = %+v, want nil", empty.Limits)
+	}
+}
+
+func TestLegacyEditKeepsProgrammingPayload(t *testing.T) {
+	old := models.QuestionPayload{
+		Templates: []models.CodeTemplate{{Language: "Go", Starter: "x", Solution: "y"}},
+		TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseStdio, Output: "1"}},
+		Limits:    &models.ExecutionLimits{TimeMs: 100, MemoryMB: 64},
+	}
+	var p models.QuestionPayload
+	keepProgrammingPayload(&p, old)
+	if len(p.Templates) != 1 || len(p.TestCases) != 1 || p.Limits != old.Limits {
+		t.Errorf("kept payload = %+v", p)
+	}
+
+	// 旧格式的编辑请求不包含代码和测试用例，保存后沿用原有内容
+	resetData(t)
+	q := createQuestion(t, "alice", programmingQuestion("实现字符串反转"))
+	body := gin.H{"type": "programming", "content": "实现字符串反转，不使用标准库", "difficulty": "medium", "language": "Go"}
+	if code := apiRequest(t, http.MethodPut, fmt.Sprintf("/api/questions/%d", q.ID), "alice", body, nil); code != http.StatusOK {
+		t.Fatalf("legacy edit: status = %d", code)
+	}
+	var saved models.Question
+	db.First(&saved, q.ID)
+	if len(saved.Payload.TestCases) != 2 || len(saved.Payload.Templates) != 1 || saved.Payload.Templates[0].Solution == "" {
+		t.Errorf("payload after legacy edit = %+v", saved.Payload)
+	}
+}
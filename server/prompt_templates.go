@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PromptTemplateRequest struct {
+	Name         string              `json:"name" binding:"required"`
+	Description  string              `json:"description"`
+	QuestionType models.QuestionType `json:"question_type"`
+	Content      string              `json:"content" binding:"required"`
+	Comment      string              `json:"comment"` // 本次修改的版本说明
+}
+
+// PromptRef 生成题目所用的提示词模板及版本
+type PromptRef struct {
+	TemplateID uint `json:"template_id"`
+	Version    int  `json:"template_version"`
+}
+
+// promptTemplateData 模板中可以使用的变量
+type promptTemplateData struct {
+	Count      int
+	Type       string
+	Difficulty string
+	Language   string
+	Topic      string
+	Schema     string // 单道题目的 JSON Schema
+	// Requirements 内置提示词中该题型的字段要求
+	Requirements string
+}
+
+// renderPromptTemplate 用生成请求渲染模板，引用不存在的变量会报错
+func renderPromptTemplate(content string, req AIGenerateRequest) (string, error) {
+	tpl, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("模板语法错误: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, promptTemplateData{
+		Count:        req.Count,
+		Type:         string(req.Type),
+		Difficulty:   string(req.Difficulty),
+		Language:     req.Language,
+		Topic:        req.Topic,
+		Schema:       describeSchemaForPrompt(req),
+		Requirements: typePromptRequirements(req.Type),
+	}); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// structuredOutputHint 开启结构化输出时要求模型返回 {"questions": [...]}
+func structuredOutputHint() string {
+	if aiConfig.StructuredOutput == StructuredOutputNone {
+		return ""
+	}
+	return "\n\n请返回一个JSON对象，格式为 {\"questions\": [题目数组]}"
+}
+
+// resolvePrompt 确定本次生成使用的提示词。
+// 优先使用请求指定的模板（可指定版本），其次是该题型的默认模板、通用默认模板，都没有时使用内置提示词。
+func resolvePrompt(req AIGenerateRequest) (string, *PromptRef, error) {
+	var tpl models.PromptTemplate
+	if req.TemplateID != nil {
+		if err := db.First(&tpl, *req.TemplateID).Error; err != nil {
+			return "", nil, fmt.Errorf("提示词模板不存在: %d", *req.TemplateID)
+		}
+		if tpl.QuestionType != "" && tpl.QuestionType != req.Type {
+			return "", nil, fmt.Errorf("提示词模板 %d 只适用于 %s 题型", tpl.ID, tpl.QuestionType)
+		}
+	} else if err := db.Where("is_default = ? AND question_type IN ?", true, []string{string(req.Type), ""}).
+		Order("question_type DESC").First(&tpl).Error; err != nil {
+		return buildAIPrompt(req), nil, nil
+	}
+
+	content, version := tpl.Content, tpl.Version
+	if req.TemplateVersion > 0 && req.TemplateVersion != tpl.Version {
+		var v models.PromptTemplateVersion
+		if err := db.Where("template_id = ? AND version = ?", tpl.ID, req.TemplateVersion).First(&v).Error; err != nil {
+			return "", nil, fmt.Errorf("提示词模板 %d 没有版本 %d", tpl.ID, req.TemplateVersion)
+		}
+		content, version = v.Content, v.Version
+	}
+
+	prompt, err := renderPromptTemplate(content, req)
+	if err != nil {
+		return "", nil, err
+	}
+	return prompt + structuredOutputHint(), &PromptRef{TemplateID: tpl.ID, Version: version}, nil
+}
+
+// setPromptRef 在生成的题目上记录所用模板，便于追溯和对比不同模板的效果
+func setPromptRef(questions []GeneratedQuestion, ref *PromptRef) {
+	if ref == nil {
+		return
+	}
+	for i := range questions {
+		id := ref.TemplateID
+		questions[i].TemplateID = &id
+		questions[i].TemplateVersion = ref.Version
+	}
+}
+
+// validatePromptTemplate 用示例参数试渲染，提前发现语法和变量错误
+func validatePromptTemplate(req PromptTemplateRequest) error {
+	sample := AIGenerateRequest{
+		Type:       req.QuestionType,
+		Count:      5,
+		Difficulty: models.Medium,
+		Language:   "Go",
+		Topic:      "示例主题",
+	}
+	if sample.Type == "" {
+		sample.Type = models.SingleChoice
+	} else if questionItemSchema(sample.Type) == nil {
+		return fmt.Errorf("不支持的题目类型: %s", req.QuestionType)
+	}
+	_, err := renderPromptTemplate(req.Content, sample)
+	return err
+}
+
+// 7.1 模板列表
+func getPromptTemplates(c *gin.Context) {
+	query := db.Model(&models.PromptTemplate{})
+	if questionType := c.Query("question_type"); questionType != "" {
+		query = query.Where("question_type = ?", questionType)
+	}
+
+	var templates []models.PromptTemplate
+	if err := query.Order("id").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// 7.2 模板详情
+func getPromptTemplate(c *gin.Context) {
+	var tpl models.PromptTemplate
+	if err := db.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tpl})
+}
+
+// 7.3 新建模板
+func createPromptTemplate(c *gin.Context) {
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := validatePromptTemplate(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tpl := models.PromptTemplate{
+		Name:         req.Name,
+		Description:  req.Description,
+		QuestionType: req.QuestionType,
+		Version:      1,
+		Content:      req.Content,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tpl).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PromptTemplateVersion{
+			TemplateID: tpl.ID,
+			Version:    1,
+			Content:    req.Content,
+			Comment:    req.Comment,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tpl})
+}
+
+// 7.4 修改模板，内容变化时生成新版本；修改默认模板的题型时取消默认
+func updatePromptTemplate(c *gin.Context) {
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := validatePromptTemplate(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tpl models.PromptTemplate
+	if err := db.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	contentChanged := tpl.Content != req.Content
+	if tpl.QuestionType != req.QuestionType {
+		// 默认模板按题型区分，改为其他题型后不再是默认模板，避免同一题型出现两个默认模板
+		tpl.IsDefault = false
+	}
+	tpl.Name = req.Name
+	tpl.Description = req.Description
+	tpl.QuestionType = req.QuestionType
+	if contentChanged {
+		tpl.Version++
+		tpl.Content = req.Content
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&tpl).Error; err != nil {
+			return err
+		}
+		if !contentChanged {
+			return nil
+		}
+		return tx.Create(&models.PromptTemplateVersion{
+			TemplateID: tpl.ID,
+			Version:    tpl.Version,
+			Content:    req.Content,
+			Comment:    req.Comment,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tpl})
+}
+
+// 7.5 删除模板，历史版本保留用于追溯
+func deletePromptTemplate(c *gin.Context) {
+	if err := db.Delete(&models.PromptTemplate{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// 7.6 版本历史
+func getPromptTemplateVersions(c *gin.Context) {
+	var tpl models.PromptTemplate
+	if err := db.Unscoped().First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	var versions []models.PromptTemplateVersion
+	if err := db.Where("template_id = ?", tpl.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// 7.7 设为默认模板，同一题型只有一个默认模板
+func setDefaultPromptTemplate(c *gin.Context) {
+	var tpl models.PromptTemplate
+	if err := db.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PromptTemplate{}).
+			Where("question_type = ? AND id <> ?", tpl.QuestionType, tpl.ID).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&tpl).Update("is_default", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tpl})
+}
+
+// 7.8 预览模板渲染结果
+func previewPromptTemplate(c *gin.Context) {
+	var req AIGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.TemplateID = &id
+
+	prompt, ref, err := resolvePrompt(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"prompt": prompt, "template": ref}})
+}
+
+// parseUintParam 读取路径中的数字参数
+func parseUintParam(c *gin.Context, name string) (uint, error) {
+	var id uint
+	if _, err := fmt.Sscanf(c.Param(name), "%d", &id); err != nil || id == 0 {
+		return 0, errors.New("无效的ID: " + c.Param(name))
+	}
+	return id, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type templateResponse struct {
+	Data  models.PromptTemplate `json:"data"`
+	Error string                `json:"error"`
+}
+
+// resetTemplates 清空提示词模板和历史版本
+func resetTemplates(t *testing.T) {
+	t.Helper()
+	for _, table := range []string{"prompt_templates", "prompt_template_versions"} {
+		if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func createTemplate(t *testing.T, name string, questionType models.QuestionType, content string) models.PromptTemplate {
+	t.Helper()
+	var resp templateResponse
+	body := gin.H{"name": name, "question_type": questionType, "content": content, "comment": "初始版本"}
+	if code := apiRequest(t, http.MethodPost, "/api/prompt-templates", "", body, &resp); code != http.StatusCreated {
+		t.Fatalf("create template %s: status = %d (%s)", name, code, resp.Error)
+	}
+	return resp.Data
+}
+
+func TestPromptTemplateVersions(t *testing.T) {
+	resetTemplates(t)
+	tpl := createTemplate(t, "单选题", models.SingleChoice, "出{{.Count}}道{{.Language}}单选题")
+	path := fmt.Sprintf("/api/prompt-templates/%d", tpl.ID)
+	if tpl.Version != 1 {
+		t.Errorf("new template version = %d, want 1", tpl.Version)
+	}
+
+	// 语法错误和不存在的变量在保存时报错
+	for _, content := range []string{"{{.Count", "{{.Unknown}}"} {
+		body := gin.H{"name": "坏模板", "content": content}
+		if code := apiRequest(t, http.MethodPost, "/api/prompt-templates", "", body, nil); code != http.StatusBadRequest {
+			t.Errorf("content %q: status = %d, want 400", content, code)
+		}
+	}
+
+	var resp templateResponse
+	body := gin.H{"name": "单选题（新）", "question_type": "single_choice", "content": "请出{{.Count}}道{{.Language}}单选题", "comment": "调整措辞"}
+	apiRequest(t, http.MethodPut, path, "", body, &resp)
+	if resp.Data.Version != 2 || resp.Data.Name != "单选题（新）" {
+		t.Errorf("after content change: version = %d, name = %q", resp.Data.Version, resp.Data.Name)
+	}
+	// 只改名称不产生新版本
+	body["name"] = "单选题"
+	apiRequest(t, http.MethodPut, path, "", body, &resp)
+	if resp.Data.Version != 2 {
+		t.Errorf("after rename: version = %d, want 2", resp.Data.Version)
+	}
+
+	var versions struct {
+		Data []models.PromptTemplateVersion `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, path+"/versions", "", nil, &versions)
+	if len(versions.Data) != 2 || versions.Data[0].Version != 2 || versions.Data[0].Comment != "调整措辞" {
+		t.Fatalf("versions = %+v", versions.Data)
+	}
+
+	// 预览可以指定历史版本
+	var preview struct {
+		Data struct {
+			Prompt   string    `json:"prompt"`
+			Template PromptRef `json:"template"`
+		} `json:"data"`
+		Error string `json:"error"`
+	}
+	req := gin.H{"type": "single_choice", "count": 3, "difficulty": "easy", "language": "Go", "template_version": 1}
+	if code := apiRequest(t, http.MethodPost, path+"/preview", "", req, &preview); code != http.StatusOK {
+		t.Fatalf("preview: status = %d (%s)", code, preview.Error)
+	}
+	if !strings.HasPrefix(preview.Data.Prompt, "出3道Go单选题") || preview.Data.Template.Version != 1 {
+		t.Errorf("preview = %q, version %d", preview.Data.Prompt, preview.Data.Template.Version)
+	}
+	req["template_version"] = 9
+	if code := apiRequest(t, http.MethodPost, path+"/preview", "", req, nil); code != http.StatusBadRequest {
+		t.Errorf("preview missing version: status = %d, want 400", code)
+	}
+	// 模板只适用于指定的题型
+	req["template_version"] = 0
+	req["type"] = "multiple_choice"
+	if code := apiRequest(t, http.MethodPost, path+"/preview", "", req, nil); code != http.StatusBadRequest {
+		t.Errorf("preview other type: status = %d, want 400", code)
+	}
+
+	// 删除后历史版本仍可查询
+	apiRequest(t, http.MethodDelete, path, "", nil, nil)
+	if code := apiRequest(t, http.MethodGet, path, "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("get deleted template: status = %d, want 404", code)
+	}
+	if code := apiRequest(t, http.MethodGet, path+"/versions", "", nil, &versions); code != http.StatusOK || len(versions.Data) != 2 {
+		t.Errorf("versions of deleted template: status = %d, %d versions", code, len(versions.Data))
+	}
+}
+
+func TestDefaultPromptTemplate(t *testing.T) {
+	resetTemplates(t)
+	first := createTemplate(t, "单选一", models.SingleChoice, "单选一：{{.Count}}")
+	second := createTemplate(t, "单选二", models.SingleChoice, "单选二：{{.Count}}")
+	generic := createTemplate(t, "通用", "", "通用：{{.Type}}")
+	setDefault := func(id uint) {
+		t.Helper()
+		if code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/prompt-templates/%d/default", id), "", nil, nil); code != http.StatusOK {
+			t.Fatalf("set default %d: status = %d", id, code)
+		}
+	}
+	resolve := func(questionType models.QuestionType) (string, *PromptRef) {
+		t.Helper()
+		prompt, ref, err := resolvePrompt(AIGenerateRequest{Type: questionType, Count: 2, Difficulty: models.Easy, Language: "Go"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prompt, ref
+	}
+	defaults := func(questionType models.QuestionType) int64 {
+		var n int64
+		db.Model(&models.PromptTemplate{}).Where("is_default = ? AND question_type = ?", true, questionType).Count(&n)
+		return n
+	}
+
+	// 没有默认模板时使用内置提示词
+	if _, ref := resolve(models.SingleChoice); ref != nil {
+		t.Errorf("no default: template = %+v, want built-in prompt", ref)
+	}
+
+	setDefault(first.ID)
+	setDefault(second.ID)
+	if n := defaults(models.SingleChoice); n != 1 {
+		t.Errorf("single choice defaults = %d, want 1", n)
+	}
+	if prompt, _ := resolve(models.SingleChoice); !strings.HasPrefix(prompt, "单选二：2") {
+		t.Errorf("single choice prompt = %q", prompt)
+	}
+
+	// 题型专用的默认模板优先于通用默认模板
+	setDefault(generic.ID)
+	if prompt, _ := resolve(models.SingleChoice); !strings.HasPrefix(prompt, "单选二") {
+		t.Errorf("single choice prompt with generic default = %q", prompt)
+	}
+	if prompt, _ := resolve(models.TrueFalse); !strings.HasPrefix(prompt, "通用：true_false") {
+		t.Errorf("true false prompt = %q", prompt)
+	}
+
+	// 默认模板改为其他题型后取消默认，不会出现两个默认模板
+	setDefault(first.ID)
+	var resp templateResponse
+	body := gin.H{"name": "单选二", "question_type": "single_choice", "content": "单选二：{{.Count}}"}
+	apiRequest(t, http.MethodPut, fmt.Sprintf("/api/prompt-templates/%d", first.ID), "", gin.H{"name": "改为多选", "question_type": "multiple_choice", "content": "多选：{{.Count}}"}, &resp)
+	if resp.Data.IsDefault {
+		t.Error("template moved to another type is still default")
+	}
+	if n := defaults(models.SingleChoice); n != 0 {
+		t.Errorf("single choice defaults = %d, want 0", n)
+	}
+	if n := defaults(models.MultipleChoice); n != 0 {
+		t.Errorf("multiple choice defaults = %d, want 0", n)
+	}
+	// 题型不变时保留默认
+	setDefault(second.ID)
+	apiRequest(t, http.MethodPut, fmt.Sprintf("/api/prompt-templates/%d", second.ID), "", body, &resp)
+	if !resp.Data.IsDefault {
+		t.Error("editing a default template without changing its type cleared the default")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"homework-server/models"
+)
+
+// ResponseSchema 发送给支持结构化输出的提供方的 JSON Schema
+type ResponseSchema struct {
+	Name   string
+	Schema map[string]interface{}
+}
+
+// InvalidQuestion 未通过 schema 校验的题目，保留模型原始输出
+type InvalidQuestion struct {
+	Index  int                    `json:"index"`
+	Item   map[string]interface{} `json:"item"`
+	Errors []FieldError           `json:"errors"`
+}
+
+// questionItemSchema 返回单道题目的 JSON Schema，题型不受支持时返回 nil
+func questionItemSchema(t models.QuestionType) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":       map[string]interface{}{"type": "string", "enum": []string{string(t)}},
+		"content":    map[string]interface{}{"type": "string", "minLength": 1},
+		"difficulty": map[string]interface{}{"type": "string", "enum": []string{string(models.Easy), string(models.Medium), string(models.Hard)}},
+		"language":   map[string]interface{}{"type": "string"},
+	}
+	required := []string{"type", "content"}
+
+	spec := lookupQuestionType(t)
+	if spec == nil {
+		return nil
+	}
+	for name, schema := range spec.Schema {
+		properties[name] = schema
+	}
+	required = append(required, spec.Required...)
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// requestItemSchema 返回生成请求中单道题目的 JSON Schema；
+// 请求指定了选项 ID 时（生成变体），选择题的选项和答案按这些 ID 校验
+func requestItemSchema(req AIGenerateRequest) map[string]interface{} {
+	item := questionItemSchema(req.Type)
+	if item == nil || len(req.OptionIDs) == 0 || !isChoiceQuestion(req.Type) {
+		return item
+	}
+	properties := item["properties"].(map[string]interface{})
+	for name, schema := range choiceSchema(req.OptionIDs, req.Type == models.MultipleChoice) {
+		properties[name] = schema
+	}
+	return item
+}
+
+// questionResponseSchema 返回一次生成请求的完整输出 schema：{"questions": [...]}
+func questionResponseSchema(req AIGenerateRequest) *ResponseSchema {
+	item := requestItemSchema(req)
+	if item == nil {
+		return nil
+	}
+	return &ResponseSchema{
+		Name: "generated_questions",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"questions": map[string]interface{}{
+					"type":     "array",
+					"items":    item,
+					"minItems": req.Count,
+					"maxItems": req.Count,
+				},
+			},
+			"required": []string{"questions"},
+		},
+	}
+}
+
+// canonicalizeQuestionItem 只做不改变含义的格式整理：答案数组拼接为逗号分隔，选择题答案去空格，不是选项 ID 的转大写
+func canonicalizeQuestionItem(item map[string]interface{}) {
+	switch answer := item["answer"].(type) {
+	case []interface{}:
+		parts := make([]string, 0, len(answer))
+		for _, a := range answer {
+			parts = append(parts, fmt.Sprintf("%v", a))
+		}
+		item["answer"] = strings.Join(parts, ",")
+	}
+
+	t, _ := item["type"].(string)
+	if isChoiceQuestion(models.QuestionType(t)) {
+		if answer, ok := item["answer"].(string); ok {
+			options, _ := item["options"].(map[string]interface{})
+			item["answer"] = canonicalChoiceAnswer(answer, options)
+		}
+	}
+}
+
+// canonicalChoiceAnswer 选择题答案去空格，与选项 ID 完全一致的部分保持原样，其余转大写，
+// opt1 这样的选项 ID 不受影响
+func canonicalChoiceAnswer(answer string, options map[string]interface{}) string {
+	parts := strings.Split(strings.ReplaceAll(answer, " ", ""), ",")
+	for i, part := range parts {
+		if _, ok := options[part]; !ok {
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// validateQuestionItem 按请求题型的 schema 校验单道题目
+func validateQuestionItem(item map[string]interface{}, req AIGenerateRequest) []FieldError {
+	schema := requestItemSchema(req)
+	if schema == nil {
+		return []FieldError{{Field: "type", Message: "不支持的题目类型"}}
+	}
+	return validateJSONSchema(schema, item, "")
+}
+
+// decodeQuestionItems 从模型输出中取出题目对象列表。
+// 先按严格JSON解析，失败后再用宽松语法；strict 表示内容本身就是合法JSON。
+// 支持三种外层结构：题目数组、{"questions": [...]}、单个题目对象。
+func decodeQuestionItems(content string) (items []interface{}, strict bool, err error) {
+	var root interface{}
+	if jsonErr := json.Unmarshal([]byte(extractJSONContent(content)), &root); jsonErr == nil {
+		strict = true
+	} else if jsonErr := json.Unmarshal([]byte(cleanJSONContent(content)), &root); jsonErr != nil {
+		if _, err := parseJSON5(extractJSONContent(content)); err != nil {
+			return nil, false, fmt.Errorf("无法解析AI响应: %w", err)
+		}
+		return nil, false, fmt.Errorf("无法解析AI响应: %w", jsonErr)
+	}
+
+	switch v := root.(type) {
+	case []interface{}:
+		return v, strict, nil
+	case map[string]interface{}:
+		if list, ok := v["questions"].([]interface{}); ok {
+			return list, strict, nil
+		}
+		return []interface{}{v}, strict, nil
+	default:
+		return nil, strict, fmt.Errorf("无法解析AI响应: 顶层既不是数组也不是对象")
+	}
+}
+
+// describeSchemaForPrompt 生成提示词中描述输出格式的部分
+func describeSchemaForPrompt(req AIGenerateRequest) string {
+	item := requestItemSchema(req)
+	if item == nil {
+		return ""
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
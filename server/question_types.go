@@ -0,0 +1,904 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionTypeSpec 一种题型的定义。新增题型只需要在 questionTypeSpecs 中注册，
+// 题目的校验、旧格式字段、AI出题的提示词和输出 schema、自动判分都从这里读取
+type QuestionTypeSpec struct {
+	Type  models.QuestionType
+	Label string
+	// Aliases validateAndConvertType 识别的其他写法，均为小写
+	Aliases []string
+	// LegacyInput 是否接受旧格式的 options、answer，不接受时必须提交 payload
+	LegacyInput bool
+	// Fields 该题型使用的 payload 字段，其他字段不能设置
+	Fields []string
+	// Schema AI输出中该题型特有字段的 JSON Schema，Required 为其中的必填字段
+	Schema   map[string]interface{}
+	Required []string
+	// Example 内置提示词的示例题目中该题型特有的字段
+	Example map[string]interface{}
+	// Prompt 内置提示词中对该题型的要求
+	Prompt []string
+	// ExplainHint 生成解析时对该题型的额外要求
+	ExplainHint string
+	// Validate 校验结构化内容，字段名相对于 payload，题目内容本身的错误字段名为 content
+	Validate func(content string, p models.QuestionPayload) []FieldError
+	// Legacy 由结构化内容生成旧格式的 options、answer，供按旧格式读取题目的接口使用
+	Legacy func(p models.QuestionPayload) (models.JSON, string)
+	// Grade 按 payload 中的答案给作答判分，不支持自动判分的题型为 nil
+	Grade func(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error)
+	// Mock mock 提供方生成的第 i 道题目中该题型特有的字段
+	Mock func(i int) map[string]interface{}
+}
+
+// GradeResult 自动判分结果，Score 在 0 到 1 之间；Details 为每个空位、配对的对错
+type GradeResult struct {
+	Correct bool            `json:"correct"`
+	Score   float64         `json:"score"`
+	Details map[string]bool `json:"details,omitempty"`
+}
+
+// GradeRequest 判分请求，Response 的格式由题型决定
+type GradeRequest struct {
+	Response json.RawMessage `json:"response" binding:"required"`
+}
+
+// 各题型条目数量的限制
+const (
+	maxClozeBlanks   = 20
+	minMatchPairs    = 2
+	maxMatchPairs    = 20
+	minOrderingItems = 2
+	maxOrderingItems = 20
+)
+
+var clozeMarkerRe = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)\s*\}\}`)
+
+// questionTypeSpecs 按 validateAndConvertType 的匹配顺序排列
+var questionTypeSpecs = []*QuestionTypeSpec{
+	{
+		Type:        models.SingleChoice,
+		Label:       "单选题",
+		Aliases:     []string{"single"},
+		LegacyInput: true,
+		Fields:      []string{"options", "answers"},
+		Schema:      choiceSchema(defaultChoiceOptionIDs, false),
+		Required:    []string{"options", "answer"},
+		Example:     choiceExample("A"),
+		Prompt: []string{
+			"options字段必须是一个JSON对象，包含A、B、C、D四个选项",
+			`answer字段为唯一正确选项的字母，如"A"`,
+		},
+		ExplainHint: "逐一说明其余每个选项为什么错误；",
+		Validate: func(content string, p models.QuestionPayload) []FieldError {
+			return validateChoicePayload(p, false)
+		},
+		Legacy: choiceLegacy,
+		Grade: func(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+			return gradeChoice(p, response)
+		},
+		Mock: func(i int) map[string]interface{} { return mockChoice(i, "A") },
+	},
+	{
+		Type:        models.MultipleChoice,
+		Label:       "多选题",
+		Aliases:     []string{"multiple"},
+		LegacyInput: true,
+		Fields:      []string{"options", "answers"},
+		Schema:      choiceSchema(defaultChoiceOptionIDs, true),
+		Required:    []string{"options", "answer"},
+		Example:     choiceExample("A,B"),
+		Prompt: []string{
+			"options字段必须是一个JSON对象，包含A、B、C、D四个选项",
+			`answer字段为所有正确选项的字母，如"A,B"（用逗号分隔，不要有空格）`,
+		},
+		ExplainHint: "逐一说明其余每个选项为什么错误；",
+		Validate: func(content string, p models.QuestionPayload) []FieldError {
+			return validateChoicePayload(p, true)
+		},
+		Legacy: choiceLegacy,
+		Grade: func(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+			return gradeChoice(p, response)
+		},
+		Mock: func(i int) map[string]interface{} { return mockChoice(i, "A,B") },
+	},
+	{
+		Type:        models.Programming,
+		Label:       "编程题",
+		Aliases:     []string{"programming"},
+		LegacyInput: true,
+		Fields:      []string{"templates", "test_cases", "limits"},
+		Schema: map[string]interface{}{
+			"options": map[string]interface{}{"type": []string{"string", "null"}, "maxLength": 0},
+			"answer":  map[string]interface{}{"type": []string{"string", "null"}},
+			"payload": programmingSchema(),
+		},
+		Example: map[string]interface{}{"options": "", "answer": "", "payload": programmingExample()},
+		Prompt: []string{
+			"options字段请返回空字符串",
+			`answer字段请返回空字符串""`,
+			"payload.templates 给出题目所用编程语言的起始代码 starter 和参考答案 solution，起始代码只包含函数签名或输入输出框架",
+			`payload.test_cases 给出至少3个测试用例：按标准输入输出判题时 kind 为 "stdio"，填写 input 和 output；按函数调用判题时 kind 为 "function"，填写 function、args 和 expected`,
+			"其中至少一个是公开用例，边界情况的用例设置 hidden 为 true",
+			"payload.limits 给出时间限制 time_ms（毫秒）和内存限制 memory_mb（MB）",
+		},
+		ExplainHint: "说明算法思路、关键代码和时间复杂度；",
+		Validate:    validateProgrammingPayload,
+		Legacy:      func(p models.QuestionPayload) (models.JSON, string) { return nil, "" },
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{"options": "", "answer": "", "payload": map[string]interface{}{
+				"templates": []interface{}{map[string]interface{}{
+					"language": "Go",
+					"starter":  "func Add(a, b int) int {\n\t// TODO\n}",
+					"solution": "func Add(a, b int) int {\n\treturn a + b\n}",
+				}},
+				"test_cases": []interface{}{
+					map[string]interface{}{"kind": "function", "function": "Add", "args": []int{1, i}, "expected": 1 + i},
+					map[string]interface{}{"kind": "function", "function": "Add", "args": []int{-i, i}, "expected": 0, "hidden": true},
+				},
+				"limits": map[string]interface{}{"time_ms": defaultTimeLimitMs, "memory_mb": defaultMemoryLimitMB},
+			}}
+		},
+	},
+	{
+		Type:     models.TrueFalse,
+		Label:    "判断题",
+		Aliases:  []string{"true_false", "truefalse", "true/false", "judge"},
+		Fields:   []string{"correct"},
+		Schema:   payloadSchema(map[string]interface{}{"correct": map[string]interface{}{"type": "boolean"}}, "correct"),
+		Required: []string{"payload"},
+		Example:  map[string]interface{}{"payload": map[string]interface{}{"correct": true}},
+		Prompt: []string{
+			"content字段为一个陈述，学生判断它是否正确",
+			`payload字段为 {"correct": true} 或 {"correct": false}，表示该陈述是否正确，正确和错误的陈述都要有`,
+		},
+		ExplainHint: "说明该陈述正确或错误的依据；",
+		Validate: func(content string, p models.QuestionPayload) []FieldError {
+			if p.Correct == nil {
+				return []FieldError{{Field: "correct", Message: "请指定判断题的正确答案 true 或 false"}}
+			}
+			return nil
+		},
+		Legacy: func(p models.QuestionPayload) (models.JSON, string) {
+			if p.Correct == nil {
+				return nil, ""
+			}
+			return nil, strconv.FormatBool(*p.Correct)
+		},
+		Grade: func(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+			var answer bool
+			if err := decodeResponse(response, &answer); err != nil {
+				return nil, errors.New("作答格式错误，判断题应为 true 或 false")
+			}
+			return scoreResult(answer == *p.Correct), nil
+		},
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{"payload": map[string]interface{}{"correct": i%2 == 1}}
+		},
+	},
+	{
+		Type:    models.Cloze,
+		Label:   "填空题",
+		Aliases: []string{"cloze", "fill"},
+		Fields:  []string{"blanks"},
+		Schema: payloadSchema(map[string]interface{}{
+			"blanks": map[string]interface{}{
+				"type":     "array",
+				"minItems": 1,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id":      map[string]interface{}{"type": "string", "minLength": 1},
+						"answers": map[string]interface{}{"type": "array", "minItems": 1, "items": map[string]interface{}{"type": "string", "minLength": 1}},
+					},
+					"required": []string{"id", "answers"},
+				},
+			},
+		}, "blanks"),
+		Required: []string{"payload"},
+		Example: map[string]interface{}{"payload": map[string]interface{}{
+			"blanks": []interface{}{map[string]interface{}{"id": "1", "answers": []string{"答案", "其他可接受的写法"}}},
+		}},
+		Prompt: []string{
+			"content字段中用 {{1}}、{{2}} 这样的标记表示每个空位，一道题可以有多个空位",
+			`payload字段为 {"blanks": [{"id": "1", "answers": ["答案"]}]}，id 与 content 中的标记对应，answers 列出该空位所有可接受的写法`,
+		},
+		ExplainHint: "说明每个空位应填的内容及原因；",
+		Validate:    validateClozePayload,
+		Legacy: func(p models.QuestionPayload) (models.JSON, string) {
+			parts := make([]string, 0, len(p.Blanks))
+			for _, b := range p.Blanks {
+				if len(b.Answers) > 0 {
+					parts = append(parts, b.ID+": "+b.Answers[0])
+				}
+			}
+			return nil, strings.Join(parts, "; ")
+		},
+		Grade: gradeCloze,
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{
+				"content": fmt.Sprintf("[mock] 第%d题：Go 中声明变量使用 {{1}} 关键字，声明常量使用 {{2}} 关键字", i),
+				"payload": map[string]interface{}{"blanks": []interface{}{
+					map[string]interface{}{"id": "1", "answers": []string{"var"}},
+					map[string]interface{}{"id": "2", "answers": []string{"const"}},
+				}},
+			}
+		},
+	},
+	{
+		Type:    models.ShortAnswer,
+		Label:   "简答题",
+		Aliases: []string{"short"},
+		Fields:  []string{"accepted_answers", "patterns"},
+		Schema: payloadSchema(map[string]interface{}{
+			"accepted_answers": map[string]interface{}{"type": "array", "minItems": 1, "items": map[string]interface{}{"type": "string", "minLength": 1}},
+			"patterns":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "minLength": 1}},
+		}, "accepted_answers"),
+		Required: []string{"payload"},
+		Example: map[string]interface{}{"payload": map[string]interface{}{
+			"accepted_answers": []string{"标准答案"},
+			"patterns":         []string{"^可选的正则表达式$"},
+		}},
+		Prompt: []string{
+			"题目的答案应当简短明确，可以自动比对",
+			`payload字段为 {"accepted_answers": ["标准答案"], "patterns": []}，accepted_answers 列出所有可接受的写法，patterns 为可选的正则表达式`,
+		},
+		ExplainHint: "给出答题要点；",
+		Validate:    validateShortAnswerPayload,
+		Legacy: func(p models.QuestionPayload) (models.JSON, string) {
+			if len(p.AcceptedAnswers) > 0 {
+				return nil, p.AcceptedAnswers[0]
+			}
+			if len(p.Patterns) > 0 {
+				return nil, p.Patterns[0]
+			}
+			return nil, ""
+		},
+		Grade: gradeShortAnswer,
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{"payload": map[string]interface{}{
+				"accepted_answers": []string{fmt.Sprintf("答案%d", i)},
+			}}
+		},
+	},
+	{
+		Type:    models.Matching,
+		Label:   "连线题",
+		Aliases: []string{"matching", "配对题"},
+		Fields:  []string{"pairs"},
+		Schema: payloadSchema(map[string]interface{}{
+			"pairs": map[string]interface{}{
+				"type":     "array",
+				"minItems": minMatchPairs,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"left":  map[string]interface{}{"type": "string", "minLength": 1},
+						"right": map[string]interface{}{"type": "string", "minLength": 1},
+					},
+					"required": []string{"left", "right"},
+				},
+			},
+		}, "pairs"),
+		Required: []string{"payload"},
+		Example: map[string]interface{}{"payload": map[string]interface{}{
+			"pairs": []interface{}{map[string]interface{}{"left": "左侧条目", "right": "对应的右侧条目"}},
+		}},
+		Prompt: []string{
+			"content字段说明连线的要求",
+			`payload字段为 {"pairs": [{"left": "左侧条目", "right": "对应的右侧条目"}]}，至少4组，左侧和右侧的条目各自互不相同`,
+		},
+		ExplainHint: "说明每组配对的依据；",
+		Validate:    validateMatchingPayload,
+		Legacy: func(p models.QuestionPayload) (models.JSON, string) {
+			parts := make([]string, 0, len(p.Pairs))
+			for _, pair := range p.Pairs {
+				parts = append(parts, pair.Left+" → "+pair.Right)
+			}
+			return nil, strings.Join(parts, "; ")
+		},
+		Grade: gradeMatching,
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{"payload": map[string]interface{}{"pairs": []interface{}{
+				map[string]interface{}{"left": "int", "right": fmt.Sprintf("整数-%d", i)},
+				map[string]interface{}{"left": "string", "right": fmt.Sprintf("字符串-%d", i)},
+			}}}
+		},
+	},
+	{
+		Type:    models.Ordering,
+		Label:   "排序题",
+		Aliases: []string{"ordering", "order"},
+		Fields:  []string{"items"},
+		Schema: payloadSchema(map[string]interface{}{
+			"items": map[string]interface{}{
+				"type":     "array",
+				"minItems": minOrderingItems,
+				"items": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string", "minLength": 1}},
+					"required":   []string{"text"},
+				},
+			},
+		}, "items"),
+		Required: []string{"payload"},
+		Example: map[string]interface{}{"payload": map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"text": "第一步"}, map[string]interface{}{"text": "第二步"}},
+		}},
+		Prompt: []string{
+			"content字段说明排序的要求",
+			`payload字段为 {"items": [{"text": "条目"}]}，items 按正确顺序排列，至少4项，条目互不相同`,
+		},
+		ExplainHint: "说明正确顺序的依据；",
+		Validate: func(content string, p models.QuestionPayload) []FieldError {
+			return validateOptionList("items", "条目", p.Items, minOrderingItems, maxOrderingItems)
+		},
+		Legacy: func(p models.QuestionPayload) (models.JSON, string) {
+			ids := make([]string, len(p.Items))
+			for i, item := range p.Items {
+				ids[i] = item.ID
+			}
+			return optionsJSON(p.Items), strings.Join(ids, ",")
+		},
+		Grade: gradeOrdering,
+		Mock: func(i int) map[string]interface{} {
+			return map[string]interface{}{"payload": map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"text": fmt.Sprintf("声明变量-%d", i)},
+				map[string]interface{}{"text": fmt.Sprintf("赋值-%d", i)},
+				map[string]interface{}{"text": fmt.Sprintf("使用变量-%d", i)},
+			}}}
+		},
+	},
+}
+
+var questionTypeIndex = func() map[models.QuestionType]*QuestionTypeSpec {
+	index := make(map[models.QuestionType]*QuestionTypeSpec, len(questionTypeSpecs))
+	for _, spec := range questionTypeSpecs {
+		index[spec.Type] = spec
+	}
+	return index
+}()
+
+// lookupQuestionType 返回题型定义，不支持的题型返回 nil
+func lookupQuestionType(t models.QuestionType) *QuestionTypeSpec {
+	return questionTypeIndex[t]
+}
+
+// ---------------- schema 和示例 ----------------
+
+// defaultChoiceOptionIDs 生成新题目时要求的选项 ID
+var defaultChoiceOptionIDs = []string{"A", "B", "C", "D"}
+
+// choiceSchema 选择题的 options、answer 字段：options 必须恰好包含 ids 中的选项，
+// 答案只能是这些选项，多选题用逗号分隔
+func choiceSchema(ids []string, multiple bool) map[string]interface{} {
+	optionProperties := map[string]interface{}{}
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		optionProperties[id] = map[string]interface{}{"type": "string", "minLength": 1}
+		quoted[i] = regexp.QuoteMeta(id)
+	}
+	answer := map[string]interface{}{"type": "string", "enum": ids}
+	if multiple {
+		one := "(" + strings.Join(quoted, "|") + ")"
+		answer = map[string]interface{}{"type": "string", "pattern": "^" + one + "(," + one + ")*$"}
+	}
+	return map[string]interface{}{
+		"options": map[string]interface{}{
+			"type":                 "object",
+			"properties":           optionProperties,
+			"required":             ids,
+			"additionalProperties": false,
+		},
+		"answer": answer,
+	}
+}
+
+func choiceExample(answer string) map[string]interface{} {
+	return map[string]interface{}{
+		"options": map[string]string{"A": "选项A内容", "B": "选项B内容", "C": "选项C内容", "D": "选项D内容"},
+		"answer":  answer,
+	}
+}
+
+// payloadSchema 非选择题的特有字段都放在 payload 对象中
+func payloadSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"payload": map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+	}
+}
+
+// programmingSchema 编程题 payload 的 schema，测试用例的字段按 kind 在题型校验中检查
+func programmingSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"templates": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"language": map[string]interface{}{"type": "string", "minLength": 1},
+						"starter":  map[string]interface{}{"type": "string"},
+						"solution": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"language"},
+				},
+			},
+			"test_cases": map[string]interface{}{
+				"type":     "array",
+				"maxItems": maxTestCases,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"kind":     map[string]interface{}{"type": "string", "enum": []string{string(models.TestCaseStdio), string(models.TestCaseFunction)}},
+						"input":    map[string]interface{}{"type": "string"},
+						"output":   map[string]interface{}{"type": "string"},
+						"function": map[string]interface{}{"type": "string"},
+						"args":     map[string]interface{}{"type": "array"},
+						"hidden":   map[string]interface{}{"type": "boolean"},
+					},
+					"required": []string{"kind"},
+				},
+			},
+			"limits": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"time_ms":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxTimeLimitMs},
+					"memory_mb": map[string]interface{}{"type": "integer", "minimum": minMemoryLimitMB, "maximum": maxMemoryLimitMB},
+				},
+			},
+		},
+	}
+}
+
+func programmingExample() map[string]interface{} {
+	return map[string]interface{}{
+		"templates": []interface{}{map[string]interface{}{"language": "编程语言", "starter": "起始代码", "solution": "参考答案"}},
+		"test_cases": []interface{}{
+			map[string]interface{}{"kind": "stdio", "input": "标准输入", "output": "期望的标准输出"},
+			map[string]interface{}{"kind": "function", "function": "函数名", "args": []interface{}{"参数"}, "expected": "期望的返回值", "hidden": true},
+		},
+		"limits": map[string]interface{}{"time_ms": defaultTimeLimitMs, "memory_mb": defaultMemoryLimitMB},
+	}
+}
+
+func mockChoice(i int, answer string) map[string]interface{} {
+	return map[string]interface{}{
+		"options": map[string]string{
+			"A": fmt.Sprintf("选项A-%d", i),
+			"B": fmt.Sprintf("选项B-%d", i),
+			"C": fmt.Sprintf("选项C-%d", i),
+			"D": fmt.Sprintf("选项D-%d", i),
+		},
+		"answer": answer,
+	}
+}
+
+// ---------------- 校验 ----------------
+
+// validateChoicePayload 选项数量、ID和内容不能重复，答案必须引用已有的选项
+func validateChoicePayload(p models.QuestionPayload, multiple bool) []FieldError {
+	errs := validateOptionList("options", "选项", p.Options, minChoiceOptions, maxChoiceOptions)
+	if len(p.Answers) == 0 {
+		errs = append(errs, FieldError{Field: "answers", Message: "请指定正确答案"})
+	} else if !multiple && len(p.Answers) > 1 {
+		errs = append(errs, FieldError{Field: "answers", Message: "单选题只能有一个正确答案"})
+	}
+	ids := make(map[string]bool, len(p.Options))
+	for _, o := range p.Options {
+		ids[o.ID] = true
+	}
+	seen := make(map[string]bool)
+	for i, a := range p.Answers {
+		field := fmt.Sprintf("answers[%d]", i)
+		if !ids[a] {
+			errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf("答案 %s 不是已有的选项", a)})
+		} else if seen[a] {
+			errs = append(errs, FieldError{Field: field, Message: "答案重复: " + a})
+		}
+		seen[a] = true
+	}
+	return errs
+}
+
+// validateOptionList 校验选项或排序条目：数量在范围内，ID合法且不重复，内容不为空且不重复
+func validateOptionList(field, label string, options []models.ChoiceOption, min, max int) []FieldError {
+	var errs []FieldError
+	if len(options) < min || len(options) > max {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf("需要%d到%d个%s，当前%d个", min, max, label, len(options))})
+	}
+	ids := make(map[string]bool)
+	texts := make(map[string]bool)
+	for i, o := range options {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		if !optionIDRe.MatchString(o.ID) {
+			errs = append(errs, FieldError{Field: f + ".id", Message: "ID只能包含字母、数字、下划线和连字符，长度不超过36"})
+		} else if ids[o.ID] {
+			errs = append(errs, FieldError{Field: f + ".id", Message: "ID重复: " + o.ID})
+		}
+		ids[o.ID] = true
+		if o.Text == "" {
+			errs = append(errs, FieldError{Field: f + ".text", Message: label + "内容不能为空"})
+		} else if texts[o.Text] {
+			errs = append(errs, FieldError{Field: f + ".text", Message: label + "内容重复: " + o.Text})
+		}
+		texts[o.Text] = true
+	}
+	return errs
+}
+
+// validateClozePayload 每个空位都要有答案，并且与题目内容中的 {{ID}} 标记一一对应
+func validateClozePayload(content string, p models.QuestionPayload) []FieldError {
+	var errs []FieldError
+	if len(p.Blanks) == 0 || len(p.Blanks) > maxClozeBlanks {
+		errs = append(errs, FieldError{Field: "blanks", Message: fmt.Sprintf("需要1到%d个空位，当前%d个", maxClozeBlanks, len(p.Blanks))})
+	}
+
+	markers := make(map[string]int)
+	for _, m := range clozeMarkerRe.FindAllStringSubmatch(content, -1) {
+		markers[m[1]]++
+	}
+	ids := make(map[string]bool)
+	for i, b := range p.Blanks {
+		field := fmt.Sprintf("blanks[%d]", i)
+		switch {
+		case !optionIDRe.MatchString(b.ID):
+			errs = append(errs, FieldError{Field: field + ".id", Message: "ID只能包含字母、数字、下划线和连字符，长度不超过36"})
+		case ids[b.ID]:
+			errs = append(errs, FieldError{Field: field + ".id", Message: "ID重复: " + b.ID})
+		case markers[b.ID] == 0:
+			errs = append(errs, FieldError{Field: field + ".id", Message: fmt.Sprintf("题目内容中没有空位 {{%s}}", b.ID)})
+		case markers[b.ID] > 1:
+			errs = append(errs, FieldError{Field: field + ".id", Message: fmt.Sprintf("题目内容中空位 {{%s}} 出现了多次", b.ID)})
+		}
+		ids[b.ID] = true
+		if len(b.Answers) == 0 {
+			errs = append(errs, FieldError{Field: field + ".answers", Message: "请至少填写一个可接受的答案"})
+		}
+		for j, a := range b.Answers {
+			if a == "" {
+				errs = append(errs, FieldError{Field: fmt.Sprintf("%s.answers[%d]", field, j), Message: "答案不能为空"})
+			}
+		}
+	}
+	for id := range markers {
+		if !ids[id] {
+			errs = append(errs, FieldError{Field: "content", Message: fmt.Sprintf("空位 {{%s}} 没有对应的答案", id)})
+		}
+	}
+	return errs
+}
+
+// validateShortAnswerPayload 至少有一个可接受的答案或正则表达式，正则表达式必须能编译
+func validateShortAnswerPayload(content string, p models.QuestionPayload) []FieldError {
+	var errs []FieldError
+	if len(p.AcceptedAnswers) == 0 && len(p.Patterns) == 0 {
+		errs = append(errs, FieldError{Field: "accepted_answers", Message: "请至少填写一个可接受的答案或正则表达式"})
+	}
+	for i, a := range p.AcceptedAnswers {
+		if a == "" {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("accepted_answers[%d]", i), Message: "答案不能为空"})
+		}
+	}
+	for i, pattern := range p.Patterns {
+		if _, err := regexp.Compile(pattern); pattern == "" || err != nil {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("patterns[%d]", i), Message: "正则表达式无效"})
+		}
+	}
+	return errs
+}
+
+// validateMatchingPayload 配对数量在范围内，左侧和右侧的条目各自不能重复
+func validateMatchingPayload(content string, p models.QuestionPayload) []FieldError {
+	var errs []FieldError
+	if len(p.Pairs) < minMatchPairs || len(p.Pairs) > maxMatchPairs {
+		errs = append(errs, FieldError{Field: "pairs", Message: fmt.Sprintf("需要%d到%d组配对，当前%d组", minMatchPairs, maxMatchPairs, len(p.Pairs))})
+	}
+	ids := make(map[string]bool)
+	lefts := make(map[string]bool)
+	rights := make(map[string]bool)
+	for i, pair := range p.Pairs {
+		field := fmt.Sprintf("pairs[%d]", i)
+		if !optionIDRe.MatchString(pair.ID) {
+			errs = append(errs, FieldError{Field: field + ".id", Message: "ID只能包含字母、数字、下划线和连字符，长度不超过36"})
+		} else if ids[pair.ID] {
+			errs = append(errs, FieldError{Field: field + ".id", Message: "ID重复: " + pair.ID})
+		}
+		ids[pair.ID] = true
+		if pair.Left == "" {
+			errs = append(errs, FieldError{Field: field + ".left", Message: "左侧条目不能为空"})
+		} else if lefts[pair.Left] {
+			errs = append(errs, FieldError{Field: field + ".left", Message: "左侧条目重复: " + pair.Left})
+		}
+		lefts[pair.Left] = true
+		if pair.Right == "" {
+			errs = append(errs, FieldError{Field: field + ".right", Message: "右侧条目不能为空"})
+		} else if rights[pair.Right] {
+			errs = append(errs, FieldError{Field: field + ".right", Message: "右侧条目重复: " + pair.Right})
+		}
+		rights[pair.Right] = true
+	}
+	return errs
+}
+
+// payloadFields payload 的字段及其名称，按 QuestionPayload 中的顺序排列
+var payloadFields = []struct{ Name, Label string }{
+	{"options", "选项"},
+	{"answers", "答案"},
+	{"correct", "判断结果"},
+	{"blanks", "空位"},
+	{"accepted_answers", "可接受的答案"},
+	{"patterns", "正则表达式"},
+	{"pairs", "配对"},
+	{"items", "排序条目"},
+	{"templates", "代码模板"},
+	{"test_cases", "测试用例"},
+	{"limits", "运行限制"},
+}
+
+// payloadFieldSet 判断 payload 的字段是否已设置
+func payloadFieldSet(p models.QuestionPayload, field string) bool {
+	switch field {
+	case "options":
+		return len(p.Options) > 0
+	case "answers":
+		return len(p.Answers) > 0
+	case "correct":
+		return p.Correct != nil
+	case "blanks":
+		return len(p.Blanks) > 0
+	case "accepted_answers":
+		return len(p.AcceptedAnswers) > 0
+	case "patterns":
+		return len(p.Patterns) > 0
+	case "pairs":
+		return len(p.Pairs) > 0
+	case "items":
+		return len(p.Items) > 0
+	case "templates":
+		return len(p.Templates) > 0
+	case "test_cases":
+		return len(p.TestCases) > 0
+	case "limits":
+		return p.Limits != nil
+	}
+	return false
+}
+
+// ---------------- 旧格式字段 ----------------
+
+// choiceLegacy 选项按 ID 生成 options，多个答案按选项顺序用逗号连接
+func choiceLegacy(p models.QuestionPayload) (models.JSON, string) {
+	answers := make(map[string]bool, len(p.Answers))
+	for _, a := range p.Answers {
+		answers[a] = true
+	}
+	var parts []string
+	for _, o := range p.Options {
+		if answers[o.ID] {
+			parts = append(parts, o.ID)
+		}
+	}
+	return optionsJSON(p.Options), strings.Join(parts, ",")
+}
+
+func optionsJSON(options []models.ChoiceOption) models.JSON {
+	if len(options) == 0 {
+		return nil
+	}
+	m := make(models.JSON, len(options))
+	for _, o := range options {
+		m[o.ID] = o.Text
+	}
+	return m
+}
+
+// ---------------- 判分 ----------------
+
+func scoreResult(correct bool) *GradeResult {
+	if correct {
+		return &GradeResult{Correct: true, Score: 1}
+	}
+	return &GradeResult{}
+}
+
+// partialResult 按答对的比例给分，全部答对才算正确
+func partialResult(details map[string]bool) *GradeResult {
+	right := 0
+	for _, ok := range details {
+		if ok {
+			right++
+		}
+	}
+	result := &GradeResult{Details: details, Correct: right == len(details)}
+	if len(details) > 0 {
+		result.Score = float64(right) / float64(len(details))
+	}
+	return result
+}
+
+// normalizeAnswerText 合并空白，不区分大小写时转小写
+func normalizeAnswerText(s string, caseSensitive bool) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if !caseSensitive {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// decodeResponse 解析作答，作答为 null 时返回错误，避免被当作 false 或空答案判分
+func decodeResponse(response json.RawMessage, v interface{}) error {
+	if s := bytes.TrimSpace(response); len(s) == 0 || bytes.Equal(s, []byte("null")) {
+		return errors.New("作答不能为 null")
+	}
+	return json.Unmarshal(response, v)
+}
+
+// gradeChoice 作答为 "A,C" 或 ["A","C"]，选项ID不区分大小写，选中的选项与答案完全一致才得分
+func gradeChoice(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+	var selected []string
+	if err := decodeResponse(response, &selected); err != nil {
+		var s string
+		if err := decodeResponse(response, &s); err != nil {
+			return nil, errors.New(`作答格式错误，选择题应为 "A,C" 或 ["A","C"]`)
+		}
+		selected = strings.Split(s, ",")
+	}
+
+	chosen := make(map[string]bool)
+	for _, s := range selected {
+		for _, o := range p.Options {
+			if strings.EqualFold(o.ID, strings.TrimSpace(s)) {
+				chosen[o.ID] = true
+			}
+		}
+	}
+	if len(chosen) != len(p.Answers) {
+		return scoreResult(false), nil
+	}
+	for _, a := range p.Answers {
+		if !chosen[a] {
+			return scoreResult(false), nil
+		}
+	}
+	return scoreResult(true), nil
+}
+
+// gradeCloze 作答为 {"空位ID": "答案"}，每个空位单独判分
+func gradeCloze(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+	var answers map[string]string
+	if err := decodeResponse(response, &answers); err != nil {
+		return nil, errors.New(`作答格式错误，填空题应为 {"空位ID": "答案"}`)
+	}
+	details := make(map[string]bool, len(p.Blanks))
+	for _, b := range p.Blanks {
+		given := normalizeAnswerText(answers[b.ID], b.CaseSensitive)
+		for _, accepted := range b.Answers {
+			if given != "" && given == normalizeAnswerText(accepted, b.CaseSensitive) {
+				details[b.ID] = true
+				break
+			}
+		}
+		if !details[b.ID] {
+			details[b.ID] = false
+		}
+	}
+	return partialResult(details), nil
+}
+
+// gradeShortAnswer 作答与任一可接受的答案相同，或匹配任一正则表达式即得分
+func gradeShortAnswer(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+	var answer string
+	if err := decodeResponse(response, &answer); err != nil {
+		return nil, errors.New("作答格式错误，简答题应为字符串")
+	}
+	given := normalizeAnswerText(answer, false)
+	if given == "" {
+		return scoreResult(false), nil
+	}
+	for _, accepted := range p.AcceptedAnswers {
+		if given == normalizeAnswerText(accepted, false) {
+			return scoreResult(true), nil
+		}
+	}
+	for _, pattern := range p.Patterns {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(strings.TrimSpace(answer)) {
+			return scoreResult(true), nil
+		}
+	}
+	return scoreResult(false), nil
+}
+
+// gradeMatching 作答为 {"配对ID": "右侧条目"}，每组配对单独判分
+func gradeMatching(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+	var answers map[string]string
+	if err := decodeResponse(response, &answers); err != nil {
+		return nil, errors.New(`作答格式错误，连线题应为 {"配对ID": "右侧条目"}`)
+	}
+	details := make(map[string]bool, len(p.Pairs))
+	for _, pair := range p.Pairs {
+		details[pair.ID] = strings.TrimSpace(answers[pair.ID]) == pair.Right
+	}
+	return partialResult(details), nil
+}
+
+// gradeOrdering 作答为按顺序排列的全部条目ID，按位置正确的比例给分
+func gradeOrdering(p models.QuestionPayload, response json.RawMessage) (*GradeResult, error) {
+	var order []string
+	if err := decodeResponse(response, &order); err != nil {
+		return nil, errors.New(`作答格式错误，排序题应为条目ID数组，如 ["B","A","C"]`)
+	}
+	if len(order) != len(p.Items) {
+		return nil, fmt.Errorf("作答格式错误，排序题需要给出全部%d个条目的顺序，当前%d个", len(p.Items), len(order))
+	}
+	details := make(map[string]bool, len(p.Items))
+	for i, item := range p.Items {
+		details[item.ID] = order[i] == item.ID
+	}
+	return partialResult(details), nil
+}
+
+// 18.1 支持的题型
+func getQuestionTypes(c *gin.Context) {
+	types := make([]gin.H, 0, len(questionTypeSpecs))
+	for _, spec := range questionTypeSpecs {
+		types = append(types, gin.H{
+			"type":       spec.Type,
+			"label":      spec.Label,
+			"fields":     spec.Fields,
+			"auto_grade": spec.Grade != nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": types})
+}
+
+// 18.2 自动判分，作答格式由题型决定
+func gradeQuestion(c *gin.Context) {
+	var req GradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	spec := lookupQuestionType(question.Type)
+	if spec == nil || spec.Grade == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该题型不支持自动判分: " + string(question.Type)})
+		return
+	}
+	if errs := spec.Validate(question.Content, question.Payload); len(errs) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "题目答案不完整，无法判分", "errors": errs})
+		return
+	}
+
+	result, err := spec.Grade(question.Payload, req.Response)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
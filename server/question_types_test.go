@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func options(texts ...string) []models.ChoiceOption {
+	list := make([]models.ChoiceOption, len(texts))
+	for i, text := range texts {
+		list[i] = models.ChoiceOption{ID: string(rune('A' + i)), Text: text}
+	}
+	return list
+}
+
+func TestValidators(t *testing.T) {
+	pairs := func(p ...string) []models.MatchPair {
+		list := make([]models.MatchPair, 0, len(p)/2)
+		for i := 0; i+1 < len(p); i += 2 {
+			list = append(list, models.MatchPair{ID: fmt.Sprint(i/2 + 1), Left: p[i], Right: p[i+1]})
+		}
+		return list
+	}
+	blank := func(id string, answers ...string) models.ClozeBlank {
+		return models.ClozeBlank{ID: id, Answers: answers}
+	}
+	yes := true
+
+	tests := []struct {
+		name    string
+		t       models.QuestionType
+		content string
+		p       models.QuestionPayload
+		want    []string
+	}{
+		{"single choice ok", models.SingleChoice, "", models.QuestionPayload{Options: options("a", "b"), Answers: []string{"A"}}, nil},
+		{"single choice two answers", models.SingleChoice, "", models.QuestionPayload{Options: options("a", "b"), Answers: []string{"A", "B"}}, []string{"answers"}},
+		{"multiple choice ok", models.MultipleChoice, "", models.QuestionPayload{Options: options("a", "b", "c"), Answers: []string{"A", "C"}}, nil},
+		{"choice missing answer", models.MultipleChoice, "", models.QuestionPayload{Options: options("a", "b")}, []string{"answers"}},
+		{"choice unknown and repeated answer", models.MultipleChoice, "", models.QuestionPayload{Options: options("a", "b"), Answers: []string{"A", "A", "E"}},
+			[]string{"answers[1]", "answers[2]"}},
+		{"choice too few options", models.SingleChoice, "", models.QuestionPayload{Options: options("a"), Answers: []string{"A"}}, []string{"options"}},
+		{"choice bad and duplicate option", models.SingleChoice, "", models.QuestionPayload{
+			Options: []models.ChoiceOption{{ID: "A", Text: "a"}, {ID: "A", Text: "a"}, {ID: "a b", Text: ""}}, Answers: []string{"A"}},
+			[]string{"options[1].id", "options[1].text", "options[2].id", "options[2].text"}},
+		{"true false ok", models.TrueFalse, "", models.QuestionPayload{Correct: &yes}, nil},
+		{"true false missing", models.TrueFalse, "", models.QuestionPayload{}, []string{"correct"}},
+		{"cloze ok", models.Cloze, "用 {{1}} 声明变量，用 {{2}} 声明常量", models.QuestionPayload{Blanks: []models.ClozeBlank{blank("1", "var"), blank("2", "const")}}, nil},
+		{"cloze no blanks", models.Cloze, "没有空位", models.QuestionPayload{}, []string{"blanks"}},
+		{"cloze marker without answer", models.Cloze, "{{1}} 和 {{2}}", models.QuestionPayload{Blanks: []models.ClozeBlank{blank("1", "a")}}, []string{"content"}},
+		{"cloze blank without marker", models.Cloze, "{{1}}", models.QuestionPayload{Blanks: []models.ClozeBlank{blank("1", "a"), blank("2", "b")}}, []string{"blanks[1].id"}},
+		{"cloze repeated marker", models.Cloze, "{{1}} {{1}}", models.QuestionPayload{Blanks: []models.ClozeBlank{blank("1", "a")}}, []string{"blanks[0].id"}},
+		{"cloze empty answers", models.Cloze, "{{1}} {{2}}", models.QuestionPayload{Blanks: []models.ClozeBlank{blank("1"), blank("2", "")}},
+			[]string{"blanks[0].answers", "blanks[1].answers[0]"}},
+		{"short answer ok", models.ShortAnswer, "", models.QuestionPayload{AcceptedAnswers: []string{"goroutine"}, Patterns: []string{"^go"}}, nil},
+		{"short answer pattern only", models.ShortAnswer, "", models.QuestionPayload{Patterns: []string{"^go"}}, nil},
+		{"short answer empty", models.ShortAnswer, "", models.QuestionPayload{}, []string{"accepted_answers"}},
+		{"short answer bad entries", models.ShortAnswer, "", models.QuestionPayload{AcceptedAnswers: []string{""}, Patterns: []string{"(", ""}},
+			[]string{"accepted_answers[0]", "patterns[0]", "patterns[1]"}},
+		{"matching ok", models.Matching, "", models.QuestionPayload{Pairs: pairs("int", "0", "string", `""`)}, nil},
+		{"matching too few", models.Matching, "", models.QuestionPayload{Pairs: pairs("int", "0")}, []string{"pairs"}},
+		{"matching duplicates and blanks", models.Matching, "", models.QuestionPayload{Pairs: pairs("int", "0", "int", "0", "", "")},
+			[]string{"pairs[1].left", "pairs[1].right", "pairs[2].left", "pairs[2].right"}},
+		{"ordering ok", models.Ordering, "", models.QuestionPayload{Items: options("声明", "赋值")}, nil},
+		{"ordering too few", models.Ordering, "", models.QuestionPayload{Items: options("声明")}, []string{"items"}},
+		{"ordering duplicate item", models.Ordering, "", models.QuestionPayload{Items: options("声明", "声明")}, []string{"items[1].text"}},
+		// 题型没有使用的字段不能设置
+		{"field of another type", models.Ordering, "", models.QuestionPayload{Items: options("a", "b"), Correct: &yes}, []string{"correct"}},
+		{"unknown type", models.QuestionType("essay"), "", models.QuestionPayload{}, []string{"type"}},
+	}
+	for _, tt := range tests {
+		got := errorFields(validatePayload(tt.t, tt.content, tt.p))
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGraders(t *testing.T) {
+	yes, no := true, false
+	choice := models.QuestionPayload{Options: options("a", "b", "c"), Answers: []string{"A", "C"}}
+	cloze := models.QuestionPayload{Blanks: []models.ClozeBlank{
+		{ID: "1", Answers: []string{"var", "VAR"}},
+		{ID: "2", Answers: []string{"Const"}, CaseSensitive: true},
+	}}
+	short := models.QuestionPayload{AcceptedAnswers: []string{"Garbage Collection"}, Patterns: []string{`^GC\b`}}
+	matching := models.QuestionPayload{Pairs: []models.MatchPair{{ID: "1", Left: "int", Right: "0"}, {ID: "2", Left: "bool", Right: "false"}}}
+	ordering := models.QuestionPayload{Items: options("声明", "赋值", "使用")}
+
+	tests := []struct {
+		name     string
+		t        models.QuestionType
+		p        models.QuestionPayload
+		response string
+		wantErr  bool
+		correct  bool
+		score    float64
+	}{
+		{"choice array", models.MultipleChoice, choice, `["c","A"]`, false, true, 1},
+		{"choice string", models.MultipleChoice, choice, `"A, C"`, false, true, 1},
+		{"choice missing option", models.MultipleChoice, choice, `["A"]`, false, false, 0},
+		{"choice extra option", models.MultipleChoice, choice, `"A,B,C"`, false, false, 0},
+		{"choice malformed", models.MultipleChoice, choice, `{"A":true}`, true, false, 0},
+		{"choice null", models.MultipleChoice, choice, `null`, true, false, 0},
+
+		{"true false right", models.TrueFalse, models.QuestionPayload{Correct: &no}, `false`, false, true, 1},
+		{"true false wrong", models.TrueFalse, models.QuestionPayload{Correct: &yes}, `false`, false, false, 0},
+		{"true false string", models.TrueFalse, models.QuestionPayload{Correct: &yes}, `"true"`, true, false, 0},
+		{"true false null", models.TrueFalse, models.QuestionPayload{Correct: &no}, `null`, true, false, 0},
+
+		{"cloze all right", models.Cloze, cloze, `{"1":" var ","2":"Const"}`, false, true, 1},
+		{"cloze case insensitive blank", models.Cloze, cloze, `{"1":"Var","2":"Const"}`, false, true, 1},
+		{"cloze case sensitive blank", models.Cloze, cloze, `{"1":"var","2":"const"}`, false, false, 0.5},
+		{"cloze missing blank", models.Cloze, cloze, `{"2":"Const"}`, false, false, 0.5},
+		{"cloze malformed", models.Cloze, cloze, `["var"]`, true, false, 0},
+		{"cloze null", models.Cloze, cloze, `null`, true, false, 0},
+
+		{"short answer case and spaces", models.ShortAnswer, short, `"  garbage   collection "`, false, true, 1},
+		{"short answer pattern", models.ShortAnswer, short, `"GC 回收"`, false, true, 1},
+		{"short answer pattern is case sensitive", models.ShortAnswer, short, `"gc 回收"`, false, false, 0},
+		{"short answer empty", models.ShortAnswer, short, `""`, false, false, 0},
+		{"short answer malformed", models.ShortAnswer, short, `42`, true, false, 0},
+		{"short answer null", models.ShortAnswer, short, `null`, true, false, 0},
+
+		{"matching all right", models.Matching, matching, `{"1":"0","2":"false"}`, false, true, 1},
+		{"matching partial", models.Matching, matching, `{"1":"0","2":"0"}`, false, false, 0.5},
+		{"matching malformed", models.Matching, matching, `"1-0"`, true, false, 0},
+		{"matching null", models.Matching, matching, `null`, true, false, 0},
+
+		{"ordering right", models.Ordering, ordering, `["A","B","C"]`, false, true, 1},
+		{"ordering partial", models.Ordering, ordering, `["A","C","B"]`, false, false, 1.0 / 3},
+		{"ordering too short", models.Ordering, ordering, `["A","B"]`, true, false, 0},
+		{"ordering too long", models.Ordering, ordering, `["A","B","C","A"]`, true, false, 0},
+		{"ordering malformed", models.Ordering, ordering, `"A,B,C"`, true, false, 0},
+		{"ordering null", models.Ordering, ordering, `null`, true, false, 0},
+	}
+	for _, tt := range tests {
+		result, err := lookupQuestionType(tt.t).Grade(tt.p, json.RawMessage(tt.response))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error, got %+v", tt.name, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if result.Correct != tt.correct || result.Score != tt.score {
+			t.Errorf("%s: correct = %v, score = %v, want %v, %v", tt.name, result.Correct, result.Score, tt.correct, tt.score)
+		}
+	}
+}
+
+func TestGradeQuestionEndpoint(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "", gin.H{"type": "true_false", "content": "nil 切片的长度为 0", "difficulty": "easy", "language": "Go",
+		"payload": gin.H{"correct": true}})
+	path := fmt.Sprintf("/api/questions/%d/grade", q.ID)
+
+	var resp struct {
+		Data  GradeResult `json:"data"`
+		Error string      `json:"error"`
+	}
+	if code := apiRequest(t, http.MethodPost, path, "", gin.H{"response": true}, &resp); code != http.StatusOK || !resp.Data.Correct {
+		t.Errorf("grade true: status = %d, result = %+v", code, resp.Data)
+	}
+	if code := apiRequest(t, http.MethodPost, path, "", gin.H{"response": nil}, &resp); code != http.StatusBadRequest {
+		t.Errorf("grade null: status = %d, want 400", code)
+	}
+
+	programming := createQuestion(t, "", gin.H{"type": "programming", "content": "实现 Add", "difficulty": "easy", "language": "Go"})
+	path = fmt.Sprintf("/api/questions/%d/grade", programming.ID)
+	if code := apiRequest(t, http.MethodPost, path, "", gin.H{"response": "code"}, nil); code != http.StatusBadRequest {
+		t.Errorf("grade programming: status = %d, want 400", code)
+	}
+}
+
+func TestAIPromptUsesTypeLabel(t *testing.T) {
+	for _, tt := range []struct {
+		t    models.QuestionType
+		want string
+	}{
+		{models.SingleChoice, "请生成3道单选题，"},
+		{models.Matching, "请生成3道连线题，"},
+		{models.Programming, "请生成3道编程题，"},
+	} {
+		prompt := buildAIPrompt(AIGenerateRequest{Type: tt.t, Count: 3, Difficulty: models.Easy, Language: "Go"})
+		if !strings.HasPrefix(prompt, tt.want) {
+			t.Errorf("%s: prompt starts with %q, want %q", tt.t, strings.SplitN(prompt, "\n", 2)[0], tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// revisionFields 比较修订版本时的字段顺序，与 QuestionSnapshot 的 json 字段一致
+var revisionFields = []string{"type", "content", "options", "answer", "payload", "explanation", "difficulty", "language", "locale", "tags"}
+
+// FieldChange 两个修订版本之间一个字段的变化
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// snapshotQuestion 生成题目当前内容的快照，题目需要预加载标签
+func snapshotQuestion(q models.Question) models.QuestionSnapshot {
+	return models.QuestionSnapshot{
+		Type:        q.Type,
+		Content:     q.Content,
+		Options:     q.Options,
+		Answer:      q.Answer,
+		Payload:     &q.Payload,
+		Explanation: q.Explanation,
+		Difficulty:  q.Difficulty,
+		Language:    q.Language,
+		Locale:      q.Locale,
+		Tags:        tagPaths(q.Tags),
+	}
+}
+
+func tagPaths(tags []models.Tag) []string {
+	paths := make([]string, len(tags))
+	for i, t := range tags {
+		paths[i] = t.Path
+	}
+	return paths
+}
+
+// recordQuestionRevision 按题目在事务中的当前状态追加一个修订版本，已删除的题目同样可以记录
+func recordQuestionRevision(tx *gorm.DB, questionID uint, action models.RevisionAction, author string) error {
+	var question models.Question
+	if err := tx.Unscoped().Preload("Tags").First(&question, questionID).Error; err != nil {
+		return err
+	}
+	var last int
+	if err := tx.Model(&models.QuestionRevision{}).Where("question_id = ?", questionID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.QuestionRevision{
+		QuestionID: questionID,
+		Revision:   last + 1,
+		Action:     action,
+		Snapshot:   snapshotQuestion(question),
+		Author:     author,
+	}).Error
+}
+
+// recordQuestionRevisions 为多道题目追加修订版本
+func recordQuestionRevisions(tx *gorm.DB, questionIDs []uint, action models.RevisionAction, author string) error {
+	for _, id := range questionIDs {
+		if err := recordQuestionRevision(tx, id, action, author); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteQuestionsWithRevision 删除题目并记录删除时的版本
+func deleteQuestionsWithRevision(ids []uint, author string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Question{}, ids).Error; err != nil {
+			return err
+		}
+		return recordQuestionRevisions(tx, ids, models.RevisionDelete, author)
+	})
+}
+
+// backfillQuestionRevisions 为还没有修订记录的题目补充初始版本，之后的修改才能和修改前的内容比较
+func backfillQuestionRevisions() {
+	var questions []models.Question
+	if err := db.Preload("Tags").Where("id NOT IN (SELECT question_id FROM question_revisions)").Find(&questions).Error; err != nil {
+		log.Printf("Failed to load questions for revision backfill: %v", err)
+		return
+	}
+	for _, q := range questions {
+		err := db.Create(&models.QuestionRevision{
+			QuestionID: q.ID,
+			Revision:   1,
+			Action:     models.RevisionCreate,
+			Snapshot:   snapshotQuestion(q),
+			CreatedAt:  q.UpdatedAt,
+		}).Error
+		if err != nil {
+			log.Printf("Failed to record initial revision for question %d: %v", q.ID, err)
+		}
+	}
+	if len(questions) > 0 {
+		log.Printf("Recorded initial revisions for %d questions", len(questions))
+	}
+}
+
+// diffSnapshots 逐字段比较两个快照，只返回有变化的字段
+func diffSnapshots(from, to models.QuestionSnapshot) []FieldChange {
+	a, b := snapshotFields(from), snapshotFields(to)
+	changes := []FieldChange{}
+	for _, f := range revisionFields {
+		if !reflect.DeepEqual(a[f], b[f]) {
+			changes = append(changes, FieldChange{Field: f, From: a[f], To: b[f]})
+		}
+	}
+	return changes
+}
+
+func snapshotFields(s models.QuestionSnapshot) map[string]interface{} {
+	var m map[string]interface{}
+	b, _ := json.Marshal(s)
+	json.Unmarshal(b, &m)
+	return m
+}
+
+// redactRevision 不是出题端查看时，去掉快照中编程题的参考答案和隐藏用例
+func redactRevision(c *gin.Context, rev *models.QuestionRevision) {
+	if isAuthorView(c) || rev.Snapshot.Payload == nil {
+		return
+	}
+	p := learnerPayload(*rev.Snapshot.Payload)
+	rev.Snapshot.Payload = &p
+}
+
+// findRevision 查找题目的指定版本
+func findRevision(questionID string, revision string) (*models.QuestionRevision, error) {
+	var rev models.QuestionRevision
+	err := db.Where("question_id = ? AND revision = ?", questionID, revision).First(&rev).Error
+	return &rev, err
+}
+
+// 15.1 题目的修订历史，最新的版本在前
+func getQuestionRevisions(c *gin.Context) {
+	var question models.Question
+	if err := db.Unscoped().First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	var revisions []models.QuestionRevision
+	if err := db.Where("question_id = ?", question.ID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range revisions {
+		redactRevision(c, &revisions[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions, "deleted": question.DeletedAt.Valid})
+}
+
+// 15.2 单个修订版本
+func getQuestionRevision(c *gin.Context) {
+	rev, err := findRevision(c.Param("id"), c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	redactRevision(c, rev)
+
+	c.JSON(http.StatusOK, gin.H{"data": rev})
+}
+
+// 15.3 比较两个修订版本，参数 from、to 为版本号；
+// 不传 to 时取最新版本，不传 from 时取 to 的上一个版本
+func diffQuestionRevisions(c *gin.Context) {
+	questionID := c.Param("id")
+	to := c.Query("to")
+	if to == "" {
+		var last int
+		db.Model(&models.QuestionRevision{}).Where("question_id = ?", questionID).
+			Select("COALESCE(MAX(revision), 0)").Scan(&last)
+		to = strconv.Itoa(last)
+	}
+	from := c.Query("from")
+	if from == "" {
+		n, err := strconv.Atoi(to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号: " + to})
+			return
+		}
+		from = strconv.Itoa(n - 1)
+	}
+
+	fromRev, err := findRevision(questionID, from)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在: " + from})
+		return
+	}
+	toRev, err := findRevision(questionID, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在: " + to})
+		return
+	}
+	redactRevision(c, fromRev)
+	redactRevision(c, toRev)
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    fromRev.Revision,
+		"to":      toRev.Revision,
+		"changes": diffSnapshots(fromRev.Snapshot, toRev.Snapshot),
+	})
+}
+
+// 15.4 把题目回滚到指定版本，已删除的题目同时恢复，回滚本身也记录为一个新版本；
+// 回滚后的题目退回草稿，需要重新审核
+func restoreQuestionRevision(c *gin.Context) {
+	rev, err := findRevision(c.Param("id"), c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	var question models.Question
+	if err := db.Unscoped().First(&question, rev.QuestionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	s := rev.Snapshot
+	question.Type = s.Type
+	question.Content = s.Content
+	question.Options = s.Options
+	question.Answer = s.Answer
+	question.Payload = questionPayload(s.Type, s.Payload, s.Options, s.Answer)
+	question.Explanation = s.Explanation
+	question.Difficulty = s.Difficulty
+	question.Language = s.Language
+	question.Locale = s.Locale
+	question.DeletedAt = gorm.DeletedAt{}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 标签按路径匹配，回滚前已删除的标签重新创建
+		tags, err := resolveRequestTags(tx, nil, s.Tags)
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Omit("Tags").Save(&question).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&question).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		question.Tags = tags
+		if err := saveQuestionFingerprint(tx, &question); err != nil {
+			return err
+		}
+		if err := returnToDraft(tx, &question, "restore", requestUser(c)); err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question.ID, models.RevisionRestore, requestUser(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": question, "restored_from": rev.Revision})
+}
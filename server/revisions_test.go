@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type revisionsResponse struct {
+	Data    []models.QuestionRevision `json:"data"`
+	Deleted bool                      `json:"deleted"`
+}
+
+func TestRevisionHistoryDiffAndRestore(t *testing.T) {
+	resetData(t)
+	body := choiceQuestion("slice 的底层结构")
+	q := createQuestion(t, "alice", body)
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+
+	body["content"] = "slice 的底层结构包含哪些字段"
+	body["difficulty"] = "medium"
+	if code := apiRequest(t, http.MethodPut, base, "bob", body, nil); code != http.StatusOK {
+		t.Fatalf("update: status = %d", code)
+	}
+
+	var history revisionsResponse
+	apiRequest(t, http.MethodGet, base+"/revisions", "", nil, &history)
+	if len(history.Data) != 2 {
+		t.Fatalf("revisions = %d, want 2", len(history.Data))
+	}
+	latest := history.Data[0]
+	if latest.Revision != 2 || latest.Action != models.RevisionUpdate || latest.Author != "bob" {
+		t.Errorf("latest revision = %d %s by %q, want 2 update by bob", latest.Revision, latest.Action, latest.Author)
+	}
+	if history.Data[1].Action != models.RevisionCreate || history.Data[1].Author != "alice" {
+		t.Errorf("first revision = %s by %q, want create by alice", history.Data[1].Action, history.Data[1].Author)
+	}
+
+	// 不传版本号时比较最新版本和上一个版本，只返回变化的字段
+	var diff struct {
+		From    int           `json:"from"`
+		To      int           `json:"to"`
+		Changes []FieldChange `json:"changes"`
+	}
+	if code := apiRequest(t, http.MethodGet, base+"/revisions/diff", "", nil, &diff); code != http.StatusOK {
+		t.Fatalf("diff: status = %d", code)
+	}
+	changed := make(map[string]FieldChange)
+	for _, ch := range diff.Changes {
+		changed[ch.Field] = ch
+	}
+	if diff.From != 1 || diff.To != 2 || len(changed) != 2 {
+		t.Errorf("diff %d..%d changed %v, want content and difficulty", diff.From, diff.To, diff.Changes)
+	}
+	if ch := changed["content"]; ch.From != "slice 的底层结构" || ch.To != body["content"] {
+		t.Errorf("content change = %v -> %v", ch.From, ch.To)
+	}
+
+	var restored struct {
+		Data         models.Question `json:"data"`
+		RestoredFrom int             `json:"restored_from"`
+	}
+	if code := apiRequest(t, http.MethodPost, base+"/revisions/1/restore", "carol", nil, &restored); code != http.StatusOK {
+		t.Fatalf("restore: status = %d", code)
+	}
+	if restored.RestoredFrom != 1 || restored.Data.Content != "slice 的底层结构" || restored.Data.Difficulty != "easy" {
+		t.Errorf("restored = %q (%s) from %d", restored.Data.Content, restored.Data.Difficulty, restored.RestoredFrom)
+	}
+	apiRequest(t, http.MethodGet, base+"/revisions", "", nil, &history)
+	if len(history.Data) != 3 || history.Data[0].Action != models.RevisionRestore || history.Data[0].Author != "carol" {
+		t.Errorf("after restore: %d revisions, latest %s by %q", len(history.Data), history.Data[0].Action, history.Data[0].Author)
+	}
+}
+
+func TestRevisionRestoresDeletedQuestion(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "", choiceQuestion("interface 的零值"))
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+	if code := apiRequest(t, http.MethodDelete, base, "", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status = %d", code)
+	}
+
+	var history revisionsResponse
+	apiRequest(t, http.MethodGet, base+"/revisions", "", nil, &history)
+	if !history.Deleted || len(history.Data) != 2 || history.Data[0].Action != models.RevisionDelete {
+		t.Fatalf("deleted = %v, %d revisions", history.Deleted, len(history.Data))
+	}
+	if code := apiRequest(t, http.MethodPost, base+"/revisions/1/restore", "", nil, nil); code != http.StatusOK {
+		t.Fatalf("restore: status = %d", code)
+	}
+	if _, resp := listQuestions(t, ""); len(resp.Data) != 1 || resp.Data[0].ID != q.ID {
+		t.Errorf("listing after restore = %d questions, want the restored one", len(resp.Data))
+	}
+}
+
+func TestRevisionNotFound(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "", choiceQuestion("select 的默认分支"))
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, base + "/revisions/9", http.StatusNotFound},
+		{http.MethodPost, base + "/revisions/9/restore", http.StatusNotFound},
+		{http.MethodGet, base + "/revisions/diff?from=1&to=9", http.StatusNotFound},
+		{http.MethodGet, base + "/revisions/diff?to=x", http.StatusBadRequest},
+		{http.MethodGet, "/api/questions/99999/revisions", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := apiRequest(t, tt.method, tt.path, "", nil, nil); code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, code, tt.want)
+		}
+	}
+}
+
+func TestRevisionsHideSolutions(t *testing.T) {
+	resetData(t)
+	body := programmingQuestion("实现两数相加")
+	q := createQuestion(t, "alice", body)
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+
+	// 同时修改起始代码和隐藏用例，比较结果中才有 payload 字段
+	body["payload"] = gin.H{
+		"templates": []gin.H{{"language": "Go", "starter": "func Add(a, b int) int {\n\t// TODO\n}", "solution": "func Add(a, b int) int {\n\treturn a + b\n}"}},
+		"test_cases": []gin.H{
+			{"function": "Add", "args": []int{1, 2}, "expected": 3},
+			{"input": "secret-input-2", "output": "secret-output-2", "hidden": true},
+		},
+	}
+	if code := apiRequest(t, http.MethodPut, base, "alice", body, nil); code != http.StatusOK {
+		t.Fatalf("update: status = %d", code)
+	}
+
+	for _, path := range []string{base + "/revisions", base + "/revisions/1", base + "/revisions/diff"} {
+		assertLearnerView(t, path)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 相似度检测：题目内容和结构化内容规范化后切分为字符 shingle，计算 MinHash 签名并按段分桶（LSH）。
+// 同一段落入同一个桶的题目作为候选，再用签名估算 Jaccard 相似度。
+const (
+	shingleSize         = 3  // 按字符切分，中英文都适用
+	minHashSize         = 64 // 签名长度
+	minHashRows         = 2  // 每段的行数，共 32 段，相似度 0.3 以上的题目基本都能召回
+	minHashBands        = minHashSize / minHashRows
+	defaultSimilarLimit = 10
+	// fingerprintVersion 指纹规则的版本，参与比较的文本变化时递增，已保存的指纹在启动时重新计算
+	fingerprintVersion = 2
+)
+
+// similarityThreshold 判定为重复的最低相似度，由 SIMILARITY_THRESHOLD 配置，默认 0.8
+var similarityThreshold = 0.8
+
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		x += 0x9E3779B97F4A7C15
+		seeds[i] = mix64(x)
+	}
+	return seeds
+}()
+
+// mix64 splitmix64 的混合函数
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
+
+// SimilarQuestion 相似题目及相似度
+type SimilarQuestion struct {
+	ID      uint    `json:"id"`
+	Score   float64 `json:"score"`
+	Exact   bool    `json:"exact"` // 规范化后文本完全相同
+	Content string  `json:"content"`
+}
+
+type questionFingerprint struct {
+	Hash      string
+	Signature []uint32
+}
+
+func loadSimilarityThreshold() {
+	if v := os.Getenv("SIMILARITY_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			similarityThreshold = f
+		} else {
+			log.Printf("Warning: invalid SIMILARITY_THRESHOLD %q, using %.2f", v, similarityThreshold)
+		}
+	}
+}
+
+// normalizeSimilarityText 转小写、全角转半角，只保留字母和数字
+func normalizeSimilarityText(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return sb.String()
+}
+
+// similarityText 参与比较的文本：题型、题目内容加上结构化内容中各题型自己的字段，
+// 题干相同但题型或配对、空位、测试用例不同的题目不算重复。
+// 选择题的答案和判断题的对错不参与比较，同一道题答案不同时仍然视为重复
+func similarityText(t models.QuestionType, content string, p models.QuestionPayload) string {
+	parts := []string{string(t), content}
+	options := append([]models.ChoiceOption{}, p.Options...)
+	sort.Slice(options, func(i, j int) bool { return options[i].ID < options[j].ID })
+	for _, o := range options {
+		parts = append(parts, o.Text)
+	}
+	for _, b := range p.Blanks {
+		parts = append(parts, b.ID)
+		parts = append(parts, b.Answers...)
+	}
+	parts = append(parts, p.AcceptedAnswers...)
+	parts = append(parts, p.Patterns...)
+	for _, pair := range p.Pairs {
+		parts = append(parts, pair.Left, pair.Right)
+	}
+	for _, item := range p.Items {
+		parts = append(parts, item.Text)
+	}
+	for _, tpl := range p.Templates {
+		parts = append(parts, tpl.Language, tpl.Starter)
+	}
+	for _, tc := range p.TestCases {
+		parts = append(parts, tc.Input, tc.Output, tc.Function)
+		for _, arg := range tc.Args {
+			parts = append(parts, fmt.Sprintf("%v", arg))
+		}
+		if tc.Expected != nil {
+			parts = append(parts, fmt.Sprintf("%v", tc.Expected))
+		}
+	}
+	return normalizeSimilarityText(strings.Join(parts, " "))
+}
+
+// requestFingerprint 新建或生成的题目的指纹，旧格式的请求按 options、answer 转换后计算
+func requestFingerprint(req QuestionRequest) questionFingerprint {
+	return fingerprintQuestion(req.Type, req.Content, questionPayload(req.Type, req.Payload, req.Options, req.Answer))
+}
+
+// fingerprintQuestion 计算题目的精确哈希和 MinHash 签名
+func fingerprintQuestion(t models.QuestionType, content string, p models.QuestionPayload) questionFingerprint {
+	text := similarityText(t, content, p)
+	sum := sha256.Sum256([]byte(text))
+	fp := questionFingerprint{Hash: hex.EncodeToString(sum[:]), Signature: make([]uint32, minHashSize)}
+
+	runes := []rune(text)
+	var shingles []string
+	if len(runes) <= shingleSize {
+		shingles = []string{text}
+	} else {
+		for i := 0; i+shingleSize <= len(runes); i++ {
+			shingles = append(shingles, string(runes[i:i+shingleSize]))
+		}
+	}
+
+	for i := range fp.Signature {
+		fp.Signature[i] = ^uint32(0)
+	}
+	for _, s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		base := h.Sum64()
+		for i, seed := range minHashSeeds {
+			if v := uint32(mix64(base ^ seed)); v < fp.Signature[i] {
+				fp.Signature[i] = v
+			}
+		}
+	}
+	return fp
+}
+
+func (fp questionFingerprint) encodeSignature() string {
+	b := make([]byte, 4*len(fp.Signature))
+	for i, v := range fp.Signature {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return hex.EncodeToString(b)
+}
+
+func decodeSignature(s string) []uint32 {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4*minHashSize {
+		return nil
+	}
+	sig := make([]uint32, minHashSize)
+	for i := range sig {
+		sig[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	return sig
+}
+
+// bandBuckets 把签名分段，每段哈希为一个桶
+func (fp questionFingerprint) bandBuckets() []string {
+	buckets := make([]string, minHashBands)
+	for band := range buckets {
+		h := fnv.New64a()
+		b := make([]byte, 4)
+		for _, v := range fp.Signature[band*minHashRows : (band+1)*minHashRows] {
+			binary.BigEndian.PutUint32(b, v)
+			h.Write(b)
+		}
+		buckets[band] = fmt.Sprintf("%016x", h.Sum64())
+	}
+	return buckets
+}
+
+// estimateSimilarity 签名中相同位置取值相同的比例，即 Jaccard 相似度的估计
+func estimateSimilarity(a, b []uint32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// saveQuestionFingerprint 保存题目指纹和分桶，题目创建或修改后调用
+func saveQuestionFingerprint(tx *gorm.DB, question *models.Question) error {
+	fp := fingerprintQuestion(question.Type, question.Content, question.Payload)
+	if err := tx.Save(&models.QuestionFingerprint{
+		QuestionID:  question.ID,
+		ContentHash: fp.Hash,
+		Signature:   fp.encodeSignature(),
+		Version:     fingerprintVersion,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("question_id = ?", question.ID).Delete(&models.QuestionSimilarityBand{}).Error; err != nil {
+		return err
+	}
+	bands := make([]models.QuestionSimilarityBand, 0, minHashBands)
+	for band, bucket := range fp.bandBuckets() {
+		bands = append(bands, models.QuestionSimilarityBand{QuestionID: question.ID, Band: band, Bucket: bucket})
+	}
+	return tx.Create(&bands).Error
+}
+
+// findSimilarQuestions 在题库中查找相似度不低于 threshold 的题目，按相似度从高到低排列。
+// 已删除的题目不会返回；excludeID 不为 0 时排除该题目本身。
+func findSimilarQuestions(fp questionFingerprint, excludeID uint, threshold float64, limit int) ([]SimilarQuestion, error) {
+	conds := []string{"question_id IN (SELECT question_id FROM question_fingerprints WHERE content_hash = ?)"}
+	args := []interface{}{fp.Hash}
+	for band, bucket := range fp.bandBuckets() {
+		conds = append(conds, "(band = ? AND bucket = ?)")
+		args = append(args, band, bucket)
+	}
+
+	var candidateIDs []uint
+	if err := db.Model(&models.QuestionSimilarityBand{}).
+		Where(strings.Join(conds, " OR "), args...).
+		Where("question_id <> ?", excludeID).
+		Distinct().Pluck("question_id", &candidateIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(candidateIDs) == 0 {
+		return []SimilarQuestion{}, nil
+	}
+
+	var fingerprints []models.QuestionFingerprint
+	if err := db.Where("question_id IN ?", candidateIDs).Find(&fingerprints).Error; err != nil {
+		return nil, err
+	}
+	scores := make(map[uint]SimilarQuestion)
+	for _, f := range fingerprints {
+		match := SimilarQuestion{ID: f.QuestionID, Exact: f.ContentHash == fp.Hash}
+		if match.Exact {
+			match.Score = 1
+		} else {
+			match.Score = estimateSimilarity(fp.Signature, decodeSignature(f.Signature))
+		}
+		if match.Score >= threshold {
+			scores[f.QuestionID] = match
+		}
+	}
+	if len(scores) == 0 {
+		return []SimilarQuestion{}, nil
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	var questions []models.Question
+	if err := db.Select("id", "content").Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	matches := make([]SimilarQuestion, 0, len(questions))
+	for _, q := range questions {
+		match := scores[q.ID]
+		match.Content = q.Content
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// hasExactDuplicate 是否存在完全相同的题目
+func hasExactDuplicate(matches []SimilarQuestion) bool {
+	for _, m := range matches {
+		if m.Exact {
+			return true
+		}
+	}
+	return false
+}
+
+// flagDuplicateQuestions 检查AI生成的题目是否与题库重复，在题目上记录相似题目并返回警告
+func flagDuplicateQuestions(questions []GeneratedQuestion) []string {
+	var warnings []string
+	for i := range questions {
+		if questions[i].Provenance == ProvenancePlaceholder {
+			continue
+		}
+		fp := requestFingerprint(questions[i].QuestionRequest)
+		matches, err := findSimilarQuestions(fp, 0, similarityThreshold, defaultSimilarLimit)
+		if err != nil {
+			log.Printf("Duplicate check failed: %v", err)
+			continue
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		questions[i].Duplicates = matches
+		warnings = append(warnings, fmt.Sprintf("第%d题与题库中的题目 #%d 重复（相似度 %.2f）", i+1, matches[0].ID, matches[0].Score))
+	}
+	return warnings
+}
+
+// backfillQuestionFingerprints 为还没有指纹或指纹版本过旧的题目重新计算指纹，启动时在生成结构化内容之后调用
+func backfillQuestionFingerprints() {
+	var questions []models.Question
+	if err := db.Where("id NOT IN (SELECT question_id FROM question_fingerprints WHERE version >= ?)", fingerprintVersion).Find(&questions).Error; err != nil {
+		log.Printf("Failed to load questions for fingerprint backfill: %v", err)
+		return
+	}
+	for i := range questions {
+		if err := saveQuestionFingerprint(db, &questions[i]); err != nil {
+			log.Printf("Failed to fingerprint question %d: %v", questions[i].ID, err)
+		}
+	}
+	if len(questions) > 0 {
+		log.Printf("Computed similarity fingerprints for %d questions", len(questions))
+	}
+}
+
+// 1.1 相似题目查询
+func getSimilarQuestions(c *gin.Context) {
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	threshold := 0.5
+	if v := c.Query("threshold"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold 必须在 0 到 1 之间"})
+			return
+		}
+		threshold = f
+	}
+	limit := defaultSimilarLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须是正整数"})
+			return
+		}
+		limit = n
+	}
+
+	matches, err := findSimilarQuestions(fingerprintQuestion(question.Type, question.Content, question.Payload), question.ID, threshold, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": matches, "threshold": threshold})
+}
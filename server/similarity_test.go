@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFingerprintUsesPayload(t *testing.T) {
+	yes, no := true, false
+	pairs := func(rights ...string) models.QuestionPayload {
+		var p models.QuestionPayload
+		for i, r := range rights {
+			p.Pairs = append(p.Pairs, models.MatchPair{ID: string(rune('1' + i)), Left: string(rune('a' + i)), Right: r})
+		}
+		return p
+	}
+	const stem = "把左侧的包和用途连起来"
+
+	tests := []struct {
+		name      string
+		aType     models.QuestionType
+		a         models.QuestionPayload
+		bType     models.QuestionType
+		b         models.QuestionPayload
+		wantExact bool
+	}{
+		{"same pairs", models.Matching, pairs("fmt", "io"), models.Matching, pairs("fmt", "io"), true},
+		{"different pairs", models.Matching, pairs("fmt", "io"), models.Matching, pairs("net", "os"), false},
+		{"different blanks", models.Cloze,
+			models.QuestionPayload{Blanks: []models.ClozeBlank{{ID: "1", Answers: []string{"defer"}}}},
+			models.Cloze,
+			models.QuestionPayload{Blanks: []models.ClozeBlank{{ID: "1", Answers: []string{"go"}}}}, false},
+		{"different test cases", models.Programming,
+			models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseStdio, Input: "1 2", Output: "3"}}},
+			models.Programming,
+			models.QuestionPayload{TestCases: []models.TestCase{{ID: "1", Kind: models.TestCaseStdio, Input: "2 2", Output: "4"}}}, false},
+		{"different types", models.TrueFalse, models.QuestionPayload{Correct: &yes}, models.ShortAnswer,
+			models.QuestionPayload{AcceptedAnswers: []string{"是"}}, false},
+		// 判断题的对错不参与比较
+		{"true false answer ignored", models.TrueFalse, models.QuestionPayload{Correct: &yes}, models.TrueFalse,
+			models.QuestionPayload{Correct: &no}, true},
+		// 选项按 ID 排序后比较，与选项的提交顺序无关
+		{"option order", models.SingleChoice,
+			models.QuestionPayload{Options: []models.ChoiceOption{{ID: "A", Text: "x"}, {ID: "B", Text: "y"}}, Answers: []string{"A"}},
+			models.SingleChoice,
+			models.QuestionPayload{Options: []models.ChoiceOption{{ID: "B", Text: "y"}, {ID: "A", Text: "x"}}, Answers: []string{"B"}}, true},
+	}
+	for _, tt := range tests {
+		a := fingerprintQuestion(tt.aType, stem, tt.a)
+		b := fingerprintQuestion(tt.bType, stem, tt.b)
+		if exact := a.Hash == b.Hash; exact != tt.wantExact {
+			t.Errorf("%s: exact = %v, want %v", tt.name, exact, tt.wantExact)
+		}
+	}
+}
+
+func TestSameStemDifferentPayloadIsNotDuplicate(t *testing.T) {
+	resetData(t)
+	matching := func(rights ...string) gin.H {
+		pairs := make([]gin.H, len(rights))
+		for i, r := range rights {
+			pairs[i] = gin.H{"left": string(rune('a' + i)), "right": r}
+		}
+		return gin.H{"type": "matching", "content": "把左侧的包和用途连起来", "difficulty": "easy", "language": "Go", "payload": gin.H{"pairs": pairs}}
+	}
+
+	createQuestion(t, "", matching("fmt", "io"))
+	createQuestion(t, "", matching("net", "os"))
+	var resp struct {
+		Duplicates []SimilarQuestion `json:"duplicates"`
+	}
+	if code := apiRequest(t, http.MethodPost, "/api/questions", "", matching("fmt", "io"), &resp); code != http.StatusConflict || len(resp.Duplicates) == 0 {
+		t.Errorf("same matching question: status = %d, want 409", code)
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tagPathSeparators 标签路径的分隔符，"Go/并发" 和 "Go > 并发" 等价
+const tagPathSeparators = "/>"
+
+type TagRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// TagNode 带题目数量的标签，树形返回时包含子标签
+type TagNode struct {
+	models.Tag
+	// QuestionCount 直接打上该标签的题目数
+	QuestionCount int64 `json:"question_count"`
+	// TotalCount 该标签及所有子标签下的题目数，同一道题只计一次
+	TotalCount int64      `json:"total_count"`
+	Children   []*TagNode `json:"children,omitempty"`
+}
+
+// splitTagPath 拆分并清理标签路径
+func splitTagPath(path string) []string {
+	var names []string
+	for _, name := range strings.FieldsFunc(path, func(r rune) bool {
+		return strings.ContainsRune(tagPathSeparators, r)
+	}) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// descendantCond 匹配路径为 path 的标签及其所有子标签，column 为 tags.path 所在的列
+func descendantCond(column string) string {
+	return fmt.Sprintf("(%s = ? OR substr(%s, 1, length(?) + 1) = ? || '/')", column, column)
+}
+
+// resolveTagPath 按路径查找标签，不存在的层级依次创建
+func resolveTagPath(tx *gorm.DB, path string) (*models.Tag, error) {
+	names := splitTagPath(path)
+	if len(names) == 0 {
+		return nil, errors.New("标签不能为空")
+	}
+
+	var parent *models.Tag
+	for i := range names {
+		full := strings.Join(names[:i+1], "/")
+		tag := models.Tag{Name: names[i], Path: full}
+		if parent != nil {
+			tag.ParentID = &parent.ID
+		}
+		if err := tx.Where("path = ?", full).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		parent = &tag
+	}
+	return parent, nil
+}
+
+// resolveTagRef 解析标签引用：带分隔符的按完整路径处理；
+// 单个名称优先匹配已有的同名标签（层级最浅的），没有时在根级创建
+func resolveTagRef(tx *gorm.DB, ref string) (*models.Tag, error) {
+	names := splitTagPath(ref)
+	if len(names) != 1 {
+		return resolveTagPath(tx, ref)
+	}
+	var tag models.Tag
+	err := tx.Where("name = ?", names[0]).Order("length(path), id").First(&tag).Error
+	if err == nil {
+		return &tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return resolveTagPath(tx, names[0])
+}
+
+// resolveRequestTags 合并请求中的标签ID和标签引用，结果去重
+func resolveRequestTags(tx *gorm.DB, ids []uint, refs []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Find(&tags).Error; err != nil {
+			return nil, err
+		}
+		if len(tags) != len(uniqueUints(ids)) {
+			return nil, errors.New("部分标签不存在")
+		}
+	}
+	for _, ref := range refs {
+		tag, err := resolveTagRef(tx, ref)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *tag)
+	}
+
+	seen := make(map[uint]bool, len(tags))
+	unique := tags[:0]
+	for _, t := range tags {
+		if !seen[t.ID] {
+			seen[t.ID] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique, nil
+}
+
+func uniqueUints(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var out []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// tagNames 标签名称列表
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// findTag 按ID或路径查找标签
+func findTag(ref string) (*models.Tag, error) {
+	var tag models.Tag
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		if err := db.First(&tag, id).Error; err != nil {
+			return nil, err
+		}
+		return &tag, nil
+	}
+	if err := db.Where("path = ?", strings.Join(splitTagPath(ref), "/")).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// tagFilterSubquery 打上该标签或其任一子标签的题目ID
+func tagFilterSubquery(tag *models.Tag) *gorm.DB {
+	return db.Table("question_tags").
+		Select("question_tags.question_id").
+		Joins("JOIN tags ON tags.id = question_tags.tag_id").
+		Where(descendantCond("tags.path"), tag.Path, tag.Path, tag.Path)
+}
+
+// tagCounts 统计每个标签直接关联的题目数，以及包含子标签在内的题目数，已删除的题目不计
+func tagCounts() (direct, total map[uint]int64, err error) {
+	type row struct {
+		TagID uint
+		Count int64
+	}
+	var rows []row
+	if err = db.Table("question_tags").
+		Select("question_tags.tag_id AS tag_id, COUNT(*) AS count").
+		Joins("JOIN questions ON questions.id = question_tags.question_id AND questions.deleted_at IS NULL").
+		Group("question_tags.tag_id").Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	direct = make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		direct[r.TagID] = r.Count
+	}
+
+	rows = nil
+	if err = db.Table("tags AS t").
+		Select("t.id AS tag_id, COUNT(DISTINCT question_tags.question_id) AS count").
+		Joins("JOIN tags AS d ON "+descendantCond("d.path"), gorm.Expr("t.path"), gorm.Expr("t.path"), gorm.Expr("t.path")).
+		Joins("JOIN question_tags ON question_tags.tag_id = d.id").
+		Joins("JOIN questions ON questions.id = question_tags.question_id AND questions.deleted_at IS NULL").
+		Group("t.id").Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	total = make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		total[r.TagID] = r.Count
+	}
+	return direct, total, nil
+}
+
+// migrateLegacyQuestionTags 把旧版本存在 questions.tags 列中的标签名称迁移到标签表，迁移后清空该列
+func migrateLegacyQuestionTags() {
+	if !db.Migrator().HasColumn(&models.Question{}, "tags") {
+		return
+	}
+	type legacyRow struct {
+		ID   uint
+		Tags string
+	}
+	var rows []legacyRow
+	if err := db.Table("questions").Select("id", "tags").
+		Where("tags IS NOT NULL AND tags NOT IN ('', '[]')").Scan(&rows).Error; err != nil {
+		log.Printf("Failed to load legacy question tags: %v", err)
+		return
+	}
+	for _, r := range rows {
+		var names []string
+		if err := json.Unmarshal([]byte(r.Tags), &names); err != nil {
+			log.Printf("Skip invalid legacy tags of question %d: %v", r.ID, err)
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			tags, err := resolveRequestTags(tx, nil, names)
+			if err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				if err := tx.Model(&models.Question{ID: r.ID}).Association("Tags").Append(tags); err != nil {
+					return err
+				}
+			}
+			return tx.Table("questions").Where("id = ?", r.ID).Update("tags", "[]").Error
+		})
+		if err != nil {
+			log.Printf("Failed to migrate legacy tags of question %d: %v", r.ID, err)
+		}
+	}
+	if len(rows) > 0 {
+		log.Printf("Migrated legacy tags of %d questions", len(rows))
+	}
+}
+
+// 13.1 标签列表，tree=true 时按层级返回，每个标签附带题目数量
+func getTags(c *gin.Context) {
+	var tags []models.Tag
+	if err := db.Order("path").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	direct, total, err := tagCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	nodes := make([]*TagNode, 0, len(tags))
+	byID := make(map[uint]*TagNode, len(tags))
+	for _, t := range tags {
+		node := &TagNode{Tag: t, QuestionCount: direct[t.ID], TotalCount: total[t.ID]}
+		nodes = append(nodes, node)
+		byID[t.ID] = node
+	}
+	if c.Query("tree") != "true" {
+		c.JSON(http.StatusOK, gin.H{"data": nodes})
+		return
+	}
+
+	roots := []*TagNode{}
+	for _, node := range nodes {
+		if node.ParentID == nil || byID[*node.ParentID] == nil {
+			roots = append(roots, node)
+			continue
+		}
+		parent := byID[*node.ParentID]
+		parent.Children = append(parent.Children, node)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roots})
+}
+
+// 13.2 新建标签
+func createTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	tag, status, err := buildTag(models.Tag{}, req)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Create(tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tag})
+}
+
+// buildTag 校验名称和父标签并计算路径，返回错误时附带HTTP状态码
+func buildTag(tag models.Tag, req TagRequest) (*models.Tag, int, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || strings.ContainsAny(name, tagPathSeparators) {
+		return nil, http.StatusBadRequest, errors.New("标签名称不能为空，且不能包含 / 或 >")
+	}
+
+	path := name
+	if req.ParentID != nil {
+		var parent models.Tag
+		if err := db.First(&parent, *req.ParentID).Error; err != nil {
+			return nil, http.StatusBadRequest, errors.New("父标签不存在")
+		}
+		// 不能移动到自己或自己的子标签下
+		if tag.ID != 0 && (parent.ID == tag.ID || strings.HasPrefix(parent.Path+"/", tag.Path+"/")) {
+			return nil, http.StatusBadRequest, errors.New("不能把标签移动到它自己的子标签下")
+		}
+		path = parent.Path + "/" + name
+	}
+
+	var count int64
+	db.Model(&models.Tag{}).Where("path = ? AND id <> ?", path, tag.ID).Count(&count)
+	if count > 0 {
+		return nil, http.StatusConflict, errors.New("标签已存在: " + path)
+	}
+
+	tag.Name = name
+	tag.ParentID = req.ParentID
+	tag.Path = path
+	return &tag, 0, nil
+}
+
+// 13.3 标签详情，包含直接子标签
+func getTag(c *gin.Context) {
+	var tag models.Tag
+	if err := db.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	var children []models.Tag
+	if err := db.Where("parent_id = ?", tag.ID).Order("name").Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tag, "children": children})
+}
+
+// 13.4 修改标签名称或父标签，子标签的路径一并更新
+func updateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	var current models.Tag
+	if err := db.First(&current, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	tag, status, err := buildTag(current, req)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldPath := current.Path
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(tag).Error; err != nil {
+			return err
+		}
+		if tag.Path == oldPath {
+			return nil
+		}
+		return tx.Model(&models.Tag{}).
+			Where("substr(path, 1, length(?) + 1) = ? || '/'", oldPath, oldPath).
+			Update("path", gorm.Expr("? || substr(path, length(?) + 1)", tag.Path, oldPath)).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// 13.5 删除标签，有子标签时不能删除
+func deleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := db.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	var children int64
+	db.Model(&models.Tag{}).Where("parent_id = ?", tag.ID).Count(&children)
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "请先删除或移动子标签"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM question_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listResponse struct {
+	Data  []models.Question `json:"data"`
+	Total int64             `json:"total"`
+	Error string            `json:"error"`
+}
+
+// listQuestions 查询所有状态的题目，query 为额外的查询参数
+func listQuestions(t *testing.T, query string) (int, listResponse) {
+	t.Helper()
+	var resp listResponse
+	code := apiRequest(t, http.MethodGet, "/api/questions?status=all&page_size=100&"+query, "", nil, &resp)
+	return code, resp
+}
+
+func findTagByPath(t *testing.T, path string) models.Tag {
+	t.Helper()
+	var tag models.Tag
+	if err := db.Where("path = ?", path).First(&tag).Error; err != nil {
+		t.Fatalf("tag %s: %v", path, err)
+	}
+	return tag
+}
+
+func TestTagFilterIncludesDescendants(t *testing.T) {
+	resetData(t)
+	withTags := func(content string, tags ...string) gin.H {
+		q := choiceQuestion(content)
+		q["tags"] = tags
+		return q
+	}
+	createQuestion(t, "", withTags("channel 的零值是什么", "Go > 并发 > Channel"))
+	createQuestion(t, "", withTags("defer 的执行顺序", "Go/语法"))
+	createQuestion(t, "", withTags("列表推导式", "Python"))
+
+	tests := []struct {
+		tag  string
+		want int
+	}{
+		{"Go", 2},
+		{"Go/并发", 1},
+		{"Go > 并发 > Channel", 1},
+		{"Python", 1},
+	}
+	for _, tt := range tests {
+		code, resp := listQuestions(t, "tag="+url.QueryEscape(tt.tag))
+		if code != http.StatusOK || len(resp.Data) != tt.want {
+			t.Errorf("tag %q: status = %d, got %d questions, want %d", tt.tag, code, len(resp.Data), tt.want)
+		}
+	}
+
+	// 数字参数按标签ID查找
+	goTag := findTagByPath(t, "Go")
+	if _, resp := listQuestions(t, fmt.Sprintf("tag=%d", goTag.ID)); len(resp.Data) != 2 {
+		t.Errorf("tag id %d: got %d questions, want 2", goTag.ID, len(resp.Data))
+	}
+	if code, _ := listQuestions(t, "tag=Rust"); code != http.StatusBadRequest {
+		t.Errorf("unknown tag: status = %d, want 400", code)
+	}
+
+	var tree struct {
+		Data []TagNode `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, "/api/tags?tree=true", "", nil, &tree)
+	for _, root := range tree.Data {
+		if root.Path == "Go" && (root.QuestionCount != 0 || root.TotalCount != 2 || len(root.Children) != 2) {
+			t.Errorf("Go node: direct = %d, total = %d, children = %d", root.QuestionCount, root.TotalCount, len(root.Children))
+		}
+	}
+}
+
+func TestTagRenameAndMove(t *testing.T) {
+	resetData(t)
+	q := choiceQuestion("select 的用法")
+	q["tags"] = []string{"Go/并发/Channel"}
+	createQuestion(t, "", q)
+	concurrency := findTagByPath(t, "Go/并发")
+
+	var resp struct {
+		Data  models.Tag `json:"data"`
+		Error string     `json:"error"`
+	}
+	if code := apiRequest(t, http.MethodPut, fmt.Sprintf("/api/tags/%d", concurrency.ID), "", gin.H{"name": "Concurrency", "parent_id": concurrency.ParentID}, &resp); code != http.StatusOK {
+		t.Fatalf("rename: status = %d, error = %s", code, resp.Error)
+	}
+	// 子标签的路径随父标签一起更新
+	findTagByPath(t, "Go/Concurrency/Channel")
+	if _, list := listQuestions(t, "tag="+url.QueryEscape("Go/Concurrency")); len(list.Data) != 1 {
+		t.Errorf("renamed tag should still match the question, got %d", len(list.Data))
+	}
+
+	// 移动到顶层
+	if code := apiRequest(t, http.MethodPut, fmt.Sprintf("/api/tags/%d", concurrency.ID), "", gin.H{"name": "Concurrency"}, &resp); code != http.StatusOK {
+		t.Fatalf("move: status = %d, error = %s", code, resp.Error)
+	}
+	findTagByPath(t, "Concurrency/Channel")
+}
+
+func TestTagRejections(t *testing.T) {
+	resetData(t)
+	q := choiceQuestion("goroutine 泄漏")
+	q["tags"] = []string{"Go/并发"}
+	createQuestion(t, "", q)
+	goTag := findTagByPath(t, "Go")
+	child := findTagByPath(t, "Go/并发")
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   gin.H
+		want   int
+	}{
+		{"separator in name", http.MethodPost, "/api/tags", gin.H{"name": "a/b"}, http.StatusBadRequest},
+		{"blank name", http.MethodPost, "/api/tags", gin.H{"name": "  "}, http.StatusBadRequest},
+		{"missing parent", http.MethodPost, "/api/tags", gin.H{"name": "x", "parent_id": 99999}, http.StatusBadRequest},
+		{"duplicate path", http.MethodPost, "/api/tags", gin.H{"name": "并发", "parent_id": goTag.ID}, http.StatusConflict},
+		{"move under descendant", http.MethodPut, fmt.Sprintf("/api/tags/%d", goTag.ID), gin.H{"name": "Go", "parent_id": child.ID}, http.StatusBadRequest},
+		{"delete with children", http.MethodDelete, fmt.Sprintf("/api/tags/%d", goTag.ID), nil, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body interface{}
+			if tt.body != nil {
+				body = tt.body
+			}
+			if code := apiRequest(t, tt.method, tt.path, "", body, &resp); code != tt.want {
+				t.Errorf("status = %d, want %d (error = %s)", code, tt.want, resp.Error)
+			}
+		})
+	}
+
+	// 删除叶子标签后题目不再带有该标签
+	if code := apiRequest(t, http.MethodDelete, fmt.Sprintf("/api/tags/%d", child.ID), "", nil, &resp); code != http.StatusOK {
+		t.Fatalf("delete leaf: status = %d, error = %s", code, resp.Error)
+	}
+	var count int64
+	db.Table("question_tags").Where("tag_id = ?", child.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("question_tags still references deleted tag: %d rows", count)
+	}
+}
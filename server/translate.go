@@ -0,0 +1,531 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	localeRe = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+	// 代码块和行内代码在翻译前替换为占位符，翻译后原样放回
+	codeSpanRe    = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`")
+	codeMarkerRe  = regexp.MustCompile(`\[\[CODE_\d+\]\]`)
+	localeDisplay = map[string]string{
+		"zh":    "简体中文",
+		"zh-CN": "简体中文",
+		"zh-TW": "繁體中文",
+		"en":    "English",
+		"ja":    "日本語",
+		"ko":    "한국어",
+		"fr":    "Français",
+		"de":    "Deutsch",
+		"es":    "Español",
+		"ru":    "Русский",
+	}
+)
+
+type TranslationRequest struct {
+	Content     string      `json:"content" binding:"required"`
+	Options     models.JSON `json:"options"`
+	Explanation string      `json:"explanation"`
+	// Payload 结构化内容的译文，选项、空位、配对和排序条目按 ID 对应原文
+	Payload models.QuestionPayload `json:"payload"`
+}
+
+// translationPayload 发给模型和从模型取回的翻译内容，选项、空位、配对和排序条目以 ID 为键
+type translationPayload struct {
+	Content         string                    `json:"content"`
+	Options         map[string]string         `json:"options,omitempty"`
+	Blanks          map[string][]string       `json:"blanks,omitempty"`
+	AcceptedAnswers []string                  `json:"accepted_answers,omitempty"`
+	Pairs           map[string]translatedPair `json:"pairs,omitempty"`
+	Items           map[string]string         `json:"items,omitempty"`
+	Explanation     string                    `json:"explanation,omitempty"`
+}
+
+type translatedPair struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// LocalizedQuestion 按 locale 返回的题目，Translated 表示使用了译文；
+// TranslationStale 表示有译文，但原文在翻译后修改过，返回的是原文
+type LocalizedQuestion struct {
+	models.Question
+	Translated       bool `json:"translated"`
+	TranslationStale bool `json:"translation_stale,omitempty"`
+}
+
+func localeName(locale string) string {
+	if name, ok := localeDisplay[locale]; ok {
+		return name
+	}
+	return locale
+}
+
+// codeProtector 记录被替换为占位符的代码
+type codeProtector struct {
+	spans []string
+}
+
+func (p *codeProtector) protect(text string) string {
+	return codeSpanRe.ReplaceAllStringFunc(text, func(code string) string {
+		p.spans = append(p.spans, code)
+		return fmt.Sprintf("[[CODE_%d]]", len(p.spans)-1)
+	})
+}
+
+// restore 放回代码，占位符缺失或被改动时返回错误
+func (p *codeProtector) restore(text string) (string, error) {
+	var missing error
+	restored := codeMarkerRe.ReplaceAllStringFunc(text, func(marker string) string {
+		var i int
+		fmt.Sscanf(marker, "[[CODE_%d]]", &i)
+		if i < 0 || i >= len(p.spans) {
+			missing = fmt.Errorf("翻译结果中出现了未知的代码占位符 %s", marker)
+			return marker
+		}
+		return p.spans[i]
+	})
+	return restored, missing
+}
+
+// translationSource 题目中需要翻译的文字，编程题的代码和测试用例不翻译
+func translationSource(q models.Question) translationPayload {
+	source := translationPayload{Content: q.Content, Explanation: q.Explanation}
+	p := q.Payload
+	if len(p.Options) > 0 {
+		source.Options = make(map[string]string, len(p.Options))
+		for _, o := range p.Options {
+			source.Options[o.ID] = o.Text
+		}
+	}
+	if len(p.Blanks) > 0 {
+		source.Blanks = make(map[string][]string, len(p.Blanks))
+		for _, b := range p.Blanks {
+			source.Blanks[b.ID] = b.Answers
+		}
+	}
+	source.AcceptedAnswers = p.AcceptedAnswers
+	if len(p.Pairs) > 0 {
+		source.Pairs = make(map[string]translatedPair, len(p.Pairs))
+		for _, pair := range p.Pairs {
+			source.Pairs[pair.ID] = translatedPair{Left: pair.Left, Right: pair.Right}
+		}
+	}
+	if len(p.Items) > 0 {
+		source.Items = make(map[string]string, len(p.Items))
+		for _, item := range p.Items {
+			source.Items[item.ID] = item.Text
+		}
+	}
+	return source
+}
+
+// translationSourceHash 需要翻译的原文的哈希，原文修改后译文失效
+func translationSourceHash(q models.Question) string {
+	b, _ := json.Marshal(translationSource(q))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// mapStrings 对所有需要翻译的文字执行 f，用于替换和放回代码占位符
+func (t translationPayload) mapStrings(f func(string) (string, error)) (translationPayload, error) {
+	var err error
+	apply := func(s string) string {
+		if err != nil {
+			return s
+		}
+		var out string
+		out, err = f(s)
+		return out
+	}
+	out := translationPayload{Content: apply(t.Content), Explanation: apply(t.Explanation)}
+	if t.Options != nil {
+		out.Options = make(map[string]string, len(t.Options))
+		for k, v := range t.Options {
+			out.Options[k] = apply(v)
+		}
+	}
+	if t.Blanks != nil {
+		out.Blanks = make(map[string][]string, len(t.Blanks))
+		for k, answers := range t.Blanks {
+			for _, a := range answers {
+				out.Blanks[k] = append(out.Blanks[k], apply(a))
+			}
+		}
+	}
+	for _, a := range t.AcceptedAnswers {
+		out.AcceptedAnswers = append(out.AcceptedAnswers, apply(a))
+	}
+	if t.Pairs != nil {
+		out.Pairs = make(map[string]translatedPair, len(t.Pairs))
+		for k, pair := range t.Pairs {
+			out.Pairs[k] = translatedPair{Left: apply(pair.Left), Right: apply(pair.Right)}
+		}
+	}
+	if t.Items != nil {
+		out.Items = make(map[string]string, len(t.Items))
+		for k, v := range t.Items {
+			out.Items[k] = apply(v)
+		}
+	}
+	return out, err
+}
+
+// checkTranslatedKeys 译文必须包含原文的每个选项、空位、配对和排序条目
+func checkTranslatedKeys(source, translated translationPayload) error {
+	for k := range source.Options {
+		if _, ok := translated.Options[k]; !ok {
+			return fmt.Errorf("翻译结果缺少选项 %s", k)
+		}
+	}
+	for k, answers := range source.Blanks {
+		if len(translated.Blanks[k]) != len(answers) {
+			return fmt.Errorf("翻译结果中空位 %s 的答案数量不一致", k)
+		}
+	}
+	if len(translated.AcceptedAnswers) != len(source.AcceptedAnswers) {
+		return errors.New("翻译结果中可接受答案的数量不一致")
+	}
+	for k := range source.Pairs {
+		if _, ok := translated.Pairs[k]; !ok {
+			return fmt.Errorf("翻译结果缺少配对 %s", k)
+		}
+	}
+	for k := range source.Items {
+		if _, ok := translated.Items[k]; !ok {
+			return fmt.Errorf("翻译结果缺少排序条目 %s", k)
+		}
+	}
+	return nil
+}
+
+// translatedPayload 把译文按原题的顺序组装为结构化内容，只保留文字
+func translatedPayload(p models.QuestionPayload, t translationPayload) models.QuestionPayload {
+	var out models.QuestionPayload
+	for _, o := range p.Options {
+		out.Options = append(out.Options, models.ChoiceOption{ID: o.ID, Text: t.Options[o.ID]})
+	}
+	for _, b := range p.Blanks {
+		out.Blanks = append(out.Blanks, models.ClozeBlank{ID: b.ID, Answers: t.Blanks[b.ID]})
+	}
+	out.AcceptedAnswers = t.AcceptedAnswers
+	for _, pair := range p.Pairs {
+		tp := t.Pairs[pair.ID]
+		out.Pairs = append(out.Pairs, models.MatchPair{ID: pair.ID, Left: tp.Left, Right: tp.Right})
+	}
+	for _, item := range p.Items {
+		out.Items = append(out.Items, models.ChoiceOption{ID: item.ID, Text: t.Items[item.ID]})
+	}
+	return out
+}
+
+// buildTranslatePrompt 构建翻译提示词，源内容以JSON形式放在最后
+func buildTranslatePrompt(source translationPayload, locale string) string {
+	b, _ := json.MarshalIndent(source, "", "  ")
+	return fmt.Sprintf(`请把下面这道编程题目翻译成%s。
+
+要求：
+1. 只翻译自然语言部分，专业术语使用目标语言中的通用译法；
+2. 形如 [[CODE_0]] 的占位符代表代码，必须原样保留，不能翻译、修改或删除；
+3. options、blanks、pairs、items 的键保持不变，只翻译对应的内容，blanks 和 accepted_answers 中答案的数量和顺序不变；
+4. 答案中的代码、关键字、标识符和数字保持原样；
+5. 只返回与输入结构相同的JSON对象，不要包含其他文字。
+
+%s`, localeName(locale), string(b))
+}
+
+// translateQuestion 调用模型翻译题目内容、选项、结构化内容中的文字和解析，代码保持不变
+func translateQuestion(c *gin.Context, q models.Question, locale string) (*models.QuestionTranslation, error) {
+	protector := &codeProtector{}
+	source, _ := translationSource(q).mapStrings(func(s string) (string, error) {
+		return protector.protect(s), nil
+	})
+
+	result, err := aiGenerator.Generate(c.Request.Context(), GenerationRequest{
+		Task:     TaskTranslate,
+		Messages: userPrompt(buildTranslatePrompt(source, locale)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var translated translationPayload
+	value, err := parseJSON5(extractJSONContent(result.Content))
+	if err != nil {
+		return nil, fmt.Errorf("无法解析翻译结果: %w", err)
+	}
+	b, _ := json.Marshal(value)
+	if err := json.Unmarshal(b, &translated); err != nil || strings.TrimSpace(translated.Content) == "" {
+		return nil, errors.New("翻译结果缺少题目内容")
+	}
+	if err := checkTranslatedKeys(source, translated); err != nil {
+		return nil, err
+	}
+	restored, err := translated.mapStrings(protector.restore)
+	if err != nil {
+		return nil, err
+	}
+
+	// 代码块数量必须一致，防止模型删掉代码
+	all, _ := json.Marshal(restored)
+	for i, code := range protector.spans {
+		if b, _ := json.Marshal(code); !strings.Contains(string(all), strings.Trim(string(b), `"`)) {
+			return nil, fmt.Errorf("翻译结果丢失了第%d段代码", i+1)
+		}
+	}
+
+	translation := &models.QuestionTranslation{
+		QuestionID:  q.ID,
+		Locale:      locale,
+		Content:     restored.Content,
+		Explanation: restored.Explanation,
+		Payload:     translatedPayload(q.Payload, restored),
+		SourceHash:  translationSourceHash(q),
+		Source:      "ai",
+		Model:       result.Model,
+	}
+	if len(restored.Options) > 0 {
+		translation.Options = models.JSON{}
+		for k, v := range restored.Options {
+			translation.Options[k] = v
+		}
+	}
+	return translation, nil
+}
+
+// saveTranslation 保存译文，同一语言已有译文时覆盖
+func saveTranslation(translation *models.QuestionTranslation) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "question_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "options", "explanation", "payload", "source_hash", "source", "model", "updated_at"}),
+	}).Create(translation).Error
+}
+
+// localizeQuestions 用指定语言的译文替换题目内容，没有译文或原文在翻译后修改过时保留原文
+func localizeQuestions(questions []models.Question, locale string) ([]LocalizedQuestion, error) {
+	ids := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		ids = append(ids, q.ID)
+	}
+	var translations []models.QuestionTranslation
+	if len(ids) > 0 {
+		if err := db.Where("question_id IN ? AND locale = ?", ids, locale).Find(&translations).Error; err != nil {
+			return nil, err
+		}
+	}
+	byQuestion := make(map[uint]models.QuestionTranslation, len(translations))
+	for _, t := range translations {
+		byQuestion[t.QuestionID] = t
+	}
+
+	localized := make([]LocalizedQuestion, 0, len(questions))
+	for _, q := range questions {
+		item := LocalizedQuestion{Question: q}
+		t, ok := byQuestion[q.ID]
+		if ok && q.Locale != locale && t.SourceHash != translationSourceHash(q) {
+			item.TranslationStale = true
+		} else if ok && q.Locale != locale {
+			item.Content = t.Content
+			item.Payload = localizePayload(q.Payload, t)
+			if len(item.Payload.Options) > 0 {
+				item.Options = optionsJSON(item.Payload.Options)
+			}
+			if t.Explanation != "" {
+				item.Explanation = t.Explanation
+			}
+			item.Locale = locale
+			item.Translated = true
+		}
+		localized = append(localized, item)
+	}
+	return localized, nil
+}
+
+// localizePayload 按 ID 把选项、空位答案、配对和排序条目替换为译文，不修改原题目；
+// 可接受答案数量一致时按顺序替换。旧的译文只有 options，选项按它替换
+func localizePayload(p models.QuestionPayload, t models.QuestionTranslation) models.QuestionPayload {
+	options := make(map[string]string)
+	for k, v := range t.Options {
+		if text, ok := v.(string); ok {
+			options[k] = text
+		}
+	}
+	for _, o := range t.Payload.Options {
+		options[o.ID] = o.Text
+	}
+	p.Options = localizeOptionList(p.Options, options)
+
+	items := make(map[string]string, len(t.Payload.Items))
+	for _, item := range t.Payload.Items {
+		items[item.ID] = item.Text
+	}
+	p.Items = localizeOptionList(p.Items, items)
+
+	if len(p.Blanks) > 0 {
+		blanks := make(map[string][]string, len(t.Payload.Blanks))
+		for _, b := range t.Payload.Blanks {
+			blanks[b.ID] = b.Answers
+		}
+		localized := make([]models.ClozeBlank, len(p.Blanks))
+		for i, b := range p.Blanks {
+			localized[i] = b
+			if answers := blanks[b.ID]; len(answers) > 0 {
+				localized[i].Answers = answers
+			}
+		}
+		p.Blanks = localized
+	}
+	if len(p.Pairs) > 0 {
+		pairs := make(map[string]models.MatchPair, len(t.Payload.Pairs))
+		for _, pair := range t.Payload.Pairs {
+			pairs[pair.ID] = pair
+		}
+		localized := make([]models.MatchPair, len(p.Pairs))
+		for i, pair := range p.Pairs {
+			localized[i] = pair
+			if tp, ok := pairs[pair.ID]; ok {
+				if tp.Left != "" {
+					localized[i].Left = tp.Left
+				}
+				if tp.Right != "" {
+					localized[i].Right = tp.Right
+				}
+			}
+		}
+		p.Pairs = localized
+	}
+	if len(t.Payload.AcceptedAnswers) > 0 && len(t.Payload.AcceptedAnswers) == len(p.AcceptedAnswers) {
+		p.AcceptedAnswers = t.Payload.AcceptedAnswers
+	}
+	return p
+}
+
+// localizeOptionList 按选项ID替换为译文，不修改原题目的选项
+func localizeOptionList(options []models.ChoiceOption, translated map[string]string) []models.ChoiceOption {
+	if len(options) == 0 {
+		return options
+	}
+	localized := make([]models.ChoiceOption, len(options))
+	for i, o := range options {
+		localized[i] = o
+		if text := translated[o.ID]; text != "" {
+			localized[i].Text = text
+		}
+	}
+	return localized
+}
+
+// 11.1 AI翻译题目
+func translateQuestionHandler(c *gin.Context) {
+	locale := c.Query("to")
+	if !localeRe.MatchString(locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "目标语言无效，应为 en、zh-TW 这样的语言代码"})
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if question.Locale == locale {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目原文已经是该语言"})
+		return
+	}
+
+	translation, err := translateQuestion(c, question, locale)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Printf("Translate question %d to %s error: %v", question.ID, locale, err)
+		return
+	}
+	if err := saveTranslation(translation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": translation})
+}
+
+// 11.2 题目的所有译文，原文在翻译后修改过的译文标记为 stale
+func getQuestionTranslations(c *gin.Context) {
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	var translations []models.QuestionTranslation
+	if err := db.Where("question_id = ?", question.ID).Order("locale").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hash := translationSourceHash(question)
+	for i := range translations {
+		translations[i].Stale = translations[i].SourceHash != hash
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": translations})
+}
+
+// 11.3 人工新增或修改译文
+func saveQuestionTranslation(c *gin.Context) {
+	locale := c.Param("locale")
+	if !localeRe.MatchString(locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "语言代码无效"})
+		return
+	}
+
+	var req TranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	translation := models.QuestionTranslation{
+		QuestionID:  question.ID,
+		Locale:      locale,
+		Content:     req.Content,
+		Options:     req.Options,
+		Explanation: req.Explanation,
+		Payload:     req.Payload,
+		SourceHash:  translationSourceHash(question),
+		Source:      "manual",
+	}
+	if err := saveTranslation(&translation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": translation})
+}
+
+// 11.4 删除译文
+func deleteQuestionTranslation(c *gin.Context) {
+	if err := db.Where("question_id = ? AND locale = ?", c.Param("id"), c.Param("locale")).
+		Delete(&models.QuestionTranslation{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Translation deleted successfully"})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type localizedResponse struct {
+	Data []LocalizedQuestion `json:"data"`
+}
+
+// localizedQuestion 按 locale 列出所有状态的题目，返回指定的一道
+func localizedQuestion(t *testing.T, id uint, locale string) LocalizedQuestion {
+	t.Helper()
+	var resp localizedResponse
+	apiRequest(t, http.MethodGet, "/api/questions?status=all&page_size=100&locale="+locale, "", nil, &resp)
+	for _, q := range resp.Data {
+		if q.ID == id {
+			return q
+		}
+	}
+	t.Fatalf("question %d not listed", id)
+	return LocalizedQuestion{}
+}
+
+func TestTranslatePayload(t *testing.T) {
+	resetData(t)
+	useFixture(t, "translate_payload")
+	matching := createQuestion(t, "alice", gin.H{"type": "matching", "content": "把类型和零值连起来", "difficulty": "easy", "language": "Go",
+		"payload": gin.H{"pairs": []gin.H{{"id": "1", "left": "整数", "right": "零"}, {"id": "2", "left": "字符串", "right": "空字符串"}}}})
+	short := createQuestion(t, "alice", gin.H{"type": "short_answer", "content": "Go 中的 GC 指什么", "difficulty": "easy", "language": "Go",
+		"payload": gin.H{"accepted_answers": []string{"垃圾回收"}}})
+
+	for _, id := range []uint{matching.ID, short.ID} {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/questions/%d/translate?to=en", id), "", nil, &resp); code != http.StatusOK {
+			t.Fatalf("translate %d: status = %d (%s)", id, code, resp.Error)
+		}
+	}
+	// 源内容中的配对和可接受答案都发给了模型
+	if reqs := testStub.Requests(); len(reqs) != 2 || !strings.Contains(reqs[0].Messages[0].Content, "空字符串") ||
+		!strings.Contains(reqs[1].Messages[0].Content, "垃圾回收") {
+		t.Errorf("translate prompts do not include the payload")
+	}
+
+	q := localizedQuestion(t, matching.ID, "en")
+	if !q.Translated || q.Content != "Match each type with its zero value" || q.Payload.Pairs[1].Left != "string" || q.Payload.Pairs[1].Right != "empty string" {
+		t.Errorf("localized matching = %+v", q)
+	}
+	q = localizedQuestion(t, short.ID, "en")
+	if !q.Translated || len(q.Payload.AcceptedAnswers) != 1 || q.Payload.AcceptedAnswers[0] != "garbage collection" {
+		t.Errorf("localized short answer = %+v", q.Payload)
+	}
+
+	// 判分仍然使用原文
+	var saved models.Question
+	db.First(&saved, short.ID)
+	if saved.Payload.AcceptedAnswers[0] != "垃圾回收" {
+		t.Errorf("stored answers changed: %v", saved.Payload.AcceptedAnswers)
+	}
+
+	// 译文缺少配对时不保存
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/questions/%d/translate?to=fr", matching.ID), "", nil, &resp); code != http.StatusInternalServerError ||
+		!strings.Contains(resp.Error, "缺少配对 2") {
+		t.Errorf("missing pair: status = %d, error = %q", code, resp.Error)
+	}
+}
+
+func TestStaleTranslation(t *testing.T) {
+	resetData(t)
+	body := choiceQuestion("channel 的零值")
+	q := createQuestion(t, "alice", body)
+	path := fmt.Sprintf("/api/questions/%d/translations/en", q.ID)
+	translation := gin.H{"content": "The zero value of a channel", "payload": gin.H{"options": []gin.H{{"id": "A", "text": "nil"}}}}
+	if code := apiRequest(t, http.MethodPut, path, "", translation, nil); code != http.StatusOK {
+		t.Fatalf("save translation: status = %d", code)
+	}
+	if got := localizedQuestion(t, q.ID, "en"); !got.Translated || got.Payload.Options[0].Text != "nil" || got.Options["A"] != "nil" {
+		t.Fatalf("localized = %+v", got)
+	}
+
+	// 修改原文后不再使用译文
+	body["content"] = "channel 的零值是什么"
+	if code := apiRequest(t, http.MethodPut, fmt.Sprintf("/api/questions/%d", q.ID), "alice", body, nil); code != http.StatusOK {
+		t.Fatalf("edit: status = %d", code)
+	}
+	got := localizedQuestion(t, q.ID, "en")
+	if got.Translated || !got.TranslationStale || got.Content != "channel 的零值是什么" {
+		t.Errorf("after edit: translated = %v, stale = %v, content = %q", got.Translated, got.TranslationStale, got.Content)
+	}
+	var list struct {
+		Data []models.QuestionTranslation `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, fmt.Sprintf("/api/questions/%d/translations", q.ID), "", nil, &list)
+	if len(list.Data) != 1 || !list.Data[0].Stale {
+		t.Errorf("translations = %+v, want one stale", list.Data)
+	}
+
+	// 重新保存译文后恢复使用
+	translation["content"] = "What is the zero value of a channel"
+	apiRequest(t, http.MethodPut, path, "", translation, nil)
+	if got := localizedQuestion(t, q.ID, "en"); !got.Translated || got.TranslationStale {
+		t.Errorf("after retranslation: translated = %v, stale = %v", got.Translated, got.TranslationStale)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashRetention 回收站中题目的保留时间，超过后自动彻底删除，为 0 时不自动清理
+var trashRetention = 30 * 24 * time.Hour
+
+// trashPurgeInterval 自动清理的检查间隔
+const trashPurgeInterval = time.Hour
+
+// questionRelatedModels 彻底删除题目时一并删除的关联数据，都以 question_id 关联到题目
+var questionRelatedModels = []interface{}{
+	&models.QuestionFingerprint{},
+	&models.QuestionSimilarityBand{},
+	&models.QuestionExplanation{},
+	&models.QuestionVerification{},
+	&models.ClassificationSuggestion{},
+	&models.QuestionTranslation{},
+	&models.QuestionBankItem{},
+	&models.QuestionRevision{},
+	&models.QuestionReview{},
+}
+
+// TrashRequest 恢复或彻底删除回收站中的题目，IDs 和 All 至少指定一个
+type TrashRequest struct {
+	IDs []uint `json:"ids"`
+	// All 对回收站中的全部题目操作
+	All bool `json:"all"`
+}
+
+// TrashItem 回收站中的题目，PurgeAt 为自动彻底删除的时间
+type TrashItem struct {
+	models.Question
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"`
+}
+
+// loadTrashRetention 从 TRASH_RETENTION_DAYS 读取保留天数
+func loadTrashRetention() {
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			trashRetention = time.Duration(n) * 24 * time.Hour
+		} else {
+			log.Printf("Warning: invalid TRASH_RETENTION_DAYS %q, using %d", v, int(trashRetention.Hours()/24))
+		}
+	}
+}
+
+// startTrashPurger 定期彻底删除超过保留时间的题目
+func startTrashPurger() {
+	if trashRetention == 0 {
+		log.Println("Trash retention disabled, deleted questions are kept until purged manually")
+		return
+	}
+	go func() {
+		for {
+			purgeExpiredTrash()
+			time.Sleep(trashPurgeInterval)
+		}
+	}()
+}
+
+// purgeExpiredTrash 彻底删除超过保留时间的题目
+func purgeExpiredTrash() {
+	var ids []uint
+	cutoff := time.Now().Add(-trashRetention)
+	if err := db.Unscoped().Model(&models.Question{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &ids).Error; err != nil {
+		log.Printf("Failed to load expired trash: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := purgeQuestions(ids); err != nil {
+		log.Printf("Failed to purge expired trash: %v", err)
+		return
+	}
+	log.Printf("Purged %d questions deleted before %s", len(ids), cutoff.Format(time.RFC3339))
+}
+
+// purgeQuestions 彻底删除题目及其关联数据，由这些题目生成的变体保留，只清空 parent_id
+func purgeQuestions(ids []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := reassignVariantGroups(tx, ids); err != nil {
+			return err
+		}
+		for _, m := range questionRelatedModels {
+			if err := tx.Where("question_id IN ?", ids).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM question_tags WHERE question_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Question{}).Where("parent_id IN ?", ids).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Question{}, ids).Error
+	})
+}
+
+// reassignVariantGroups 整理要彻底删除的题目所在的变体分组：分组改为剩余题目中ID最小的一道，
+// 只剩一道题目时清空分组。回收站中的题目仍然算作分组的成员
+func reassignVariantGroups(tx *gorm.DB, ids []uint) error {
+	var groups []uint
+	if err := tx.Unscoped().Model(&models.Question{}).Where("id IN ? AND variant_group IS NOT NULL", ids).
+		Distinct().Pluck("variant_group", &groups).Error; err != nil {
+		return err
+	}
+	for _, group := range groups {
+		var members []uint
+		if err := tx.Unscoped().Model(&models.Question{}).Where("variant_group = ? AND id NOT IN ?", group, ids).
+			Order("id").Pluck("id", &members).Error; err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			continue
+		}
+		var next interface{}
+		if len(members) > 1 {
+			next = members[0]
+		}
+		if err := tx.Unscoped().Model(&models.Question{}).Where("id IN ?", members).Update("variant_group", next).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindTrashRequest 解析请求并返回回收站中要处理的题目ID，不在回收站中的题目忽略
+func bindTrashRequest(c *gin.Context) ([]uint, bool) {
+	var req TrashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return nil, false
+	}
+	if len(req.IDs) == 0 && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定 ids 或 all"})
+		return nil, false
+	}
+
+	query := db.Unscoped().Model(&models.Question{}).Where("deleted_at IS NOT NULL")
+	if !req.All {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	ids := []uint{}
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return ids, true
+}
+
+// 16.1 回收站列表，最近删除的在前
+func getTrash(c *gin.Context) {
+	var pagination Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		pagination.Page = 1
+		pagination.PageSize = 10
+	}
+
+	query := db.Unscoped().Model(&models.Question{}).Where("deleted_at IS NOT NULL")
+	if typeStr := c.Query("type"); typeStr != "" {
+		query = query.Where("type = ?", typeStr)
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("content LIKE ?", "%"+keyword+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var questions []models.Question
+	if err := query.Preload("Tags").Order("deleted_at DESC").Offset((pagination.Page - 1) * pagination.PageSize).Limit(pagination.PageSize).Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	redactQuestions(c, questions)
+
+	items := make([]TrashItem, len(questions))
+	for i, q := range questions {
+		items[i] = TrashItem{Question: q, DeletedAt: q.DeletedAt.Time}
+		if trashRetention > 0 {
+			purgeAt := q.DeletedAt.Time.Add(trashRetention)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  items,
+		"total": total,
+		"page":  pagination.Page,
+		"size":  pagination.PageSize,
+	})
+}
+
+// 16.2 从回收站恢复题目，恢复记录为题目的一个新版本
+func restoreTrash(c *gin.Context) {
+	ids, ok := bindTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	if len(ids) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Model(&models.Question{}).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			return recordQuestionRevisions(tx, ids, models.RevisionRestore, requestUser(c))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"restored": ids})
+}
+
+// 16.3 彻底删除回收站中的题目，修订历史等关联数据一并删除，不能恢复
+func purgeTrash(c *gin.Context) {
+	ids, ok := bindTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	if len(ids) > 0 {
+		if err := purgeQuestions(ids); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Purged %d questions from trash", len(ids))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": ids})
+}
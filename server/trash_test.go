@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashQuestions 新建题目并移入回收站
+func trashQuestions(t *testing.T, contents ...string) []uint {
+	t.Helper()
+	ids := make([]uint, len(contents))
+	for i, content := range contents {
+		ids[i] = createQuestion(t, "", choiceQuestion(content)).ID
+		if code := apiRequest(t, http.MethodDelete, fmt.Sprintf("/api/questions/%d", ids[i]), "", nil, nil); code != http.StatusOK {
+			t.Fatalf("delete question %d: status = %d", ids[i], code)
+		}
+	}
+	return ids
+}
+
+func questionExists(t *testing.T, id uint) bool {
+	t.Helper()
+	var count int64
+	db.Unscoped().Model(&models.Question{}).Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	resetData(t)
+	ids := trashQuestions(t, "sync.WaitGroup 的用法", "sync.Once 的用法")
+
+	var trash struct {
+		Data  []TrashItem `json:"data"`
+		Total int64       `json:"total"`
+	}
+	apiRequest(t, http.MethodGet, "/api/trash", "", nil, &trash)
+	if trash.Total != 2 || trash.Data[0].PurgeAt == nil {
+		t.Fatalf("trash total = %d, want 2 with purge time", trash.Total)
+	}
+	if _, resp := listQuestions(t, ""); len(resp.Data) != 0 {
+		t.Errorf("listing shows %d deleted questions", len(resp.Data))
+	}
+
+	var restored struct {
+		Restored []uint `json:"restored"`
+	}
+	apiRequest(t, http.MethodPost, "/api/trash/restore", "alice", gin.H{"ids": []uint{ids[0], 99999}}, &restored)
+	if len(restored.Restored) != 1 || restored.Restored[0] != ids[0] {
+		t.Errorf("restored = %v, want [%d]", restored.Restored, ids[0])
+	}
+	if _, resp := listQuestions(t, ""); len(resp.Data) != 1 || resp.Data[0].ID != ids[0] {
+		t.Errorf("listing after restore = %d questions", len(resp.Data))
+	}
+	var rev models.QuestionRevision
+	db.Where("question_id = ?", ids[0]).Order("revision DESC").First(&rev)
+	if rev.Action != models.RevisionRestore || rev.Author != "alice" {
+		t.Errorf("latest revision = %s by %q, want restore by alice", rev.Action, rev.Author)
+	}
+
+	// 未删除的题目不能从回收站彻底删除
+	var purged struct {
+		Purged []uint `json:"purged"`
+	}
+	apiRequest(t, http.MethodDelete, "/api/trash", "", gin.H{"ids": ids}, &purged)
+	if len(purged.Purged) != 1 || purged.Purged[0] != ids[1] {
+		t.Errorf("purged = %v, want [%d]", purged.Purged, ids[1])
+	}
+	if !questionExists(t, ids[0]) || questionExists(t, ids[1]) {
+		t.Errorf("after purge: restored exists = %v, purged exists = %v", questionExists(t, ids[0]), questionExists(t, ids[1]))
+	}
+	var revisions int64
+	db.Model(&models.QuestionRevision{}).Where("question_id = ?", ids[1]).Count(&revisions)
+	if revisions != 0 {
+		t.Errorf("purged question still has %d revisions", revisions)
+	}
+
+	if code := apiRequest(t, http.MethodDelete, "/api/trash", "", gin.H{}, nil); code != http.StatusBadRequest {
+		t.Errorf("purge without ids: status = %d, want 400", code)
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	resetData(t)
+	defer func(d time.Duration) { trashRetention = d }(trashRetention)
+	trashRetention = time.Hour
+
+	ids := trashQuestions(t, "context 的取消传播", "context 的超时设置")
+	db.Unscoped().Model(&models.Question{}).Where("id = ?", ids[0]).Update("deleted_at", time.Now().Add(-2*time.Hour))
+
+	purgeExpiredTrash()
+	if questionExists(t, ids[0]) {
+		t.Error("question deleted before the retention period was not purged")
+	}
+	if !questionExists(t, ids[1]) {
+		t.Error("question still within the retention period was purged")
+	}
+}
+
+func TestPurgeReassignsVariantGroup(t *testing.T) {
+	resetData(t)
+	var ids []uint
+	for _, content := range []string{"原题: 1+1", "变体: 2+2", "变体: 3+3"} {
+		ids = append(ids, createQuestion(t, "", choiceQuestion(content)).ID)
+	}
+	db.Model(&models.Question{}).Where("id IN ?", ids).Update("variant_group", ids[0])
+	db.Model(&models.Question{}).Where("id IN ?", ids[1:]).Update("parent_id", ids[0])
+
+	groupOf := func(id uint) *uint {
+		var q models.Question
+		db.Unscoped().First(&q, id)
+		return q.VariantGroup
+	}
+
+	// 原题删除后分组改为剩余题目中ID最小的一道
+	if err := purgeQuestions(ids[:1]); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:] {
+		if g := groupOf(id); g == nil || *g != ids[1] {
+			t.Errorf("question %d: variant_group = %v, want %d", id, g, ids[1])
+		}
+	}
+	var variants struct {
+		Data []models.Question `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, fmt.Sprintf("/api/questions/%d/variants", ids[2]), "", nil, &variants)
+	if len(variants.Data) != 2 {
+		t.Errorf("variants of %d = %d questions, want 2", ids[2], len(variants.Data))
+	}
+
+	// 只剩一道题目时清空分组
+	if err := purgeQuestions(ids[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if g := groupOf(ids[2]); g != nil {
+		t.Errorf("last question: variant_group = %d, want nil", *g)
+	}
+}
+
+func TestTrashHidesSolutions(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", programmingQuestion("实现两数相加"))
+	if code := apiRequest(t, http.MethodDelete, fmt.Sprintf("/api/questions/%d", q.ID), "", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status = %d", code)
+	}
+	assertLearnerView(t, "/api/trash")
+}
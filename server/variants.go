@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type VariantRequest struct {
+	Count int `json:"count" binding:"required,min=1,max=10"`
+	// Verify 是否校验变体选择题的答案，不传时由 AI_VERIFY 决定
+	Verify *bool `json:"verify"`
+}
+
+// variantGroupOf 题目所在的变体分组，还没有变体的题目以自身ID作为分组
+func variantGroupOf(q models.Question) uint {
+	if q.VariantGroup != nil {
+		return *q.VariantGroup
+	}
+	return q.ID
+}
+
+// buildVariantPrompt 构建变体提示词，要求保持考点和难度不变，只替换具体的数字和标识符；
+// 示例和题型要求与 buildAIPrompt 一样取自题型定义
+func buildVariantPrompt(q models.Question, spec AIGenerateRequest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "请根据下面这道%s题目，生成%d道同构的变体题目。\n\n原题：\n", q.Language, spec.Count)
+	writeQuestionDetails(&sb, q)
+
+	fmt.Fprintf(&sb, `
+要求：
+1. 考查的知识点和解题思路与原题相同，难度保持为%s，编程语言保持为%s，题型保持为%s；
+2. 更换题目中的数字、变量名、函数名、数据和场景等具体内容，并重新计算答案；
+3. 每道变体都必须与原题不同，变体之间也不能相同；
+4. 选项、空位、配对、排序条目、测试用例等题型特有的内容同样需要随题目内容调整，答案必须与新的题目内容一致。%s
+
+请严格按照以下JSON数组格式返回，不要包含任何其他文字：
+[
+%s
+]
+
+具体要求：
+%s`,
+		q.Difficulty, q.Language, q.Type, variantOptionRequirement(spec), promptExample(spec, string(q.Type), string(q.Difficulty)), typePromptRequirements(q.Type))
+
+	if schema := describeSchemaForPrompt(spec); schema != "" {
+		sb.WriteString("\n\n每道题目必须符合以下JSON Schema：\n" + schema)
+	}
+	sb.WriteString(structuredOutputHint())
+	return sb.String()
+}
+
+// variantOptionRequirement 选择题的变体沿用原题的选项 ID 和选项数量，优先于题型要求中的 A、B、C、D
+func variantOptionRequirement(spec AIGenerateRequest) string {
+	if len(spec.OptionIDs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n5. options 使用与原题相同的%d个选项ID：%s，答案也用这些ID表示，不要增减选项，以此条为准。",
+		len(spec.OptionIDs), strings.Join(spec.OptionIDs, "、"))
+}
+
+// 12.1 AI生成题目变体，保存后与原题归入同一变体分组
+func createQuestionVariants(c *gin.Context) {
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var parent models.Question
+	if err := db.Preload("Tags").First(&parent, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	// 变体与原题的题型、难度、编程语言一致，由 schema 校验保证
+	spec := AIGenerateRequest{
+		Type:       parent.Type,
+		Count:      req.Count,
+		Difficulty: parent.Difficulty,
+		Language:   parent.Language,
+		Topic:      fmt.Sprintf("题目#%d的变体", parent.ID),
+		Verify:     req.Verify,
+	}
+	if isChoiceQuestion(parent.Type) {
+		for _, o := range parent.Payload.Options {
+			spec.OptionIDs = append(spec.OptionIDs, o.ID)
+		}
+	}
+
+	generation, err := generateWithRepair(c.Request.Context(), spec, buildVariantPrompt(parent, spec))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务调用失败: " + err.Error()})
+		log.Printf("Generate variants for question %d error: %v", parent.ID, err)
+		return
+	}
+
+	generated, warnings := finalizeGeneratedQuestions(generation.Questions, generation.Invalid, spec, generation.ParseErr, false)
+	warnings = append(generation.Notes, warnings...)
+	warnings = append(warnings, flagDuplicateQuestions(generated)...)
+	setGenerationSource(generated, generation.Result.Model, parent.Topic, nil)
+	if spec.verify() {
+		warnings = append(warnings, verifyGeneratedQuestions(c.Request.Context(), generated)...)
+	}
+
+	// 与题库中已有题目（包括原题）完全相同的变体不保存
+	group := variantGroupOf(parent)
+	variants := make([]models.Question, 0, len(generated))
+	for i, g := range generated {
+		if hasExactDuplicate(g.Duplicates) {
+			warnings = append(warnings, fmt.Sprintf("第%d道变体与题库中的题目完全相同，已丢弃", i+1))
+			continue
+		}
+		variant := newQuestion(g.QuestionRequest, requestUser(c))
+		variant.Tags = parent.Tags
+		variant.Locale = parent.Locale
+		variant.ParentID = &parent.ID
+		variant.VariantGroup = &group
+		variants = append(variants, variant)
+	}
+	if len(variants) == 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "AI未生成可用的变体", "warnings": warnings, "invalid": generation.Invalid})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if parent.VariantGroup == nil {
+			if err := tx.Model(&parent).Update("variant_group", group).Error; err != nil {
+				return err
+			}
+		}
+		for i := range variants {
+			if err := tx.Create(&variants[i]).Error; err != nil {
+				return err
+			}
+			if err := saveQuestionFingerprint(tx, &variants[i]); err != nil {
+				return err
+			}
+			if err := recordQuestionRevision(tx, variants[i].ID, models.RevisionCreate, requestUser(c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库错误: " + err.Error()})
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	log.Printf("Created %d variants for question %d", len(variants), parent.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": variants, "group": group, "warnings": warnings, "invalid": generation.Invalid})
+}
+
+// 12.2 题目所在分组的原题和全部变体，view=author 时返回编程题的参考答案和隐藏用例
+func getQuestionVariants(c *gin.Context) {
+	var question models.Question
+	if err := db.First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+
+	group := variantGroupOf(question)
+	var questions []models.Question
+	if err := db.Preload("Tags").Where("id = ? OR variant_group = ?", group, group).Order("id").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	redactQuestions(c, questions)
+
+	c.JSON(http.StatusOK, gin.H{"data": questions, "group": group})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVariantPromptUsesTypeSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       models.Question
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "matching",
+			q: models.Question{Type: models.Matching, Content: "把类型和零值连起来", Difficulty: models.Easy, Language: "Go",
+				Payload: models.QuestionPayload{Pairs: []models.MatchPair{{ID: "1", Left: "int", Right: "0"}, {ID: "2", Left: "string", Right: `""`}}}},
+			want:    []string{"正确配对：\nint → 0\n", `"pairs"`, "连线题(type为matching)", "至少4组"},
+			notWant: []string{`"options": {"A"`},
+		},
+		{
+			name: "cloze",
+			q: models.Question{Type: models.Cloze, Content: "声明常量使用 {{1}} 关键字", Difficulty: models.Easy, Language: "Go",
+				Payload: models.QuestionPayload{Blanks: []models.ClozeBlank{{ID: "1", Answers: []string{"const", "CONST"}}}}},
+			want: []string{"{{1}}：const / CONST", `"blanks"`, "{{1}}、{{2}}"},
+		},
+		{
+			name: "true false",
+			q: models.Question{Type: models.TrueFalse, Content: "nil map 可以读取", Difficulty: models.Easy, Language: "Go",
+				Payload: models.QuestionPayload{Correct: boolPtr(true)}},
+			want: []string{"正确答案：该陈述正确", `"correct": true`},
+		},
+		{
+			name: "programming",
+			q: models.Question{Type: models.Programming, Content: "实现 Add", Difficulty: models.Medium, Language: "Go",
+				Payload: models.QuestionPayload{
+					Templates: []models.CodeTemplate{{Language: "Go", Starter: "func Add(a, b int) int", Solution: "return a + b"}},
+					TestCases: []models.TestCase{
+						{ID: "1", Kind: models.TestCaseFunction, Function: "Add", Args: []interface{}{1, 2}, Expected: 3},
+						{ID: "2", Kind: models.TestCaseStdio, Input: "1 2", Output: "3", Hidden: true},
+					},
+					Limits: &models.ExecutionLimits{TimeMs: 1000, MemoryMB: 256},
+				}},
+			want: []string{"起始代码（Go）", "参考答案（Go）", "1. Add(1,2) 返回 3", `2. 输入："1 2" 输出："3"（隐藏）`,
+				"运行限制：时间 1000 毫秒", `"test_cases"`, "payload.limits"},
+		},
+	}
+	for _, tt := range tests {
+		spec := AIGenerateRequest{Type: tt.q.Type, Count: 2, Difficulty: tt.q.Difficulty, Language: tt.q.Language}
+		prompt := buildVariantPrompt(tt.q, spec)
+		for _, s := range tt.want {
+			if !strings.Contains(prompt, s) {
+				t.Errorf("%s: prompt missing %q", tt.name, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(prompt, s) {
+				t.Errorf("%s: prompt contains %q", tt.name, s)
+			}
+		}
+	}
+}
+
+func TestVariantsKeepParentOptionIDs(t *testing.T) {
+	resetData(t)
+	useFixture(t, "variant_option_ids")
+	parent := createQuestion(t, "alice", gin.H{"type": "multiple_choice", "content": "下列哪些类型是引用类型？", "difficulty": "easy", "language": "Go",
+		"payload": gin.H{
+			"options": []gin.H{{"id": "opt1", "text": "slice"}, {"id": "opt2", "text": "string"}, {"id": "opt3", "text": "map"}},
+			"answers": []string{"opt1", "opt3"},
+		}})
+
+	var resp struct {
+		Data     []models.Question `json:"data"`
+		Warnings []string          `json:"warnings"`
+		Error    string            `json:"error"`
+	}
+	path := fmt.Sprintf("/api/questions/%d/variants", parent.ID)
+	if code := apiRequest(t, http.MethodPost, path, "alice", gin.H{"count": 1, "verify": false}, &resp); code != http.StatusCreated {
+		t.Fatalf("variants: status = %d (%s, %v)", code, resp.Error, resp.Warnings)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Answer != "opt1,opt3" || len(resp.Data[0].Payload.Options) != 3 {
+		t.Errorf("variants = %+v, want one with answer opt1,opt3", resp.Data)
+	}
+
+	// schema 按原题的选项 ID 生成，其他 ID 不能通过校验
+	spec := AIGenerateRequest{Type: models.MultipleChoice, OptionIDs: []string{"opt1", "opt2", "opt3"}}
+	item := map[string]interface{}{"type": "multiple_choice", "content": "x",
+		"options": map[string]interface{}{"A": "a", "B": "b", "C": "c", "D": "d"}, "answer": "A"}
+	if errs := validateQuestionItem(item, spec); len(errs) == 0 {
+		t.Error("A-D options passed the schema of a question with opt1-opt3")
+	}
+	prompt := buildVariantPrompt(parent, spec)
+	if !strings.Contains(prompt, "3个选项ID：opt1、opt2、opt3") || !strings.Contains(prompt, `"opt3"`) {
+		t.Errorf("prompt does not ask for the parent's option IDs:\n%s", prompt)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
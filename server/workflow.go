@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// QuestionTransition 一个状态流转操作
+type QuestionTransition struct {
+	From []models.QuestionStatus
+	To   models.QuestionStatus
+	// Review 是否为审核操作，审核人必须指定，且不能是题目的创建者或上次审核通过后修改过题目的人
+	Review bool
+	// RequireComment 是否必须填写审核意见
+	RequireComment bool
+}
+
+// questionTransitions 题目审核流程：草稿 → 审核中 → 审核通过 → 已发布，任何状态都可以归档
+var questionTransitions = map[string]QuestionTransition{
+	"submit":  {From: []models.QuestionStatus{models.StatusDraft}, To: models.StatusInReview},
+	"approve": {From: []models.QuestionStatus{models.StatusInReview}, To: models.StatusApproved, Review: true},
+	"reject":  {From: []models.QuestionStatus{models.StatusInReview}, To: models.StatusDraft, Review: true, RequireComment: true},
+	"publish": {From: []models.QuestionStatus{models.StatusApproved}, To: models.StatusPublished},
+	"archive": {From: []models.QuestionStatus{models.StatusDraft, models.StatusInReview, models.StatusApproved, models.StatusPublished}, To: models.StatusArchived},
+	"reopen":  {From: []models.QuestionStatus{models.StatusPublished, models.StatusArchived}, To: models.StatusDraft},
+}
+
+var questionStatuses = []models.QuestionStatus{models.StatusDraft, models.StatusInReview, models.StatusApproved, models.StatusPublished, models.StatusArchived}
+
+var errStatusChanged = errors.New("题目状态已被其他人修改，请刷新后重试")
+
+// TransitionRequest 状态流转的请求，Comment 为审核意见
+type TransitionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// parseStatusFilter 解析查询参数 status：不传时只返回已发布的题目，all 表示不限状态，多个状态用逗号分隔
+func parseStatusFilter(raw string) ([]models.QuestionStatus, error) {
+	if raw == "" {
+		return []models.QuestionStatus{models.StatusPublished}, nil
+	}
+	if raw == "all" {
+		return nil, nil
+	}
+	var statuses []models.QuestionStatus
+	for _, s := range strings.Split(raw, ",") {
+		status := models.QuestionStatus(strings.TrimSpace(s))
+		if !containsStatus(questionStatuses, status) {
+			return nil, errors.New("不支持的题目状态: " + string(status))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func containsStatus(statuses []models.QuestionStatus, status models.QuestionStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// 17.1 提交审核
+func submitQuestion(c *gin.Context) {
+	transitionQuestion(c, "submit")
+}
+
+// 17.2 审核通过
+func approveQuestion(c *gin.Context) {
+	transitionQuestion(c, "approve")
+}
+
+// 17.3 审核不通过，退回草稿，必须填写审核意见
+func rejectQuestion(c *gin.Context) {
+	transitionQuestion(c, "reject")
+}
+
+// 17.4 发布
+func publishQuestion(c *gin.Context) {
+	transitionQuestion(c, "publish")
+}
+
+// 17.5 归档
+func archiveQuestion(c *gin.Context) {
+	transitionQuestion(c, "archive")
+}
+
+// 17.6 重新打开，已发布或已归档的题目退回草稿
+func reopenQuestion(c *gin.Context) {
+	transitionQuestion(c, "reopen")
+}
+
+// transitionQuestion 校验当前状态和审核人后执行状态流转，并记录流转历史
+func transitionQuestion(c *gin.Context, action string) {
+	var req TransitionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+	}
+	t := questionTransitions[action]
+	comment := strings.TrimSpace(req.Comment)
+	if t.RequireComment && comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写审核意见"})
+		return
+	}
+
+	var question models.Question
+	if err := db.Preload("Tags").First(&question, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if !containsStatus(t.From, question.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "题目当前状态为 " + string(question.Status) + "，不能执行 " + action})
+		return
+	}
+	reviewer := requestUser(c)
+	if t.Review {
+		if reviewer == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请在请求头 X-User 中指定审核人"})
+			return
+		}
+		if question.Author == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "题目没有记录创建者，修改题目后才能审核"})
+			return
+		}
+		if reviewer == question.Author {
+			c.JSON(http.StatusForbidden, gin.H{"error": "审核人不能是题目的创建者"})
+			return
+		}
+		editors, err := questionEditors(question.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if containsString(editors, reviewer) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "审核人不能是上次审核通过后修改过题目的人"})
+			return
+		}
+	}
+
+	review := models.QuestionReview{
+		QuestionID: question.ID,
+		Action:     action,
+		FromStatus: question.Status,
+		ToStatus:   t.To,
+		Reviewer:   reviewer,
+		Comment:    comment,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 按原状态更新，避免并发流转时覆盖其他人的操作
+		result := tx.Model(&models.Question{}).Where("id = ? AND status = ?", question.ID, question.Status).Update("status", t.To)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStatusChanged
+		}
+		return tx.Create(&review).Error
+	})
+	if errors.Is(err, errStatusChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	question.Status = t.To
+
+	c.JSON(http.StatusOK, gin.H{"data": question, "review": review})
+}
+
+// questionEditors 上次审核通过之后修改过题目的人，从修订记录中查找；从未审核通过时为所有修改过题目的人
+func questionEditors(questionID uint) ([]string, error) {
+	query := db.Model(&models.QuestionRevision{}).Where("question_id = ? AND action <> ? AND author <> ''", questionID, models.RevisionDelete)
+	var approval models.QuestionReview
+	err := db.Where("question_id = ? AND action = ?", questionID, "approve").Order("id DESC").First(&approval).Error
+	if err == nil {
+		query = query.Where("created_at > ?", approval.CreatedAt)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var editors []string
+	err = query.Distinct("author").Pluck("author", &editors).Error
+	return editors, err
+}
+
+// returnToDraft 修改题目内容后需要重新审核：不是草稿的题目退回草稿，并以 action 记录流转历史；
+// 没有记录创建者的题目由修改人认领，之后修改人不能审核这道题目
+func returnToDraft(tx *gorm.DB, question *models.Question, action, user string) error {
+	if question.Author == "" && user != "" {
+		if err := tx.Unscoped().Model(question).Update("author", user).Error; err != nil {
+			return err
+		}
+		question.Author = user
+	}
+	if question.Status == models.StatusDraft {
+		return nil
+	}
+	review := models.QuestionReview{
+		QuestionID: question.ID,
+		Action:     action,
+		FromStatus: question.Status,
+		ToStatus:   models.StatusDraft,
+		Reviewer:   user,
+	}
+	if err := tx.Unscoped().Model(question).Update("status", models.StatusDraft).Error; err != nil {
+		return err
+	}
+	question.Status = models.StatusDraft
+	return tx.Create(&review).Error
+}
+
+// 17.7 题目的状态流转历史和审核意见
+func getQuestionReviews(c *gin.Context) {
+	var reviews []models.QuestionReview
+	if err := db.Where("question_id = ?", c.Param("id")).Order("id DESC").Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reviews})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"homework-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type transitionResponse struct {
+	Data   models.Question       `json:"data"`
+	Review models.QuestionReview `json:"review"`
+	Error  string                `json:"error"`
+}
+
+// transition 以 user 的身份执行状态流转
+func transition(t *testing.T, id uint, action, user string, body interface{}) (int, transitionResponse) {
+	t.Helper()
+	var resp transitionResponse
+	code := apiRequest(t, http.MethodPost, fmt.Sprintf("/api/questions/%d/%s", id, action), user, body, &resp)
+	return code, resp
+}
+
+func TestWorkflowSubmitApprovePublish(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", choiceQuestion("errgroup 的错误处理"))
+	if q.Status != models.StatusDraft || q.Author != "alice" {
+		t.Fatalf("new question: status = %s, author = %q", q.Status, q.Author)
+	}
+
+	steps := []struct {
+		action string
+		user   string
+		want   models.QuestionStatus
+	}{
+		{"submit", "alice", models.StatusInReview},
+		{"approve", "bob", models.StatusApproved},
+		{"publish", "alice", models.StatusPublished},
+	}
+	for _, s := range steps {
+		code, resp := transition(t, q.ID, s.action, s.user, nil)
+		if code != http.StatusOK || resp.Data.Status != s.want {
+			t.Fatalf("%s: status = %d, question status = %s, error = %s", s.action, code, resp.Data.Status, resp.Error)
+		}
+	}
+
+	// 默认只列出已发布的题目
+	var resp listResponse
+	apiRequest(t, http.MethodGet, "/api/questions", "", nil, &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != q.ID {
+		t.Errorf("published listing = %d questions, want 1", len(resp.Data))
+	}
+
+	var reviews struct {
+		Data []models.QuestionReview `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, fmt.Sprintf("/api/questions/%d/reviews", q.ID), "", nil, &reviews)
+	if len(reviews.Data) != 3 || reviews.Data[1].Action != "approve" || reviews.Data[1].Reviewer != "bob" {
+		t.Errorf("reviews = %+v", reviews.Data)
+	}
+}
+
+func TestWorkflowRejections(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", choiceQuestion("sync.Mutex 能否复制"))
+
+	if code, _ := transition(t, q.ID, "approve", "bob", nil); code != http.StatusConflict {
+		t.Errorf("approve draft: status = %d, want 409", code)
+	}
+	transition(t, q.ID, "submit", "alice", nil)
+
+	tests := []struct {
+		name   string
+		action string
+		user   string
+		body   interface{}
+		want   int
+	}{
+		{"self review", "approve", "alice", nil, http.StatusForbidden},
+		{"anonymous review", "approve", "", nil, http.StatusBadRequest},
+		{"reject without comment", "reject", "bob", gin.H{"comment": "  "}, http.StatusBadRequest},
+		{"publish before approval", "publish", "alice", nil, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if code, resp := transition(t, q.ID, tt.action, tt.user, tt.body); code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, code, tt.want, resp.Error)
+		}
+	}
+
+	code, resp := transition(t, q.ID, "reject", "bob", gin.H{"comment": "选项 C 也正确"})
+	if code != http.StatusOK || resp.Data.Status != models.StatusDraft || resp.Review.Comment != "选项 C 也正确" {
+		t.Errorf("reject: status = %d, question status = %s, comment = %q", code, resp.Data.Status, resp.Review.Comment)
+	}
+}
+
+func TestWorkflowRequiresAuthor(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "", choiceQuestion("nil map 能否写入"))
+	transition(t, q.ID, "submit", "", nil)
+
+	if code, _ := transition(t, q.ID, "approve", "bob", nil); code != http.StatusForbidden {
+		t.Errorf("approve question without author: status = %d, want 403", code)
+	}
+
+	// 修改题目的人认领为创建者，之后由其他人审核
+	body := choiceQuestion("nil map 能否写入")
+	body["difficulty"] = "medium"
+	var updated struct {
+		Data models.Question `json:"data"`
+	}
+	apiRequest(t, http.MethodPut, fmt.Sprintf("/api/questions/%d", q.ID), "carol", body, &updated)
+	if updated.Data.Author != "carol" || updated.Data.Status != models.StatusDraft {
+		t.Fatalf("after edit: author = %q, status = %s", updated.Data.Author, updated.Data.Status)
+	}
+	transition(t, q.ID, "submit", "carol", nil)
+	if code, _ := transition(t, q.ID, "approve", "carol", nil); code != http.StatusForbidden {
+		t.Errorf("approve own question: status = %d, want 403", code)
+	}
+	if code, resp := transition(t, q.ID, "approve", "bob", nil); code != http.StatusOK {
+		t.Errorf("approve: status = %d (%s)", code, resp.Error)
+	}
+}
+
+func TestEditReturnsToDraft(t *testing.T) {
+	resetData(t)
+	body := choiceQuestion("for range 的循环变量")
+	q := createQuestion(t, "alice", body)
+	base := fmt.Sprintf("/api/questions/%d", q.ID)
+	publish := func() {
+		t.Helper()
+		for _, s := range []struct{ action, user string }{{"submit", "alice"}, {"approve", "bob"}, {"publish", "alice"}} {
+			if code, resp := transition(t, q.ID, s.action, s.user, nil); code != http.StatusOK {
+				t.Fatalf("%s: status = %d (%s)", s.action, code, resp.Error)
+			}
+		}
+	}
+	status := func() models.QuestionStatus {
+		var question models.Question
+		db.First(&question, q.ID)
+		return question.Status
+	}
+
+	publish()
+	body["difficulty"] = "hard"
+	if code := apiRequest(t, http.MethodPut, base, "alice", body, nil); code != http.StatusOK {
+		t.Fatalf("edit: status = %d", code)
+	}
+	if s := status(); s != models.StatusDraft {
+		t.Errorf("after edit: status = %s, want draft", s)
+	}
+	var reviews struct {
+		Data []models.QuestionReview `json:"data"`
+	}
+	apiRequest(t, http.MethodGet, base+"/reviews", "", nil, &reviews)
+	if r := reviews.Data[0]; r.Action != "edit" || r.FromStatus != models.StatusPublished || r.ToStatus != models.StatusDraft || r.Reviewer != "alice" {
+		t.Errorf("latest review = %+v, want edit from published", r)
+	}
+
+	publish()
+	if code := apiRequest(t, http.MethodPost, base+"/revisions/1/restore", "alice", nil, nil); code != http.StatusOK {
+		t.Fatalf("restore: status = %d", code)
+	}
+	if s := status(); s != models.StatusDraft {
+		t.Errorf("after restore: status = %s, want draft", s)
+	}
+}
+
+// approveAndPublish 由 author 提交、reviewer 审核通过后发布
+func approveAndPublish(t *testing.T, id uint, author, reviewer string) {
+	t.Helper()
+	for _, s := range []struct{ action, user string }{{"submit", author}, {"approve", reviewer}, {"publish", author}} {
+		if code, resp := transition(t, id, s.action, s.user, nil); code != http.StatusOK {
+			t.Fatalf("%s: status = %d (%s)", s.action, code, resp.Error)
+		}
+	}
+}
+
+func TestEditorCannotApproveOwnEdit(t *testing.T) {
+	resetData(t)
+	body := choiceQuestion("context 的取消传播")
+	q := createQuestion(t, "alice", body)
+	approveAndPublish(t, q.ID, "alice", "bob")
+
+	// bob 审核过这道题目，但修改之后就不能再审核自己的修改
+	body["difficulty"] = "hard"
+	if code := apiRequest(t, http.MethodPut, fmt.Sprintf("/api/questions/%d", q.ID), "bob", body, nil); code != http.StatusOK {
+		t.Fatalf("edit: status = %d", code)
+	}
+	transition(t, q.ID, "submit", "bob", nil)
+	if code, _ := transition(t, q.ID, "approve", "bob", nil); code != http.StatusForbidden {
+		t.Errorf("approve own edit: status = %d, want 403", code)
+	}
+	if code, resp := transition(t, q.ID, "approve", "carol", nil); code != http.StatusOK {
+		t.Fatalf("approve by carol: status = %d (%s)", code, resp.Error)
+	}
+
+	// 审核通过之前的修改不再限制之后的审核
+	transition(t, q.ID, "reopen", "alice", nil)
+	body["difficulty"] = "medium"
+	apiRequest(t, http.MethodPut, fmt.Sprintf("/api/questions/%d", q.ID), "alice", body, nil)
+	transition(t, q.ID, "submit", "alice", nil)
+	if code, resp := transition(t, q.ID, "approve", "bob", nil); code != http.StatusOK {
+		t.Errorf("approve after earlier edit: status = %d (%s)", code, resp.Error)
+	}
+}
+
+func TestEncodedUserHeader(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "%E5%BC%A0%E4%B8%89", choiceQuestion("struct{} 占用的内存"))
+	if q.Author != "张三" {
+		t.Errorf("author = %q, want 张三", q.Author)
+	}
+}
+
+func TestExplanationAndClassificationReturnToDraft(t *testing.T) {
+	resetData(t)
+	q := createQuestion(t, "alice", choiceQuestion("defer 的执行顺序"))
+	status := func() models.QuestionStatus {
+		var question models.Question
+		db.First(&question, q.ID)
+		return question.Status
+	}
+
+	approveAndPublish(t, q.ID, "alice", "bob")
+	explanation := models.QuestionExplanation{QuestionID: q.ID, Status: models.ExplanationDraft, Content: "defer 按后进先出的顺序执行"}
+	db.Create(&explanation)
+	path := fmt.Sprintf("/api/questions/%d/explanations/%d/approve", q.ID, explanation.ID)
+	if code := apiRequest(t, http.MethodPost, path, "carol", nil, nil); code != http.StatusOK {
+		t.Fatalf("approve explanation: status = %d", code)
+	}
+	if s := status(); s != models.StatusDraft {
+		t.Errorf("after explanation approved: status = %s, want draft", s)
+	}
+	transition(t, q.ID, "submit", "alice", nil)
+	if code, _ := transition(t, q.ID, "approve", "carol", nil); code != http.StatusForbidden {
+		t.Errorf("approve by explanation reviewer: status = %d, want 403", code)
+	}
+	transition(t, q.ID, "approve", "bob", nil)
+	transition(t, q.ID, "publish", "alice", nil)
+
+	suggestion := models.ClassificationSuggestion{QuestionID: q.ID, Status: models.SuggestionPending,
+		SuggestedDifficulty: models.Hard, DifficultyConfidence: 0.9}
+	db.Create(&suggestion)
+	if code := apiRequest(t, http.MethodPost, "/api/classifications/apply", "carol", gin.H{"ids": []uint{suggestion.ID}, "tags": false}, nil); code != http.StatusOK {
+		t.Fatalf("apply classification: status = %d", code)
+	}
+	if s := status(); s != models.StatusDraft {
+		t.Errorf("after classification applied: status = %s, want draft", s)
+	}
+}